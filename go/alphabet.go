@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxAlphabet is the most letters a tile alphabet can hold. The i'th letter
+// is the tile byte 'A'+i, so tiles run from 'A' to '_' (0x41–0x5F) and their
+// blanks (tile | 32) from 'a' to DEL (0x61–0x7F): FNV.Add's &^ 32 still maps
+// a blank onto its letter, and the trie has maxAlphabet children per node.
+const maxAlphabet = 31
+
+// Alphabet maps a ruleset's letters onto tile bytes. The board, racks, the
+// trie and the wordlist hashes all work on tile bytes; only text crossing a
+// dictionary file or a person goes through encode and decode. For English
+// the mapping is the identity.
+type Alphabet struct {
+	letters []string        // letters[i] is tile 'A'+i
+	tiles   map[string]byte // letter -> tile
+	// caseSensitive matches letters exactly as written. Otherwise text is
+	// upper-cased first and a lower-case letter is that letter's blank.
+	caseSensitive bool
+}
+
+// newAlphabet builds an alphabet from its letters in tile order. Each letter
+// is one character; an ASCII letter must sit at its own tile ('B' second and
+// so on), since ASCII text is read as tile bytes.
+func newAlphabet(letters []string, caseSensitive bool) (*Alphabet, error) {
+	if len(letters) == 0 || len(letters) > maxAlphabet {
+		return nil, fmt.Errorf("alphabet has %d letters, want 1 to %d", len(letters), maxAlphabet)
+	}
+	a := &Alphabet{letters: letters, tiles: make(map[string]byte, len(letters)), caseSensitive: caseSensitive}
+	for i, letter := range letters {
+		r, n := utf8.DecodeRuneInString(letter)
+		tile := byte('A' + i)
+		if n == 0 || n != len(letter) {
+			return nil, fmt.Errorf("alphabet letter %q is not a single character", letter)
+		}
+		if r < utf8.RuneSelf && byte(r) != tile {
+			return nil, fmt.Errorf("alphabet letter %q must be letter %d", letter, r-'A'+1)
+		}
+		if _, dup := a.tiles[letter]; dup {
+			return nil, fmt.Errorf("alphabet letter %q is listed twice", letter)
+		}
+		a.tiles[letter] = tile
+	}
+	return a, nil
+}
+
+// englishAlphabet is A–Z, the default.
+var englishAlphabet = func() *Alphabet {
+	letters := make([]string, 26)
+	for i := range letters {
+		letters[i] = string(rune('A' + i))
+	}
+	a, _ := newAlphabet(letters, false)
+	return a
+}()
+
+// alphabet is the active ruleset's alphabet, set by applyRuleset.
+var alphabet = englishAlphabet
+
+// size returns how many letters a has.
+func (a *Alphabet) size() int { return len(a.letters) }
+
+// lastTile returns the tile byte of a's last letter ('Z' for English).
+func (a *Alphabet) lastTile() byte { return byte('A' + len(a.letters) - 1) }
+
+// isTile reports whether t is one of a's tiles or its blank.
+func (a *Alphabet) isTile(t byte) bool {
+	idx := int(t&^32) - 'A'
+	return idx >= 0 && idx < len(a.letters)
+}
+
+// encode maps word to tile bytes, failing on a character a lacks. ASCII
+// letters are already tile bytes and pass through, blanks (lowercase)
+// included, so encoded text encodes to itself.
+func (a *Alphabet) encode(word string) (string, bool) {
+	out := make([]byte, 0, len(word))
+	for _, r := range word {
+		if r < utf8.RuneSelf {
+			if !a.isTile(byte(r)) {
+				return "", false
+			}
+			out = append(out, byte(r))
+			continue
+		}
+		blank := false
+		if !a.caseSensitive {
+			if u := unicode.ToUpper(r); u != r {
+				r, blank = u, true
+			}
+		}
+		tile, ok := a.tiles[string(r)]
+		if !ok {
+			return "", false
+		}
+		if blank {
+			tile |= 32
+		}
+		out = append(out, tile)
+	}
+	return string(out), true
+}
+
+// decode spells tile bytes as letters. Blanks come out lower-case unless the
+// alphabet is case-sensitive; anything that isn't a tile is copied as is.
+func (a *Alphabet) decode(tiles string) string {
+	var sb strings.Builder
+	for i := 0; i < len(tiles); i++ {
+		t := tiles[i]
+		if !a.isTile(t) {
+			sb.WriteByte(t)
+			continue
+		}
+		letter := a.letters[t&^32-'A']
+		if isBlankTile(t) && !a.caseSensitive {
+			letter = strings.ToLower(letter)
+		}
+		sb.WriteString(letter)
+	}
+	return sb.String()
+}
+
+// fingerprint identifies the alphabet for the trie cache, so a trie built
+// under other letters is not reused.
+func (a *Alphabet) fingerprint() uint64 {
+	h := NewFNV()
+	h.AddString(strings.Join(a.letters, "\n"))
+	if a.caseSensitive {
+		h.AddString("\ncase-sensitive")
+	}
+	return h.Val()
+}
+
+// isBlankTile reports whether t is a blank: the lower-case form of a tile.
+func isBlankTile(t byte) bool {
+	return t >= 'a' && t < 'a'+maxAlphabet
+}
+
+// upperTiles returns tiles with every blank turned into its letter.
+func upperTiles(tiles string) string {
+	b := []byte(tiles)
+	for i, t := range b {
+		if isBlankTile(t) {
+			b[i] = t &^ 32
+		}
+	}
+	return string(b)
+}
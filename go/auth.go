@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"os"
 	"strings"
 
 	"github.com/coreos/go-oidc/v3/oidc"
@@ -92,6 +93,39 @@ func getUserClaimsFromContext(ctx context.Context) *UserClaims {
 	return nil
 }
 
+// ── Admin access ────────────────────────────────────────────────────────────
+
+// loadAdminSubjects reads the comma-separated ADMIN_USERS env var: the OIDC
+// subjects (sub claims) allowed to call /api/admin/ endpoints. Empty when
+// unset, which disables the admin endpoints.
+func loadAdminSubjects() map[string]bool {
+	admins := make(map[string]bool)
+	for _, sub := range strings.Split(os.Getenv("ADMIN_USERS"), ",") {
+		if sub = strings.TrimSpace(sub); sub != "" {
+			admins[sub] = true
+		}
+	}
+	return admins
+}
+
+// requireAdmin wraps h so that only authenticated users listed in admins can
+// reach it: 401 without a verified token, 403 for everyone else. Anonymous
+// session IDs never count as authenticated here.
+func requireAdmin(admins map[string]bool, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := getUserClaimsFromContext(r.Context())
+		if claims == nil {
+			writeError(w, 401, "not authenticated")
+			return
+		}
+		if !admins[claims.Subject] {
+			writeError(w, 403, "admin access required")
+			return
+		}
+		h(w, r)
+	}
+}
+
 // ── Middleware ───────────────────────────────────────────────────────────────
 
 // extractAuth reads the Bearer token from the Authorization header, validates
@@ -2,10 +2,16 @@ package main
 
 import (
 	"bufio"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math/rand"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 )
 
 type FNV struct {
@@ -36,20 +42,239 @@ func (h *FNV) Val() uint64 {
 var tilePoints = [255]int{'A': 1, 'B': 4, 'C': 3, 'D': 2, 'E': 1, 'F': 4, 'G': 4, 'H': 3, 'I': 1, 'J': 10, 'K': 6, 'L': 2, 'M': 3, 'N': 1, 'O': 1, 'P': 3, 'Q': 10, 'R': 1, 'S': 1, 'T': 1, 'U': 2, 'V': 6, 'W': 5, 'X': 8, 'Y': 4, 'Z': 10}
 var startTiles = "AAAAAAAAABBCCDDDDEEEEEEEEEEEEFFGGGHHIIIIIIIIIJKLLLLMMNNNNNNOOOOOOOOPPQRRRRRRSSSSTTTTTTUUUUVVWWXYYZ**"
 
+// remainingTiles subtracts the tiles in each play from the full startTiles
+// distribution and returns what is left unseen (bag plus opponents' racks).
+// Lowercase letters are blanks and consume a '*'. Returns an error if a play
+// uses a tile more times than the distribution contains.
+func remainingTiles(plays []string) (map[byte]int, error) {
+	counts := make(map[byte]int)
+	for i := 0; i < len(startTiles); i++ {
+		counts[startTiles[i]]++
+	}
+	for _, tiles := range plays {
+		for i := 0; i < len(tiles); i++ {
+			t := tiles[i]
+			if isBlankTile(t) {
+				t = '*'
+			}
+			if counts[t] == 0 {
+				return nil, fmt.Errorf("more %q tiles played than the distribution holds", t)
+			}
+			counts[t]--
+		}
+	}
+	return counts, nil
+}
+
+// TileBag is the supply of each tile (uppercase letters and '*') left in the
+// full startTiles set once the tiles on a board are taken out. Unlike
+// remainingTiles it never fails: counts go negative when the board already
+// holds more of a tile than the set has.
+type TileBag map[byte]int
+
+// newTileBag counts the supply left after board. Blanks on the board are
+// stored lowercase and count against '*'.
+func newTileBag(board [][]byte) TileBag {
+	bag := make(TileBag)
+	for i := 0; i < len(startTiles); i++ {
+		bag[startTiles[i]]++
+	}
+	for x := range board {
+		for _, t := range board[x] {
+			if t != 0 {
+				bag[tileKind(t)]--
+			}
+		}
+	}
+	return bag
+}
+
+// tileKind maps a placed tile to the bag entry it came from: '*' for a blank
+// (lowercase), else the letter itself.
+func tileKind(t byte) byte {
+	if isBlankTile(t) {
+		return '*'
+	}
+	return t
+}
+
+// covers reports whether the bag still holds every tile in tiles, blanks
+// (lowercase) drawing on '*'.
+func (bag TileBag) covers(tiles string) bool {
+	need := make(map[byte]int)
+	for i := 0; i < len(tiles); i++ {
+		need[tileKind(tiles[i])]++
+	}
+	for t, n := range need {
+		if n > bag[t] {
+			return false
+		}
+	}
+	return true
+}
+
+// rackSize is the number of tiles in a full rack: the ruleset's rack_size,
+// default 7. Playing that many in one move earns bingoBonus; a shorter rack
+// (e.g. a partially known one) can never bingo, however many tiles it places.
+var rackSize = 7
+
+// isBingo reports whether placing placed tiles from a rack of rackLen earns
+// the bingo bonus.
+func isBingo(rackLen, placed int) bool {
+	return rackLen >= rackSize && placed == rackSize
+}
+
+// Board transforms map (x, y) to a new square on the boardSize×boardSize grid. Rotations
+// are clockwise; flipBoardH mirrors left↔right, flipBoardV top↔bottom.
+func rotateBoard90(board [][]byte) [][]byte {
+	return mapBoard(board, func(x, y int) (int, int) { return boardSize - 1 - y, x })
+}
+
+func flipBoardH(board [][]byte) [][]byte {
+	return mapBoard(board, func(x, y int) (int, int) { return boardSize - 1 - x, y })
+}
+
+func flipBoardV(board [][]byte) [][]byte {
+	return mapBoard(board, func(x, y int) (int, int) { return x, boardSize - 1 - y })
+}
+
+// mapBoard returns a new board with each tile at (x, y) moved to f(x, y).
+func mapBoard(board [][]byte, f func(x, y int) (int, int)) [][]byte {
+	out := make([][]byte, boardSize)
+	for i := range out {
+		out[i] = make([]byte, boardSize)
+	}
+	for x := 0; x < boardSize; x++ {
+		for y := 0; y < boardSize; y++ {
+			nx, ny := f(x, y)
+			out[nx][ny] = board[x][y]
+		}
+	}
+	return out
+}
+
+// boardTransforms names the operations /api/transform accepts.
+var boardTransforms = map[string]func([][]byte) [][]byte{
+	"rotate90":  rotateBoard90,
+	"rotate180": func(b [][]byte) [][]byte { return rotateBoard90(rotateBoard90(b)) },
+	"rotate270": func(b [][]byte) [][]byte { return rotateBoard90(rotateBoard90(rotateBoard90(b))) },
+	"flipH":     flipBoardH,
+	"flipV":     flipBoardV,
+}
+
+// premiumsInvariant reports whether transform t maps every premium square
+// of the active ruleset onto a premium of the same kind, so every play
+// scores the same on the transformed board.
+func premiumsInvariant(t func([][]byte) [][]byte) bool {
+	for _, layout := range [][]bool{tw, dw, tl, dl} {
+		grid := make([][]byte, boardSize)
+		for x := range grid {
+			grid[x] = make([]byte, boardSize)
+			for y := 0; y < boardSize; y++ {
+				if layout[cti(x, y)] {
+					grid[x][y] = 1
+				}
+			}
+		}
+		moved := t(grid)
+		for x := 0; x < boardSize; x++ {
+			for y := 0; y < boardSize; y++ {
+				if (moved[x][y] == 1) != layout[cti(x, y)] {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// placedTiles returns every tile on the board as one string, in column-major
+// order. Blanks stay lowercase, so it can be passed to remainingTiles.
+func (b *Board) placedTiles() string {
+	var sb strings.Builder
+	for x := 0; x < boardSize; x++ {
+		for y := 0; y < boardSize; y++ {
+			if b.board[x][y] != 0 {
+				sb.WriteByte(b.board[x][y])
+			}
+		}
+	}
+	return sb.String()
+}
+
 var bingoBonus = 40 // default: NYT Crossplay; Standard Scrabble uses 50
 
-var tw = [225]bool{3: true, 11: true, 45: true, 59: true, 165: true, 179: true, 213: true, 221: true}
-var dw = [225]bool{16: true, 28: true, 52: true, 108: true, 116: true, 172: true, 196: true, 208: true}
-var tl = [225]bool{0: true, 14: true, 21: true, 23: true, 65: true, 69: true, 79: true, 85: true, 91: true, 103: true, 121: true, 133: true, 139: true, 145: true, 155: true, 159: true, 201: true, 203: true, 210: true, 224: true}
-var dl = [225]bool{7: true, 34: true, 40: true, 48: true, 56: true, 62: true, 72: true, 82: true, 105: true, 110: true, 114: true, 119: true, 142: true, 152: true, 162: true, 168: true, 176: true, 184: true, 190: true, 217: true}
+// targetScore ends an AI game as soon as a player reaches it. 0 (the default)
+// means play until the bag empties.
+var targetScore = 0
+
+// Challenge rules, set by a ruleset's challenge_rule, decide what a play
+// ruled a phony costs. Either way the play is withdrawn and the turn lost;
+// under challengeWithPenalty the player is also docked challengePenalty points.
+const (
+	challengeLoseTurn    = "lose-turn"
+	challengeWithPenalty = "penalty"
+)
+
+var challengeRule = challengeLoseTurn
+
+// challengePenalty is what challengeWithPenalty docks for a phony (ruleset
+// challenge_penalty, default 5).
+var challengePenalty = 5
+
+// phonyScore is the score a withdrawn phony counts for under challengeRule:
+// 0, or -challengePenalty under challengeWithPenalty.
+func phonyScore() int {
+	if challengeRule == challengeWithPenalty {
+		return -challengePenalty
+	}
+	return 0
+}
+
+// boardSize is the width and height of the board in squares, set by the
+// ruleset (default 15). Boards are boardSize×boardSize; cti, the premium
+// layouts and every board loop derive from it.
+var boardSize = 15
+
+// minBoardSize and maxBoardSize bound a ruleset's board_size. Columns are
+// named by letter in square notation (H8), so a board has at most 26.
+const (
+	minBoardSize = 5
+	maxBoardSize = 26
+)
+
+// center is the square the first word must cover, as [x, y].
+var center = [2]int{7, 7}
+
+// centerEmpty reports whether nothing has been played on the center square
+// yet, i.e. the next move is the opening move.
+func (b *Board) centerEmpty() bool {
+	return b.board[center[0]][center[1]] == 0
+}
+
+// Premium squares, indexed by cti and boardSize*boardSize long. The
+// compiled-in defaults are the 15×15 crossplay layout.
+var tw = (&[225]bool{3: true, 11: true, 45: true, 59: true, 165: true, 179: true, 213: true, 221: true})[:]
+var dw = (&[225]bool{16: true, 28: true, 52: true, 108: true, 116: true, 172: true, 196: true, 208: true})[:]
+var tl = (&[225]bool{0: true, 14: true, 21: true, 23: true, 65: true, 69: true, 79: true, 85: true, 91: true, 103: true, 121: true, 133: true, 139: true, 145: true, 155: true, 159: true, 201: true, 203: true, 210: true, 224: true})[:]
+var dl = (&[225]bool{7: true, 34: true, 40: true, 48: true, 56: true, 62: true, 72: true, 82: true, 105: true, 110: true, 114: true, 119: true, 142: true, 152: true, 162: true, 168: true, 176: true, 184: true, 190: true, 217: true})[:]
 
 type direction int
 
 var DIR_VERT direction = 0
 var DIR_HORIZ direction = 1
 
+// verbosity controls how much DoTurn prints. The zero value is normal output.
+type verbosity int
+
+const (
+	verbosityNormal  verbosity = iota // one line per move
+	verbosityQuiet                    // no per-move output
+	verbosityVerbose                  // per-move output plus search stats
+)
+
 type TrieNode struct {
-	children [26]*TrieNode
+	children [maxAlphabet]*TrieNode // indexed by tile - 'A'
 	isEnd    bool
 }
 
@@ -58,15 +283,126 @@ type Board struct {
 	tiles    []byte
 	wordlist map[uint64]struct{}
 	trie     *TrieNode
-	pscore   [2]int
-	ptiles   [2][]byte
+	pscore   []int    // one per player; see NewBoard
+	ptiles   [][]byte // racks, indexed like pscore
+	verbose  verbosity
+	rng      *rand.Rand // shuffles the bag; fixed-seed for reproducible games
+
+	// maxNewTiles caps how many empty squares one move may fill, bounding
+	// searchPlay's depth on dense boards. 0 means no cap beyond the rack.
+	maxNewTiles int
+	// maxBlanks caps how many blanks one move may place; searchPlay prunes
+	// any branch that would place more. 0 means no cap.
+	maxBlanks int
+	// minScore drops moves scoring less from findAllMoves (and so from
+	// findTopNMoves before it truncates). 0 keeps everything.
+	minScore int
+	// minWordLen drops moves whose main word is shorter, in findAllMoves
+	// like minScore. Unlike maxNewTiles it counts the letters already on
+	// the board, so extending a word to the minimum still qualifies.
+	minWordLen int
+	// bingoOnly makes recordMove keep only bingos and the search stop as
+	// soon as it has one: the "is there a bingo?" fast path.
+	bingoOnly bool
+	// deadline, if non-zero, stops generateMoves between anchors once it
+	// has passed, returning whatever was found so far.
+	deadline time.Time
+	// onlyRows and onlyCols, when non-nil, limit generateMoves to horizontal
+	// anchors in those rows and vertical anchors in those columns. Set only by
+	// the incremental re-solve (see moveCache).
+	onlyRows, onlyCols map[int]bool
+	// cache, if set, lets findAllMoves reuse the previous search for the same
+	// rack, regenerating only around squares filled since (solve --incremental).
+	cache *moveCache
+	// scoreless counts consecutive turns in a self-play game that scored
+	// nothing (passes and exchanges); see maxScorelessTurns.
+	scoreless int
+	// history records every self-play turn, in order (see runGame -json).
+	history []gameTurn
 }
 
 func cti(x, y int) int {
-	return y*15 + x
+	return y*boardSize + x
+}
+
+// loadExclusions reads a word list of entries to drop from the dictionary at
+// load time (e.g. proper nouns that slipped into a downloaded list). A missing
+// file is not an error: it yields an empty set. Words are read through a, and
+// any it can't spell are skipped.
+func loadExclusions(filename string, a *Alphabet) (map[uint64]struct{}, error) {
+	excluded := make(map[uint64]struct{})
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return excluded, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for line, _, err := r.ReadLine(); err == nil; line, _, err = r.ReadLine() {
+		if word, ok := a.encode(strings.TrimSpace(string(line))); ok && word != "" {
+			excluded[wordHash(word)] = struct{}{}
+		}
+	}
+	return excluded, nil
+}
+
+// loadDefinitions reads word definitions, one "WORD<TAB>definition" per line,
+// keyed by the uppercased word. It is independent of the dictionary, so any
+// source can be dropped in. A missing file returns a nil map and no error;
+// lines without a tab are skipped.
+func loadDefinitions(filename string) (map[string]string, error) {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	defs := make(map[string]string)
+	r := bufio.NewReader(f)
+	for line, _, err := r.ReadLine(); err == nil; line, _, err = r.ReadLine() {
+		word, def, ok := strings.Cut(string(line), "\t")
+		if word = strings.ToUpper(strings.TrimSpace(word)); ok && word != "" {
+			defs[word] = strings.TrimSpace(def)
+		}
+	}
+	return defs, nil
+}
+
+// wordHash returns the FNV-1a hash of a word in tile bytes used for wordlist
+// keys. Blanks hash as their letter.
+func wordHash(word string) uint64 {
+	h := NewFNV()
+	for i := 0; i < len(word); i++ {
+		h.Add(word[i])
+	}
+	return h.Val()
+}
+
+// isExcluded reports whether word is in an exclusion set from loadExclusions.
+func isExcluded(excluded map[uint64]struct{}, word string) bool {
+	_, ok := excluded[wordHash(word)]
+	return ok
 }
 
-func buildTrie(filename string) (*TrieNode, error) {
+// buildTrie returns the move-search trie for a dictionary file in alphabet a,
+// skipping words in excluded (may be nil). A prebuilt cache at
+// trieCachePath(filename) is used when it is newer than the dictionary and
+// was built with the same alphabet and exclusions; otherwise the dictionary
+// is parsed.
+func buildTrie(filename string, a *Alphabet, excluded map[uint64]struct{}) (*TrieNode, error) {
+	if root, err := loadTrieCache(trieCachePath(filename), filename, a, excluded); err == nil {
+		return root, nil
+	}
+	return parseTrie(filename, a, excluded)
+}
+
+// parseTrie builds the trie by reading every word of the dictionary file.
+// Words are read through a; any it can't spell are skipped.
+func parseTrie(filename string, a *Alphabet, excluded map[uint64]struct{}) (*TrieNode, error) {
 	root := &TrieNode{}
 	f, err := os.Open(filename)
 	if err != nil {
@@ -75,67 +411,476 @@ func buildTrie(filename string) (*TrieNode, error) {
 	defer f.Close()
 	r := bufio.NewReader(f)
 	for line, _, err := r.ReadLine(); err == nil; line, _, err = r.ReadLine() {
-		word := strings.TrimRight(string(line), "\r\n")
-		if len(word) < 2 {
+		word, ok := a.encode(strings.TrimRight(string(line), "\r\n"))
+		if !ok || len(word) < 2 || isExcluded(excluded, word) {
 			continue
 		}
 		node := root
-		valid := true
 		for i := 0; i < len(word); i++ {
 			idx := int(word[i]&^32) - int('A')
-			if idx < 0 || idx >= 26 {
-				valid = false
-				break
-			}
 			if node.children[idx] == nil {
 				node.children[idx] = &TrieNode{}
 			}
 			node = node.children[idx]
 		}
-		if valid {
-			node.isEnd = true
+		node.isEnd = true
+	}
+	return root, nil
+}
+
+// DAWG is a minimized word graph: the trie with every repeated suffix
+// subtree stored once. Nodes keep the TrieNode layout, so Root can stand in
+// for a trie anywhere one is walked (searchPlay, trieContains, longestPlayable
+// and so on); only the node count is much smaller.
+type DAWG struct {
+	root  *TrieNode
+	nodes int
+}
+
+// dawgKey identifies a node by its end flag and children. Two nodes with the
+// same key accept the same suffixes, so one can replace the other.
+type dawgKey struct {
+	isEnd    bool
+	children [maxAlphabet]*TrieNode
+}
+
+// buildDAWG builds a DAWG for a dictionary file in alphabet a, skipping words
+// in excluded (may be nil) and, as parseTrie does, any a can't spell. Words
+// are sorted and inserted in order, minimizing the previous word's path below
+// the shared prefix as it goes, so the full trie never exists in memory.
+func buildDAWG(filename string, a *Alphabet, excluded map[uint64]struct{}) (*DAWG, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var words []string
+	r := bufio.NewReader(f)
+	for line, _, err := r.ReadLine(); err == nil; line, _, err = r.ReadLine() {
+		word, ok := a.encode(strings.TrimRight(string(line), "\r\n"))
+		if !ok || len(word) < 2 || isExcluded(excluded, word) {
+			continue
+		}
+		words = append(words, upperTiles(word))
+	}
+	sort.Strings(words)
+
+	d := &DAWG{root: &TrieNode{}, nodes: 1}
+	register := make(map[dawgKey]*TrieNode)
+	path := []*TrieNode{d.root} // path[i] is the node after prev[:i]
+	prev := ""
+	// minimize replaces the nodes of prev deeper than depth with registered
+	// equivalents, deepest first, so each node's children are final before
+	// the node itself is looked up.
+	minimize := func(depth int) {
+		for i := len(prev); i > depth; i-- {
+			node := path[i]
+			key := dawgKey{node.isEnd, node.children}
+			if same, ok := register[key]; ok {
+				path[i-1].children[prev[i-1]-'A'] = same
+				d.nodes--
+			} else {
+				register[key] = node
+			}
+		}
+	}
+	for _, word := range words {
+		common := 0
+		for common < len(prev) && common < len(word) && prev[common] == word[common] {
+			common++
+		}
+		minimize(common)
+		path = path[:common+1]
+		for i := common; i < len(word); i++ {
+			node := &TrieNode{}
+			path[i].children[word[i]-'A'] = node
+			path = append(path, node)
+			d.nodes++
 		}
+		path[len(word)].isEnd = true
+		prev = word
+	}
+	minimize(0)
+	return d, nil
+}
+
+// Root returns the DAWG's start node, walked exactly like a trie root.
+func (d *DAWG) Root() *TrieNode { return d.root }
+
+// Contains reports whether word (tile bytes) is in the DAWG.
+func (d *DAWG) Contains(word string) bool { return trieContains(d.root, word) }
+
+// NodeCount returns the number of distinct nodes in the DAWG.
+func (d *DAWG) NodeCount() int { return d.nodes }
+
+// dictIndex selects the move-search index loadIndex builds: "trie" (the
+// default) or "dawg". Set from config.json's "index" key by loadRuleset.
+var dictIndex = "trie"
+
+// loadIndex returns the move-search root for dict as chosen by dictIndex:
+// buildTrie's trie, or buildDAWG's graph when dictIndex is "dawg". The DAWG
+// takes a fraction of the memory but is built from the word list each time;
+// the trie can come from a prebuilt cache (see runBuildTrie).
+func loadIndex(dict string, a *Alphabet, excluded map[uint64]struct{}) (*TrieNode, error) {
+	if dictIndex == "dawg" {
+		d, err := buildDAWG(dict, a, excluded)
+		if err != nil {
+			return nil, err
+		}
+		return d.Root(), nil
+	}
+	return buildTrie(dict, a, excluded)
+}
+
+// trieContains reports whether word (tile bytes, blanks allowed) ends at a
+// node of the trie. Unlike a wordlist lookup it cannot suffer hash collisions.
+func trieContains(root *TrieNode, word string) bool {
+	node := root
+	for i := 0; i < len(word) && node != nil; i++ {
+		idx := int(word[i]&^32) - int('A')
+		if idx < 0 || idx >= maxAlphabet {
+			return false
+		}
+		node = node.children[idx]
+	}
+	return node != nil && node.isEnd
+}
+
+// countTrieNodes returns the number of nodes in the trie rooted at node,
+// including node itself.
+func countTrieNodes(node *TrieNode) int {
+	if node == nil {
+		return 0
+	}
+	n := 1
+	for _, child := range node.children {
+		n += countTrieNodes(child)
+	}
+	return n
+}
+
+// longestPlayable returns the longest word in trie that can be spelled from
+// rack, ignoring any board. A '*' in the rack stands for any letter; letters
+// it supplies are lowercase in the result, as on the board. Real tiles are
+// tried before blanks, and among equally long words the alphabetically first
+// wins. Returns "" when nothing can be spelled or trie is nil.
+func longestPlayable(rack []byte, trie *TrieNode) string {
+	var counts [maxAlphabet]int
+	blanks := 0
+	for _, c := range rack {
+		if c == '*' {
+			blanks++
+		} else if c >= 'A' && c < 'A'+maxAlphabet {
+			counts[c-'A']++
+		}
+	}
+	total := len(rack)
+	best := ""
+	word := make([]byte, 0, total)
+	var walk func(node *TrieNode)
+	walk = func(node *TrieNode) {
+		if node.isEnd && len(word) > len(best) {
+			best = string(word)
+		}
+		if len(best) == total {
+			return
+		}
+		for i, child := range node.children {
+			if child == nil {
+				continue
+			}
+			if counts[i] > 0 {
+				counts[i]--
+				word = append(word, byte('A'+i))
+				walk(child)
+				word = word[:len(word)-1]
+				counts[i]++
+			} else if blanks > 0 {
+				blanks--
+				word = append(word, byte('a'+i))
+				walk(child)
+				word = word[:len(word)-1]
+				blanks++
+			}
+		}
+	}
+	if trie != nil {
+		walk(trie)
+	}
+	return best
+}
+
+// bingoStems finds, for each letter, the words of len(rack)+1 letters that
+// rack plus that letter spells: a "stem" analysis of a 6-tile rack. Blanks in
+// rack stand for any letter. Words are uppercase, sorted, and capped at
+// maxPerLetter per letter; truncated reports whether any list was cut.
+func bingoStems(rack []byte, trie *TrieNode, maxPerLetter int) (stems map[byte][]string, truncated bool) {
+	var counts [maxAlphabet]int
+	blanks := 0
+	for _, c := range rack {
+		if c == '*' {
+			blanks++
+		} else if c >= 'A' && c < 'A'+maxAlphabet {
+			counts[c-'A']++
+		}
+	}
+	found := make(map[byte]map[string]bool)
+	word := make([]byte, 0, len(rack)+1)
+	extra := byte(0) // the added letter, once the path has used it
+	var walk func(node *TrieNode)
+	walk = func(node *TrieNode) {
+		if len(word) == len(rack)+1 {
+			if node.isEnd {
+				if found[extra] == nil {
+					found[extra] = make(map[string]bool)
+				}
+				found[extra][string(word)] = true
+			}
+			return
+		}
+		for i, child := range node.children {
+			if child == nil {
+				continue
+			}
+			c := byte('A' + i)
+			word = append(word, c)
+			if counts[i] > 0 {
+				counts[i]--
+				walk(child)
+				counts[i]++
+			}
+			if blanks > 0 {
+				blanks--
+				walk(child)
+				blanks++
+			}
+			if extra == 0 {
+				extra = c
+				walk(child)
+				extra = 0
+			}
+			word = word[:len(word)-1]
+		}
+	}
+	if trie != nil {
+		walk(trie)
+	}
+
+	stems = make(map[byte][]string, len(found))
+	for c, words := range found {
+		list := make([]string, 0, len(words))
+		for w := range words {
+			list = append(list, w)
+		}
+		sort.Strings(list)
+		if len(list) > maxPerLetter {
+			list, truncated = list[:maxPerLetter], true
+		}
+		stems[c] = list
+	}
+	return stems, truncated
+}
+
+// ── Trie cache ────────────────────────────────────────────────────────────────
+//
+// Format: the magic line "TRIE2\n", the uint64 fingerprint of the alphabet
+// and exclusions, the uint64 node count (so loading is a single allocation),
+// then every node in pre-order as one isEnd byte and a uint32 bitmask of
+// which children follow (bit i = tile 'A'+i). All integers are little-endian.
+
+const trieCacheMagic = "TRIE2\n"
+
+// trieCachePath is where buildTrie looks for a prebuilt trie for dict.
+func trieCachePath(dict string) string {
+	return dict + ".trie"
+}
+
+// exclusionFingerprint summarises an exclusion set independent of order, so
+// a cache built with different exclusions is not reused.
+func exclusionFingerprint(excluded map[uint64]struct{}) uint64 {
+	var fp uint64
+	for h := range excluded {
+		fp ^= h
+	}
+	return fp ^ uint64(len(excluded))*0x100000001b3
+}
+
+// saveTrieCache writes root, built in alphabet a, to filename in the trie
+// cache format.
+func saveTrieCache(filename string, root *TrieNode, a *Alphabet, excluded map[uint64]struct{}) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	w.WriteString(trieCacheMagic)
+	binary.Write(w, binary.LittleEndian, a.fingerprint()^exclusionFingerprint(excluded))
+	binary.Write(w, binary.LittleEndian, uint64(countTrieNodes(root)))
+	writeTrieNode(w, root)
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeTrieNode(w *bufio.Writer, node *TrieNode) {
+	var rec [5]byte
+	if node.isEnd {
+		rec[0] = 1
+	}
+	var mask uint32
+	for i, child := range node.children {
+		if child != nil {
+			mask |= 1 << i
+		}
+	}
+	binary.LittleEndian.PutUint32(rec[1:], mask)
+	w.Write(rec[:])
+	for _, child := range node.children {
+		if child != nil {
+			writeTrieNode(w, child)
+		}
+	}
+}
+
+// loadTrieCache reads a trie written by saveTrieCache. It fails if the cache
+// is missing, older than dict, corrupt, or built with another alphabet or
+// other exclusions.
+func loadTrieCache(filename, dict string, a *Alphabet, excluded map[uint64]struct{}) (*TrieNode, error) {
+	cacheInfo, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	dictInfo, err := os.Stat(dict)
+	if err != nil {
+		return nil, err
+	}
+	if !cacheInfo.ModTime().After(dictInfo.ModTime()) {
+		return nil, fmt.Errorf("trie cache %s is older than %s", filename, dict)
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	magic := make([]byte, len(trieCacheMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != trieCacheMagic {
+		return nil, fmt.Errorf("trie cache %s: bad header", filename)
+	}
+	var fp uint64
+	if err := binary.Read(r, binary.LittleEndian, &fp); err != nil {
+		return nil, err
+	}
+	if fp != a.fingerprint()^exclusionFingerprint(excluded) {
+		return nil, fmt.Errorf("trie cache %s was built with a different alphabet or exclusions", filename)
+	}
+	var count uint64
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return nil, err
+	}
+	if count == 0 || count > uint64(cacheInfo.Size()) {
+		return nil, fmt.Errorf("trie cache %s: bad node count", filename)
+	}
+	free := make([]TrieNode, count)
+	root, err := readTrieNode(r, &free)
+	if err != nil {
+		return nil, fmt.Errorf("trie cache %s: %w", filename, err)
 	}
 	return root, nil
 }
 
+// readTrieNode reads one node and its subtree, taking storage from the front
+// of *nodes.
+func readTrieNode(r *bufio.Reader, nodes *[]TrieNode) (*TrieNode, error) {
+	var rec [5]byte
+	if _, err := io.ReadFull(r, rec[:]); err != nil {
+		return nil, err
+	}
+	if len(*nodes) == 0 {
+		return nil, fmt.Errorf("more nodes than the header declares")
+	}
+	node := &(*nodes)[0]
+	*nodes = (*nodes)[1:]
+	node.isEnd = rec[0] == 1
+	mask := binary.LittleEndian.Uint32(rec[1:])
+	for i := range node.children {
+		if mask&(1<<i) == 0 {
+			continue
+		}
+		var err error
+		if node.children[i], err = readTrieNode(r, nodes); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
 func (b *Board) addWord(word string) {
-	f := NewFNV()
-	f.AddString(word)
-	b.wordlist[f.Val()] = struct{}{}
+	b.wordlist[wordHash(word)] = struct{}{}
 }
 
-func loadDictionary(filename string) (map[uint64]struct{}, error) {
+// loadDictionary builds the FNV wordlist from a dictionary file in alphabet
+// a, skipping words in excluded (may be nil) and any a can't spell.
+func loadDictionary(filename string, a *Alphabet, excluded map[uint64]struct{}) (map[uint64]struct{}, error) {
+	wordlist, _, err := readDictionary(filename, a, excluded, nil)
+	return wordlist, err
+}
+
+// loadDictionaryChecked is loadDictionary with no exclusions that also
+// reports FNV collisions: each entry is "FIRST/LATER" for a word whose hash
+// was already taken by a different word. Either would be accepted for the
+// other when solving, so a non-empty list means the wordlist can't be
+// trusted to reject every non-word.
+func loadDictionaryChecked(filename string, a *Alphabet) (map[uint64]struct{}, []string, error) {
+	return readDictionary(filename, a, nil, make(map[uint64]string))
+}
+
+// readDictionary reads filename into an FNV wordlist, hashing each word's
+// tiles in alphabet a. When seen is non-nil it records the first word for
+// each hash and returns the collisions found.
+func readDictionary(filename string, a *Alphabet, excluded map[uint64]struct{}, seen map[uint64]string) (map[uint64]struct{}, []string, error) {
 	wordlist := make(map[uint64]struct{})
 	f, err := os.Open(filename)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer f.Close()
+	var collisions []string
 	r := bufio.NewReader(f)
 	for line, _, err := r.ReadLine(); err == nil; line, _, err = r.ReadLine() {
-		word := strings.TrimRight(string(line), "\r\n")
-		if len(word) > 1 {
-			h := NewFNV()
-			h.AddString(word)
-			wordlist[h.Val()] = struct{}{}
+		text := strings.TrimRight(string(line), "\r\n")
+		word, ok := a.encode(text)
+		if ok && len(word) > 1 && !isExcluded(excluded, word) {
+			v := wordHash(word)
+			wordlist[v] = struct{}{}
+			if seen != nil {
+				if first, ok := seen[v]; !ok {
+					seen[v] = text
+				} else if firstTiles, _ := a.encode(first); upperTiles(firstTiles) != upperTiles(word) {
+					collisions = append(collisions, first+"/"+text)
+				}
+			}
 		}
 	}
-	return wordlist, nil
+	return wordlist, collisions, nil
 }
 
 func (b *Board) checkCenterPlayed(x, y, tiles int, dir direction) bool {
-	if b.board[7][7] != 0 {
+	if !b.centerEmpty() {
 		return true
 	}
+	cx, cy := center[0], center[1]
 	if dir == DIR_VERT {
-		return x == 7 && y <= 7 && (y+tiles) > 7
+		return x == cx && y <= cy && (y+tiles) > cy
 	}
-	return y == 7 && x <= 7 && (x+tiles) > 7
+	return y == cy && x <= cx && (x+tiles) > cx
 }
 
 func (b *Board) checkContiguous(x, y, tiles int, dir direction) bool {
-	if b.board[7][7] == 0 {
+	if b.centerEmpty() {
 		return true
 	}
 	if dir == DIR_VERT {
@@ -143,7 +888,7 @@ func (b *Board) checkContiguous(x, y, tiles int, dir direction) bool {
 			if b.board[x][i] == 0 {
 				tiles--
 			}
-			if (x > 0 && b.board[x-1][i] != 0) || (x < 14 && b.board[x+1][i] != 0) || (i > 0 && b.board[x][i-1] != 0) || (i < 14 && b.board[x][i+1] != 0) {
+			if (x > 0 && b.board[x-1][i] != 0) || (x < boardSize-1 && b.board[x+1][i] != 0) || (i > 0 && b.board[x][i-1] != 0) || (i < boardSize-1 && b.board[x][i+1] != 0) {
 				return true
 			}
 		}
@@ -152,7 +897,7 @@ func (b *Board) checkContiguous(x, y, tiles int, dir direction) bool {
 			if b.board[i][y] == 0 {
 				tiles--
 			}
-			if (i > 0 && b.board[i-1][y] != 0) || (i < 14 && b.board[i+1][y] != 0) || (y > 0 && b.board[i][y-1] != 0) || (y < 14 && b.board[i][y+1] != 0) {
+			if (i > 0 && b.board[i-1][y] != 0) || (i < boardSize-1 && b.board[i+1][y] != 0) || (y > 0 && b.board[i][y-1] != 0) || (y < boardSize-1 && b.board[i][y+1] != 0) {
 				return true
 			}
 		}
@@ -160,6 +905,33 @@ func (b *Board) checkContiguous(x, y, tiles int, dir direction) bool {
 	return false
 }
 
+// fitsOnBoard reports whether tiles new tiles laid from (x, y) in dir, skipping
+// squares already filled, all land on the board. checkContiguous and
+// scoreMoveParts walk the line assuming they do and would index past the edge
+// otherwise.
+func (b *Board) fitsOnBoard(x, y, tiles int, dir direction) bool {
+	if x < 0 || x >= boardSize || y < 0 || y >= boardSize {
+		return false
+	}
+	for tiles > 0 && x < boardSize && y < boardSize {
+		if b.board[x][y] == 0 {
+			tiles--
+		}
+		if dir == DIR_VERT {
+			y++
+		} else {
+			x++
+		}
+	}
+	return tiles == 0
+}
+
+// hasNeighbor reports whether any orthogonal neighbor of (x, y) holds a tile.
+func (b *Board) hasNeighbor(x, y int) bool {
+	return (x > 0 && b.board[x-1][y] != 0) || (x < boardSize-1 && b.board[x+1][y] != 0) ||
+		(y > 0 && b.board[x][y-1] != 0) || (y < boardSize-1 && b.board[x][y+1] != 0)
+}
+
 func (b *Board) scoreWord(x, y int, dir direction, plays []byte) int {
 	points := 0
 	wordMult := 1
@@ -169,7 +941,7 @@ func (b *Board) scoreWord(x, y int, dir direction, plays []byte) int {
 	if dir == DIR_VERT {
 		for y2 = y; y2 > 0 && (plays[cti(x, y2-1)] != 0 || b.board[x][y2-1] != 0); y2-- {
 		}
-		for ; y2 < 15; y2++ {
+		for ; y2 < boardSize; y2++ {
 			idx := cti(x, y2)
 			if b.board[x][y2] != 0 {
 				wordLen++
@@ -193,7 +965,7 @@ func (b *Board) scoreWord(x, y int, dir direction, plays []byte) int {
 	} else {
 		for x2 = x; x2 > 0 && (plays[cti(x2-1, y)] != 0 || b.board[x2-1][y] != 0); x2-- {
 		}
-		for ; x2 < 15; x2++ {
+		for ; x2 < boardSize; x2++ {
 			idx := cti(x2, y)
 			if b.board[x2][y] != 0 {
 				wordLen++
@@ -222,16 +994,22 @@ func (b *Board) scoreWord(x, y int, dir direction, plays []byte) int {
 }
 
 func (b *Board) scoreMove(x, y int, tiles string, dir direction) int {
-	playPoints := 0
+	main, cross := b.scoreMoveParts(x, y, tiles, dir)
+	return main + cross
+}
+
+// scoreMoveParts scores a play split into the main word and the sum of all
+// cross-words it forms. Neither part includes the bingo bonus.
+func (b *Board) scoreMoveParts(x, y int, tiles string, dir direction) (main, cross int) {
 	tilei := 0
-	plays := make([]byte, 225)
+	plays := make([]byte, boardSize*boardSize)
 
 	if dir == DIR_VERT {
 		for i := y; len(tiles) > tilei; i++ {
 			if b.board[x][i] == 0 {
 				plays[cti(x, i)] = tiles[tilei]
 				tilei++
-				playPoints += b.scoreWord(x, i, DIR_HORIZ, plays)
+				cross += b.scoreWord(x, i, DIR_HORIZ, plays)
 			}
 		}
 	} else {
@@ -239,11 +1017,11 @@ func (b *Board) scoreMove(x, y int, tiles string, dir direction) int {
 			if b.board[i][y] == 0 {
 				plays[cti(i, y)] = tiles[tilei]
 				tilei++
-				playPoints += b.scoreWord(i, y, DIR_VERT, plays)
+				cross += b.scoreWord(i, y, DIR_VERT, plays)
 			}
 		}
 	}
-	return playPoints + b.scoreWord(x, y, dir, plays)
+	return b.scoreWord(x, y, dir, plays), cross
 }
 
 func (b *Board) getPlaySpace(x, y int, dir direction) (startX, startY int, play []byte, crossPlays [][]byte, room int) {
@@ -254,7 +1032,7 @@ func (b *Board) getPlaySpace(x, y int, dir direction) (startX, startY int, play
 			y--
 		}
 		startX, startY = x, y
-		for i := y; i < 15; i++ {
+		for i := y; i < boardSize; i++ {
 			play = append(play, b.board[x][i])
 			var crossPlay []byte
 			if b.board[x][i] == 0 {
@@ -263,7 +1041,7 @@ func (b *Board) getPlaySpace(x, y int, dir direction) (startX, startY int, play
 				for x2 > 0 && b.board[x2-1][i] != 0 {
 					x2--
 				}
-				for x3 < 14 && b.board[x3+1][i] != 0 {
+				for x3 < boardSize-1 && b.board[x3+1][i] != 0 {
 					x3++
 				}
 				if x2 < x3 {
@@ -279,7 +1057,7 @@ func (b *Board) getPlaySpace(x, y int, dir direction) (startX, startY int, play
 			x--
 		}
 		startX, startY = x, y
-		for i := x; i < 15; i++ {
+		for i := x; i < boardSize; i++ {
 			play = append(play, b.board[i][y])
 			var crossPlay []byte
 			if b.board[i][y] == 0 {
@@ -288,7 +1066,7 @@ func (b *Board) getPlaySpace(x, y int, dir direction) (startX, startY int, play
 				for y2 > 0 && b.board[i][y2-1] != 0 {
 					y2--
 				}
-				for y3 < 14 && b.board[i][y3+1] != 0 {
+				for y3 < boardSize-1 && b.board[i][y3+1] != 0 {
 					y3++
 				}
 				if y2 < y3 {
@@ -305,62 +1083,188 @@ func (b *Board) getPlaySpace(x, y int, dir direction) (startX, startY int, play
 
 func (b *Board) recordMove(placed []byte, anchorX, anchorY int, dir direction,
 	rackLen int, seen map[string]bool, moves *[]BestMove) {
+	if !b.fitsOnBoard(anchorX, anchorY, len(placed), dir) {
+		return
+	}
 	if !b.checkCenterPlayed(anchorX, anchorY, len(placed), dir) {
 		return
 	}
 	if !b.checkContiguous(anchorX, anchorY, len(placed), dir) {
 		return
 	}
+	if b.bingoOnly && !isBingo(rackLen, len(placed)) {
+		return
+	}
 	score := b.scoreMove(anchorX, anchorY, string(placed), dir)
-	if rackLen == 7 && len(placed) == 7 {
+	if isBingo(rackLen, len(placed)) {
 		score += bingoBonus
 	}
-	key := fmt.Sprintf("%d,%d,%d,%s", anchorX, anchorY, int(dir), strings.ToUpper(string(placed)))
+	key := moveKey(anchorX, anchorY, dir, string(placed))
 	if !seen[key] {
 		seen[key] = true
 		*moves = append(*moves, BestMove{x: anchorX, y: anchorY, dir: dir, tiles: string(placed), score: score})
 	}
 }
 
+// moveKey identifies a move by where its tiles go, treating a blank and the
+// real tile for the same letter alike.
+func moveKey(x, y int, dir direction, tiles string) string {
+	return fmt.Sprintf("%d,%d,%d,%s", x, y, int(dir), upperTiles(tiles))
+}
+
+// generateMoves runs the anchor search for rack over every empty square and
+// both directions, returning the moves unsorted. With a nil b.trie it falls
+// back to an unpruned search that checks each candidate word against
+// b.wordlist; results are identical but the search is far slower.
+func (b *Board) generateMoves(rack []byte) []BestMove {
+	var moves []BestMove
+	seen := make(map[string]bool)
+	rackLen := len(rack)
+	rackCopy := make([]byte, rackLen)
+	copy(rackCopy, rack)
+
+	for x := 0; x < boardSize; x++ {
+		for y := 0; y < boardSize; y++ {
+			if b.board[x][y] != 0 {
+				continue
+			}
+			if b.bingoOnly && len(moves) > 0 {
+				return moves
+			}
+			if !b.deadline.IsZero() && time.Now().After(b.deadline) {
+				return moves
+			}
+			for _, dir := range []direction{DIR_HORIZ, DIR_VERT} {
+				if (dir == DIR_HORIZ && b.onlyRows != nil && !b.onlyRows[y]) ||
+					(dir == DIR_VERT && b.onlyCols != nil && !b.onlyCols[x]) {
+					continue
+				}
+				startX, startY, play, crossPlays, room := b.getPlaySpace(x, y, dir)
+				if room == 0 {
+					continue
+				}
+				var offset int
+				if dir == DIR_HORIZ {
+					offset = x - startX
+				} else {
+					offset = y - startY
+				}
+				// Pre-walk trie through existing tiles before the anchor.
+				node := b.trie
+				valid := true
+				for i := 0; node != nil && i < offset; i++ {
+					idx := int(play[i]&^32) - int('A')
+					if idx < 0 || idx >= maxAlphabet || node.children[idx] == nil {
+						valid = false
+						break
+					}
+					node = node.children[idx]
+				}
+				if !valid {
+					continue
+				}
+				b.searchPlay(node, play, crossPlays, offset, rackCopy,
+					make([]byte, 0, rackSize), x, y, dir, rackLen, seen, &moves)
+			}
+		}
+	}
+	return moves
+}
+
+// moveGenPath names the move generator generateMoves will use, for logging.
+func (b *Board) moveGenPath() string {
+	if b.trie == nil {
+		return "wordlist fallback (no trie)"
+	}
+	return "trie"
+}
+
+// playFormsWord reports whether play[:n], with the empty slots filled from
+// placed in order, is in the wordlist. Used when there is no trie to say
+// whether the main word is complete.
+func (b *Board) playFormsWord(play []byte, n int, placed []byte) bool {
+	f := NewFNV()
+	pi := 0
+	for _, c := range play[:n] {
+		if c == 0 {
+			c = placed[pi]
+			pi++
+		}
+		f.Add(c)
+	}
+	_, ok := b.wordlist[f.Val()]
+	return ok
+}
+
+// searchPlay extends placed one square at a time from play[playIdx]. node is
+// the trie position for the letters so far, or nil when searching without a
+// trie, in which case every letter is tried and words are checked on stop.
+// In rack, '*' is a blank that can be any letter and a lowercase letter is a
+// blank already fixed to that letter.
 func (b *Board) searchPlay(node *TrieNode, play []byte, crossPlays [][]byte,
 	playIdx int, rack []byte, placed []byte,
 	anchorX, anchorY int, dir direction,
 	rackLen int, seen map[string]bool, moves *[]BestMove) {
 	// Can we record a word here? Only if the next position is not an existing tile we must include.
 	canStop := playIdx >= len(play) || play[playIdx] == 0
-	if canStop && node.isEnd && len(placed) > 0 {
-		b.recordMove(placed, anchorX, anchorY, dir, rackLen, seen, moves)
+	if canStop && len(placed) > 0 {
+		if (node != nil && node.isEnd) || (node == nil && b.playFormsWord(play, playIdx, placed)) {
+			b.recordMove(placed, anchorX, anchorY, dir, rackLen, seen, moves)
+		}
 	}
 	if playIdx >= len(play) || len(rack) == 0 {
 		return
 	}
-
+	if b.bingoOnly && len(*moves) > 0 {
+		return
+	}
 	curr := play[playIdx]
 	if curr != 0 {
 		// Existing tile on board: must follow this trie edge.
+		if node == nil {
+			b.searchPlay(nil, play, crossPlays, playIdx+1, rack, placed,
+				anchorX, anchorY, dir, rackLen, seen, moves)
+			return
+		}
 		idx := int(curr&^32) - int('A')
-		if idx >= 0 && idx < 26 && node.children[idx] != nil {
+		if idx >= 0 && idx < maxAlphabet && node.children[idx] != nil {
 			b.searchPlay(node.children[idx], play, crossPlays, playIdx+1, rack, placed,
 				anchorX, anchorY, dir, rackLen, seen, moves)
 		}
 		return
 	}
 
-	// Empty slot: try placing each rack tile here.
-	var tried [26]bool
+	// Empty slot: try placing each rack tile here, a '*' as every letter.
+	// The maxNewTiles cap applies only here, so a capped move can still run
+	// on through existing tiles.
+	if b.maxNewTiles > 0 && len(placed) >= b.maxNewTiles {
+		return
+	}
+	var tried [maxAlphabet]bool
 	for rackIdx := 0; rackIdx < len(rack); rackIdx++ {
 		t := rack[rackIdx]
 		isWild := t == '*'
-		for letter := byte('A'); letter <= 'Z'; letter++ {
-			if !isWild && (t&^32) != letter {
-				continue
-			}
+		isBlank := isWild || isBlankTile(t)
+		if isBlank && b.maxBlanks > 0 && countBlanks(placed) >= b.maxBlanks {
+			continue
+		}
+		first, last := t&^32, t&^32
+		if !isWild && (first < 'A' || first >= 'A'+maxAlphabet) {
+			continue
+		}
+		if isWild {
+			first, last = 'A', alphabet.lastTile()
+		}
+		for letter := first; letter <= last; letter++ {
 			if tried[letter-'A'] {
 				continue
 			}
-			child := node.children[letter-'A']
-			if child == nil {
-				continue
+			var child *TrieNode
+			if node != nil {
+				child = node.children[letter-'A']
+				if child == nil {
+					continue
+				}
 			}
 			// Cross-word check via FNV wordlist.
 			if crossPlays[playIdx] != nil {
@@ -378,7 +1282,7 @@ func (b *Board) searchPlay(node *TrieNode, play []byte, crossPlays [][]byte,
 			}
 			tried[letter-'A'] = true
 			stored := letter
-			if isWild {
+			if isBlank {
 				stored = letter + 32 // lowercase = blank tile on board
 			}
 			// Remove tile from rack (swap to end, shrink).
@@ -394,16 +1298,47 @@ func (b *Board) searchPlay(node *TrieNode, play []byte, crossPlays [][]byte,
 	}
 }
 
+// countBlanks returns how many of placed are blanks (lowercase).
+func countBlanks(placed []byte) int {
+	n := 0
+	for _, t := range placed {
+		if isBlankTile(t) {
+			n++
+		}
+	}
+	return n
+}
+
 // ── Ruleset loading ───────────────────────────────────────────────────────────
 
 type rulesetDef struct {
 	Name         string         `json:"name"`
 	BingoBonus   int            `json:"bingo_bonus"`
+	TargetScore  int            `json:"target_score,omitempty"`
+	BoardSize    int            `json:"board_size,omitempty"` // default 15, minBoardSize to maxBoardSize
+	RackSize     int            `json:"rack_size,omitempty"`  // default 7, at most maxRackLen
+	Center       *[2]int        `json:"center,omitempty"`     // default the middle square
 	LetterPoints map[string]int `json:"letter_points"`
 	TripleWord   [][2]int       `json:"triple_word"`
 	DoubleWord   [][2]int       `json:"double_word"`
 	TripleLetter [][2]int       `json:"triple_letter"`
 	DoubleLetter [][2]int       `json:"double_letter"`
+
+	// CenterDoubleWord makes the center square a double word (true) or
+	// plain (false) whatever double_word lists, so the opening play is
+	// doubled or not. Unset leaves it to double_word.
+	CenterDoubleWord *bool `json:"center_double_word,omitempty"`
+
+	// ChallengeRule is challengeLoseTurn (default) or challengeWithPenalty;
+	// ChallengePenalty is the penalty-rule deduction, default 5.
+	ChallengeRule    string `json:"challenge_rule,omitempty"`
+	ChallengePenalty int    `json:"challenge_penalty,omitempty"`
+
+	// Alphabet lists the tile letters in order, default A–Z (see
+	// Alphabet). CaseSensitive matches them exactly as written instead of
+	// upper-casing text first.
+	Alphabet      []string `json:"alphabet,omitempty"`
+	CaseSensitive bool     `json:"case_sensitive,omitempty"`
 }
 
 // loadRuleset reads config.json and rulesets.json and applies the selected
@@ -421,11 +1356,19 @@ func loadRuleset() string {
 
 	var cfg struct {
 		Ruleset string `json:"ruleset"`
+		Index   string `json:"index"`
 	}
 	if err := json.Unmarshal(cfgBytes, &cfg); err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: config.json is malformed (%v) — using crossplay defaults\n", err)
 		return defaultName
 	}
+	switch cfg.Index {
+	case "", "trie":
+	case "dawg":
+		dictIndex = cfg.Index
+	default:
+		fmt.Fprintf(os.Stderr, "Warning: unknown index %q in config.json — using the trie\n", cfg.Index)
+	}
 
 	rsBytes, err := os.ReadFile("rulesets.json")
 	if err != nil {
@@ -450,42 +1393,215 @@ func loadRuleset() string {
 		return defaultName
 	}
 
+	if err := def.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ruleset %q in rulesets.json: %v — using crossplay defaults\n", cfg.Ruleset, err)
+		return defaultName
+	}
 	applyRuleset(def)
 	return def.Name
 }
 
-func applyRuleset(def rulesetDef) {
-	if def.BingoBonus > 0 {
-		bingoBonus = def.BingoBonus
+// listRulesets returns the display names of every ruleset in rulesets.json,
+// sorted. A missing or malformed file yields an empty list.
+func listRulesets() []string {
+	var rulesets map[string]rulesetDef
+	if rsBytes, err := os.ReadFile("rulesets.json"); err == nil {
+		json.Unmarshal(rsBytes, &rulesets)
 	}
-	tilePoints = [255]int{}
-	for letter, pts := range def.LetterPoints {
-		if len(letter) == 1 {
-			tilePoints[letter[0]&^32] = pts // uppercase
+	names := make([]string, 0, len(rulesets))
+	for _, def := range rulesets {
+		names = append(names, def.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// nonDictionaryFiles are the .txt files in the working directory that hold
+// something other than a word list.
+var nonDictionaryFiles = map[string]bool{
+	"exclusions.txt":  true, // see loadExclusions
+	"definitions.txt": true, // see loadDefinitions
+}
+
+// listDictionaries returns the word lists in the working directory: every
+// top-level .txt file not in nonDictionaryFiles, sorted.
+func listDictionaries() []string {
+	matches, _ := filepath.Glob("*.txt")
+	dicts := make([]string, 0, len(matches))
+	for _, m := range matches {
+		if !nonDictionaryFiles[m] {
+			dicts = append(dicts, m)
+		}
+	}
+	return dicts
+}
+
+// findRuleset looks up a ruleset in rulesets.json by its key (as used in
+// config.json) or its display name.
+func findRuleset(name string) (rulesetDef, bool) {
+	var rulesets map[string]rulesetDef
+	if rsBytes, err := os.ReadFile("rulesets.json"); err == nil {
+		json.Unmarshal(rsBytes, &rulesets)
+	}
+	if def, ok := rulesets[name]; ok {
+		return def, true
+	}
+	for _, def := range rulesets {
+		if def.Name == name {
+			return def, true
+		}
+	}
+	return rulesetDef{}, false
+}
+
+// scoringTable is one ruleset's letter values and premium squares, so a
+// board can be scored under a ruleset other than the active one without
+// touching the globals.
+type scoringTable struct {
+	points         [255]int
+	tw, dw, tl, dl []bool // boardSize*boardSize long
+}
+
+// activeScoringTable snapshots the active ruleset's scoring globals.
+func activeScoringTable() scoringTable {
+	return scoringTable{points: tilePoints, tw: tw, dw: dw, tl: tl, dl: dl}
+}
+
+// size is the ruleset's board width and height: BoardSize, or 15 if unset.
+func (def rulesetDef) size() int {
+	if def.BoardSize > 0 {
+		return def.BoardSize
+	}
+	return 15
+}
+
+// centerSquare is the ruleset's center: Center, or the middle square.
+func (def rulesetDef) centerSquare() [2]int {
+	if def.Center != nil {
+		return *def.Center
+	}
+	n := def.size()
+	return [2]int{n / 2, n / 2}
+}
+
+// alphabet builds def's tile alphabet, English unless it lists letters.
+func (def rulesetDef) alphabet() (*Alphabet, error) {
+	if def.Alphabet == nil {
+		if def.CaseSensitive {
+			return newAlphabet(englishAlphabet.letters, true)
+		}
+		return englishAlphabet, nil
+	}
+	return newAlphabet(def.Alphabet, def.CaseSensitive)
+}
+
+// validate checks that the center and every premium square lie on the
+// ruleset's board. scoringTable and applyRuleset assume it passed: an x of n
+// would wrap onto the next row, and anything past the last square panics.
+func (def rulesetDef) validate() error {
+	n := def.size()
+	if def.BoardSize != 0 && (def.BoardSize < minBoardSize || def.BoardSize > maxBoardSize) {
+		return fmt.Errorf("board_size %d must be %d to %d", def.BoardSize, minBoardSize, maxBoardSize)
+	}
+	if def.RackSize < 0 || def.RackSize > maxRackLen {
+		return fmt.Errorf("rack_size %d must be 1 to %d", def.RackSize, maxRackLen)
+	}
+	onBoard := func(pos [2]int) bool {
+		return pos[0] >= 0 && pos[0] < n && pos[1] >= 0 && pos[1] < n
+	}
+	if def.Center != nil && !onBoard(*def.Center) {
+		return fmt.Errorf("center %v is off the %d×%d board", *def.Center, n, n)
+	}
+	a, err := def.alphabet()
+	if err != nil {
+		return err
+	}
+	for letter := range def.LetterPoints {
+		if tiles, ok := a.encode(letter); !ok || len(tiles) != 1 {
+			return fmt.Errorf("letter_points letter %q is not in the alphabet", letter)
+		}
+	}
+	for _, premium := range []struct {
+		name string
+		list [][2]int
+	}{
+		{"triple_word", def.TripleWord},
+		{"double_word", def.DoubleWord},
+		{"triple_letter", def.TripleLetter},
+		{"double_letter", def.DoubleLetter},
+	} {
+		for _, pos := range premium.list {
+			if !onBoard(pos) {
+				return fmt.Errorf("%s square %v is off the %d×%d board", premium.name, pos, n, n)
+			}
 		}
 	}
-	tw = [225]bool{}
+	return nil
+}
+
+// scoringTable builds def's scoring table. def must pass validate.
+func (def rulesetDef) scoringTable() scoringTable {
+	n := def.size()
+	t := scoringTable{tw: make([]bool, n*n), dw: make([]bool, n*n), tl: make([]bool, n*n), dl: make([]bool, n*n)}
+	a, _ := def.alphabet()
+	for letter, pts := range def.LetterPoints {
+		tiles, _ := a.encode(letter)
+		t.points[tiles[0]&^32] = pts // uppercase
+	}
+	// Indexed like cti, but for this ruleset's size rather than the active one.
 	for _, pos := range def.TripleWord {
-		tw[cti(pos[0], pos[1])] = true
+		t.tw[pos[1]*n+pos[0]] = true
 	}
-	dw = [225]bool{}
 	for _, pos := range def.DoubleWord {
-		dw[cti(pos[0], pos[1])] = true
+		t.dw[pos[1]*n+pos[0]] = true
+	}
+	if def.CenterDoubleWord != nil {
+		c := def.centerSquare()
+		t.dw[c[1]*n+c[0]] = *def.CenterDoubleWord
 	}
-	tl = [225]bool{}
 	for _, pos := range def.TripleLetter {
-		tl[cti(pos[0], pos[1])] = true
+		t.tl[pos[1]*n+pos[0]] = true
 	}
-	dl = [225]bool{}
 	for _, pos := range def.DoubleLetter {
-		dl[cti(pos[0], pos[1])] = true
+		t.dl[pos[1]*n+pos[0]] = true
+	}
+	return t
+}
+
+// applyRuleset makes def the active ruleset. def must pass validate.
+func applyRuleset(def rulesetDef) {
+	if def.BingoBonus > 0 {
+		bingoBonus = def.BingoBonus
+	}
+	targetScore = def.TargetScore
+	alphabet, _ = def.alphabet()
+	boardSize = def.size()
+	center = def.centerSquare()
+	rackSize = 7
+	if def.RackSize > 0 {
+		rackSize = def.RackSize
+	}
+	t := def.scoringTable()
+	tilePoints, tw, dw, tl, dl = t.points, t.tw, t.dw, t.tl, t.dl
+	switch def.ChallengeRule {
+	case "", challengeLoseTurn:
+		challengeRule = challengeLoseTurn
+	case challengeWithPenalty:
+		challengeRule = challengeWithPenalty
+	default:
+		fmt.Fprintf(os.Stderr, "Warning: unknown challenge_rule %q — using %q\n", def.ChallengeRule, challengeLoseTurn)
+		challengeRule = challengeLoseTurn
+	}
+	challengePenalty = 5
+	if def.ChallengePenalty > 0 {
+		challengePenalty = def.ChallengePenalty
 	}
 }
 
 func (b *Board) PrintBoard() {
-	for y := 0; y < 15; y++ {
+	for y := 0; y < boardSize; y++ {
 		line := ""
-		for x := 0; x < 15; x++ {
+		for x := 0; x < boardSize; x++ {
 			if b.board[x][y] == 0 {
 				if dw[cti(x, y)] {
 					line += "\x1b[31;1m"
@@ -498,13 +1614,14 @@ func (b *Board) PrintBoard() {
 				}
 				line += "."
 			} else {
-				line += string(b.board[x][y])
+				line += alphabet.decode(string(b.board[x][y]))
 			}
 			line += "\x1b[0m "
 		}
 		fmt.Println(line)
 	}
 	fmt.Println()
-	fmt.Println("Player 1:", b.pscore[0])
-	fmt.Println("Player 2:", b.pscore[1])
+	for p, score := range b.pscore {
+		fmt.Printf("Player %d: %d\n", p+1, score)
+	}
 }
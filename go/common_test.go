@@ -0,0 +1,325 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRulesetValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		def  rulesetDef
+		ok   bool
+	}{
+		{"defaults", rulesetDef{TripleWord: [][2]int{{0, 0}, {14, 14}}}, true},
+		{"21x21 corner", rulesetDef{BoardSize: 21, DoubleWord: [][2]int{{20, 20}}}, true},
+		{"x wraps a row", rulesetDef{TripleLetter: [][2]int{{15, 3}}}, false},
+		{"past the last square", rulesetDef{DoubleLetter: [][2]int{{3, 15}}}, false},
+		{"negative", rulesetDef{TripleWord: [][2]int{{-1, 0}}}, false},
+		{"center off board", rulesetDef{BoardSize: 11, Center: &[2]int{11, 5}}, false},
+		{"smallest board", rulesetDef{BoardSize: 5}, true},
+		{"largest board", rulesetDef{BoardSize: 26, TripleWord: [][2]int{{25, 25}}}, true},
+		{"board too small", rulesetDef{BoardSize: 4}, false},
+		{"board too large", rulesetDef{BoardSize: 27}, false},
+		{"negative board", rulesetDef{BoardSize: -15}, false},
+	}
+	for _, tt := range tests {
+		err := tt.def.validate()
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: validate() = %v, want ok=%t", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestListDictionariesSkipsOtherTxt(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"dictionary.txt", "collins.txt", "exclusions.txt", "definitions.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+	got := listDictionaries()
+	want := []string{"collins.txt", "dictionary.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listDictionaries() = %v, want %v", got, want)
+	}
+}
+
+func TestTrieCacheMatchesParsedTrie(t *testing.T) {
+	dict := writeDict(t, testWords)
+	excluded := map[uint64]struct{}{wordHash("TAX"): {}}
+	fresh, err := parseTrie(dict, alphabet, excluded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := trieCachePath(dict)
+	if err := saveTrieCache(cache, fresh, alphabet, excluded); err != nil {
+		t.Fatal(err)
+	}
+	// The cache must be newer than the dictionary to be used.
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(cache, later, later); err != nil {
+		t.Fatal(err)
+	}
+	cached, err := loadTrieCache(cache, dict, alphabet, excluded)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if got, want := countTrieNodes(cached), countTrieNodes(fresh); got != want {
+		t.Errorf("cached trie has %d nodes, fresh %d", got, want)
+	}
+	probes := append([]string{"TAX", "CA", "CATZ", "CARTSS", "Z"}, testWords...)
+	for _, w := range probes {
+		if got, want := trieContains(cached, w), trieContains(fresh, w); got != want {
+			t.Errorf("%s: cached trie contains = %v, fresh = %v", w, got, want)
+		}
+	}
+
+	if _, err := loadTrieCache(cache, dict, alphabet, nil); err == nil {
+		t.Error("cache built with exclusions loaded without them")
+	}
+	if _, err := loadTrieCache(cache, dict, greekAlphabet(t, false), excluded); err == nil {
+		t.Error("cache built for one alphabet loaded for another")
+	}
+}
+
+func TestRecordMoveRejectsPlayPastRightEdge(t *testing.T) {
+	b := newTestBoard(t)
+	place(b, "CAT", 6, 7, DIR_HORIZ)
+	place(b, "EAT", 12, 7, DIR_HORIZ) // runs to the right edge
+
+	// From (11, 7) only one empty square is left before the edge.
+	if !b.fitsOnBoard(11, 7, 1, DIR_HORIZ) || b.fitsOnBoard(11, 7, 2, DIR_HORIZ) {
+		t.Error("fitsOnBoard(11, 7, H): want 1 tile to fit and 2 not to")
+	}
+	var moves []BestMove
+	b.recordMove([]byte("RS"), 11, 7, DIR_HORIZ, rackSize, map[string]bool{}, &moves)
+	if len(moves) != 0 {
+		t.Errorf("recorded a play past the edge: %+v", moves)
+	}
+
+	for _, m := range allMoves(t, b, []byte("AERST")) {
+		if got := len(newTilePositions(b.board, m)); got != len(m.tiles) {
+			t.Errorf("move %s lands %d of %d tiles on the board", moveKey(m.x, m.y, m.dir, m.tiles), got, len(m.tiles))
+		}
+	}
+}
+
+// greekAlphabet is the Greek capitals plus iota with tonos (Ί) and with
+// dialytika (Ϊ) as letters of their own.
+func greekAlphabet(t *testing.T, caseSensitive bool) *Alphabet {
+	t.Helper()
+	var letters []string
+	for _, r := range "ΑΒΓΔΕΖΗΘΙΚΛΜΝΞΟΠΡΣΤΥΦΧΨΩΊΪ" {
+		letters = append(letters, string(r))
+	}
+	a, err := newAlphabet(letters, caseSensitive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// useAlphabet makes a the active alphabet for the rest of the test.
+func useAlphabet(t *testing.T, a *Alphabet) {
+	saved := alphabet
+	t.Cleanup(func() { alphabet = saved })
+	alphabet = a
+}
+
+// useRuleset applies the rulesets.json ruleset key for the rest of the test,
+// with def's fields overridden by edit if it is non-nil.
+func useRuleset(t *testing.T, key string, edit func(*rulesetDef)) {
+	t.Helper()
+	def, ok := findRuleset(key)
+	if !ok {
+		t.Fatalf("ruleset %q not in rulesets.json", key)
+	}
+	if edit != nil {
+		edit(&def)
+	}
+	if err := def.validate(); err != nil {
+		t.Fatal(err)
+	}
+	savedBingo, savedTarget, savedAlphabet, savedSize, savedCenter, savedRack := bingoBonus, targetScore, alphabet, boardSize, center, rackSize
+	savedPoints, savedTW, savedDW, savedTL, savedDL := tilePoints, tw, dw, tl, dl
+	savedRule, savedPenalty := challengeRule, challengePenalty
+	t.Cleanup(func() {
+		bingoBonus, targetScore, alphabet, boardSize, center, rackSize = savedBingo, savedTarget, savedAlphabet, savedSize, savedCenter, savedRack
+		tilePoints, tw, dw, tl, dl = savedPoints, savedTW, savedDW, savedTL, savedDL
+		challengeRule, challengePenalty = savedRule, savedPenalty
+	})
+	applyRuleset(def)
+}
+
+func TestStandardCenterDoublesOpening(t *testing.T) {
+	useRuleset(t, "scrabble", nil)
+	b := newTestBoard(t)
+	doubled := b.scoreMove(6, 7, "CAT", DIR_HORIZ)
+
+	useRuleset(t, "scrabble", func(def *rulesetDef) {
+		plain := false
+		def.CenterDoubleWord = &plain
+	})
+	if plain := b.scoreMove(6, 7, "CAT", DIR_HORIZ); doubled != 2*plain || plain == 0 {
+		t.Errorf("opening CAT through the center scores %d, %d without the star; want double", doubled, plain)
+	}
+
+	useRuleset(t, "crossplay", nil)
+	if dw[cti(center[0], center[1])] {
+		t.Error("crossplay's center star doubles the word")
+	}
+
+	// The star follows a moved center.
+	useRuleset(t, "scrabble", func(def *rulesetDef) { def.Center = &[2]int{3, 7} })
+	if !dw[cti(3, 7)] || dw[cti(7, 7)] {
+		t.Errorf("moved center: dw at (3,7) = %v, (7,7) = %v; want true, false", dw[cti(3, 7)], dw[cti(7, 7)])
+	}
+}
+
+func TestCaseSensitiveDictionary(t *testing.T) {
+	useAlphabet(t, greekAlphabet(t, true))
+	// Greek iota with tonos (CE 8A) and with dialytika (CE AA) differ only
+	// in the bit FNV.Add folds, but they are separate tiles.
+	const tonos, dialytika = "ΑΊΤΟΣ", "ΑΪΤΟΣ"
+	tonosTiles, ok1 := alphabet.encode(tonos)
+	dialytikaTiles, ok2 := alphabet.encode(dialytika)
+	if !ok1 || !ok2 || tonosTiles == dialytikaTiles {
+		t.Fatalf("encode: %q %v, %q %v; want two different words", tonosTiles, ok1, dialytikaTiles, ok2)
+	}
+
+	both, err := loadDictionary(writeDict(t, []string{tonos, dialytika}), alphabet, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(both) != 2 {
+		t.Errorf("case-sensitive wordlist has %d entries, want 2", len(both))
+	}
+	for _, w := range []string{tonosTiles, dialytikaTiles} {
+		if _, ok := both[wordHash(w)]; !ok {
+			t.Errorf("%s not valid", alphabet.decode(w))
+		}
+	}
+	one, err := loadDictionary(writeDict(t, []string{tonos}), alphabet, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := one[wordHash(dialytikaTiles)]; ok {
+		t.Errorf("%s accepted on the strength of %s", dialytika, tonos)
+	}
+	trie, err := parseTrie(writeDict(t, []string{tonos}), alphabet, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !trieContains(trie, tonosTiles) || trieContains(trie, dialytikaTiles) {
+		t.Errorf("trie holds %s = %v, %s = %v; want only the first", tonos,
+			trieContains(trie, tonosTiles), dialytika, trieContains(trie, dialytikaTiles))
+	}
+
+	// Case-sensitive: lower case is not a letter. Otherwise it is the blank.
+	if _, ok := alphabet.encode("αίτος"); ok {
+		t.Error("case-sensitive alphabet read lower-case letters")
+	}
+	folding := greekAlphabet(t, false)
+	blanks, ok := folding.encode("αίτια")
+	if upper, _ := folding.encode("ΑΊΤΙΑ"); !ok || upperTiles(blanks) != upper || blanks == upper {
+		t.Errorf("case-folding encode(αίτια) = %q, %v; want the blanks of %q", blanks, ok, upper)
+	}
+	if got := folding.decode(blanks); got != "αίτια" {
+		t.Errorf("decode = %q, want αίτια", got)
+	}
+	// Lowercase ASCII still marks a blank and matches the real tile.
+	if wordHash("CaT") != wordHash("CAT") {
+		t.Error("blank a hashes differently from A")
+	}
+}
+
+func TestNewAlphabetRejects(t *testing.T) {
+	for _, letters := range [][]string{
+		nil,
+		{"A", "B", "A"},
+		{"A", "CH"},
+		{"B"},
+		make([]string, maxAlphabet+1),
+	} {
+		if _, err := newAlphabet(letters, false); err == nil {
+			t.Errorf("newAlphabet(%q) accepted", letters)
+		}
+	}
+}
+
+func TestBoardTransforms(t *testing.T) {
+	b := newTestBoard(t)
+	place(b, "CAT", 2, 1, DIR_HORIZ) // C at (2, 1)
+	n := boardSize - 1
+	for op, want := range map[string][2]int{
+		"rotate90":  {n - 1, 2},
+		"rotate180": {n - 2, n - 1},
+		"rotate270": {1, n - 2},
+		"flipH":     {n - 2, 1},
+		"flipV":     {2, n - 1},
+	} {
+		got := boardTransforms[op](b.board)
+		if got[want[0]][want[1]] != 'C' {
+			t.Errorf("%s: C not at %v", op, want)
+		}
+	}
+	twice := boardTransforms["rotate180"](boardTransforms["rotate180"](b.board))
+	if !reflect.DeepEqual(twice, b.board) {
+		t.Error("rotate180 twice is not the original board")
+	}
+	if !reflect.DeepEqual(boardTransforms["rotate270"](rotateBoard90(b.board)), b.board) {
+		t.Error("rotate270 does not undo rotate90")
+	}
+}
+
+func TestLongestPlayable(t *testing.T) {
+	trie := newTestBoard(t).trie
+	tests := []struct {
+		rack, want string
+	}{
+		{"CARE", "CARE"},
+		// The blank reaches five letters; CARES is the first of them.
+		{"CARE*", "CAREs"},
+		{"QQ", ""},
+		{"**", "ar"},
+	}
+	for _, tt := range tests {
+		if got := longestPlayable([]byte(tt.rack), trie); got != tt.want {
+			t.Errorf("longestPlayable(%s) = %q, want %q", tt.rack, got, tt.want)
+		}
+	}
+}
+
+func TestBingoStems(t *testing.T) {
+	b := newWordsBoard(t, []string{
+		"RETAINS", "STAINER", "NASTIER", "RETINAS", "ASTERID", "TIRADES",
+		"REALIST", "SALTIER", "SATIRE", "CAT",
+	})
+	// SATIRE is a productive stem: N, D and L each complete it.
+	stems, truncated := bingoStems([]byte("AEIRST"), b.trie, 10)
+	want := map[byte][]string{
+		'N': {"NASTIER", "RETAINS", "RETINAS", "STAINER"},
+		'D': {"ASTERID", "TIRADES"},
+		'L': {"REALIST", "SALTIER"},
+	}
+	if truncated || !reflect.DeepEqual(stems, want) {
+		t.Errorf("stems of AEIRST = %q (truncated %v), want %q", stems, truncated, want)
+	}
+
+	stems, truncated = bingoStems([]byte("AEIRST"), b.trie, 2)
+	if !truncated || len(stems['N']) != 2 || len(stems['D']) != 2 {
+		t.Errorf("capped at 2: %q (truncated %v)", stems, truncated)
+	}
+
+	// A blank for the T still finds the N words.
+	stems, _ = bingoStems([]byte("AEIRS*"), b.trie, 10)
+	if !reflect.DeepEqual(stems['N'], want['N']) {
+		t.Errorf("stems of AEIRS* for N = %q, want %q", stems['N'], want['N'])
+	}
+}
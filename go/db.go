@@ -4,11 +4,17 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -25,17 +31,110 @@ type BoardMeta struct {
 
 type BoardRecord struct {
 	BoardMeta
-	Board []string `json:"board"` // 15 rows of 15 chars
+	Board []string `json:"board"` // n rows of n chars (n is the size it was saved under)
 }
 
+// Annotations are a coach's teaching marks on a board. The server stores and
+// returns them as-is; rendering is up to the client. Coordinates are (x, y).
+type Annotations struct {
+	Notes  []AnnotationNote  `json:"notes"`
+	Arrows []AnnotationArrow `json:"arrows"`
+}
+
+type AnnotationNote struct {
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+	Text string `json:"text"`
+}
+
+type AnnotationArrow struct {
+	From [2]int `json:"from"`
+	To   [2]int `json:"to"`
+}
+
+// BoardSnapshot is a saved version of a board's grid and annotations that
+// can be restored later.
+type BoardSnapshot struct {
+	ID        int64     `json:"id"`
+	Label     string    `json:"label"`
+	Board     []string  `json:"board"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// MoveRecord is one play in a board's move log. (X, Y) is the first new
+// tile and Tiles the new tiles only (lowercase = blank), as in a BestMove.
+// Player is 1 or 2.
+type MoveRecord struct {
+	Seq       int       `json:"seq"`
+	X         int       `json:"x"`
+	Y         int       `json:"y"`
+	Dir       string    `json:"dir"`
+	Tiles     string    `json:"tiles"`
+	Score     int       `json:"score"`
+	Player    int       `json:"player"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// moveRecord describes m, played by player, as a MoveRecord without Seq or
+// CreatedAt.
+func moveRecord(m BestMove, player int) MoveRecord {
+	dir := "H"
+	if m.dir == DIR_VERT {
+		dir = "V"
+	}
+	return MoveRecord{X: m.x, Y: m.y, Dir: dir, Tiles: m.tiles, Score: m.score, Player: player}
+}
+
+// BoardAccess is one entry in a board's access log. UserID is nil for
+// unauthenticated viewers of a shared link.
+type BoardAccess struct {
+	UserID     *string   `json:"userId,omitempty"`
+	Shared     bool      `json:"shared"`
+	AccessedAt time.Time `json:"accessedAt"`
+}
+
+// LeaderboardEntry is one verified high-scoring play. (X, Y) is the start of
+// Word, as in a game transcript.
+type LeaderboardEntry struct {
+	ID          int64     `json:"id"`
+	BoardHash   string    `json:"boardHash"`
+	X           int       `json:"x"`
+	Y           int       `json:"y"`
+	Dir         string    `json:"dir"`
+	Word        string    `json:"word"`
+	Score       int       `json:"score"`
+	Username    *string   `json:"username,omitempty"`
+	SubmittedAt time.Time `json:"submittedAt"`
+}
+
+// BoardLock is an explicit edit lock on a board, held by UserID until
+// ExpiresAt.
+type BoardLock struct {
+	UserID    string    `json:"userId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// errBoardLocked is returned by AcquireLock when another user holds an
+// unexpired lock.
+var errBoardLocked = errors.New("board is locked")
+
 // ── Database ─────────────────────────────────────────────────────────────────
 
 type DB struct {
 	pool *pgxpool.Pool
 }
 
+// NewDB connects to PostgreSQL. Pool sizing comes from the environment (see
+// applyPoolEnv); unset variables keep pgxpool's defaults.
 func NewDB(ctx context.Context, connStr string) (*DB, error) {
-	pool, err := pgxpool.New(ctx, connStr)
+	cfg, err := pgxpool.ParseConfig(connStr)
+	if err != nil {
+		return nil, fmt.Errorf("parse database URL: %w", err)
+	}
+	if err := applyPoolEnv(cfg); err != nil {
+		return nil, err
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("connect to database: %w", err)
 	}
@@ -46,11 +145,43 @@ func NewDB(ctx context.Context, connStr string) (*DB, error) {
 	return &DB{pool: pool}, nil
 }
 
+// applyPoolEnv sets pool limits from DB_MAX_CONNS, DB_MIN_CONNS (integers)
+// and DB_MAX_CONN_IDLE (a duration such as "5m"). It rejects values outside
+// sane bounds rather than letting a typo starve or flood the server.
+func applyPoolEnv(cfg *pgxpool.Config) error {
+	if v := os.Getenv("DB_MAX_CONNS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			return fmt.Errorf("DB_MAX_CONNS must be an integer between 1 and 1000, got %q", v)
+		}
+		cfg.MaxConns = int32(n)
+	}
+	if v := os.Getenv("DB_MIN_CONNS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > 1000 {
+			return fmt.Errorf("DB_MIN_CONNS must be an integer between 0 and 1000, got %q", v)
+		}
+		cfg.MinConns = int32(n)
+	}
+	if cfg.MinConns > cfg.MaxConns {
+		return fmt.Errorf("DB_MIN_CONNS (%d) must not exceed DB_MAX_CONNS (%d)", cfg.MinConns, cfg.MaxConns)
+	}
+	if v := os.Getenv("DB_MAX_CONN_IDLE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("DB_MAX_CONN_IDLE must be a positive duration such as 5m, got %q", v)
+		}
+		cfg.MaxConnIdleTime = d
+	}
+	return nil
+}
+
 func (d *DB) Close() {
 	d.pool.Close()
 }
 
-// Migrate creates the boards table and indexes if they don't already exist.
+// Migrate creates the boards, board_access, board_snapshots, board_locks,
+// board_moves and leaderboard tables and indexes if they don't already exist.
 func (d *DB) Migrate(ctx context.Context) error {
 	_, err := d.pool.Exec(ctx, `
 		CREATE TABLE IF NOT EXISTS boards (
@@ -64,6 +195,59 @@ func (d *DB) Migrate(ctx context.Context) error {
 		);
 		CREATE INDEX IF NOT EXISTS idx_boards_user_id ON boards(user_id);
 		CREATE INDEX IF NOT EXISTS idx_boards_share_token ON boards(share_token);
+		ALTER TABLE boards ADD COLUMN IF NOT EXISTS board_annotations JSONB;
+
+		CREATE TABLE IF NOT EXISTS board_access (
+			id          BIGSERIAL PRIMARY KEY,
+			board_id    UUID NOT NULL REFERENCES boards(id) ON DELETE CASCADE,
+			user_id     TEXT,
+			shared      BOOLEAN NOT NULL DEFAULT FALSE,
+			accessed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_board_access_board_id ON board_access(board_id, accessed_at DESC);
+
+		CREATE TABLE IF NOT EXISTS board_snapshots (
+			id                BIGSERIAL PRIMARY KEY,
+			board_id          UUID NOT NULL REFERENCES boards(id) ON DELETE CASCADE,
+			label             TEXT NOT NULL,
+			board_data        TEXT NOT NULL,
+			board_annotations JSONB,
+			created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_board_snapshots_board_id ON board_snapshots(board_id, created_at DESC);
+
+		CREATE TABLE IF NOT EXISTS board_moves (
+			board_id   UUID NOT NULL REFERENCES boards(id) ON DELETE CASCADE,
+			seq        INT NOT NULL,
+			x          INT NOT NULL,
+			y          INT NOT NULL,
+			dir        TEXT NOT NULL,
+			tiles      TEXT NOT NULL,
+			score      INT NOT NULL,
+			player     INT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (board_id, seq)
+		);
+
+		CREATE TABLE IF NOT EXISTS board_locks (
+			board_id   UUID PRIMARY KEY REFERENCES boards(id) ON DELETE CASCADE,
+			user_id    TEXT NOT NULL,
+			expires_at TIMESTAMPTZ NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS leaderboard (
+			id           BIGSERIAL PRIMARY KEY,
+			board_hash   TEXT NOT NULL,
+			x            INT NOT NULL,
+			y            INT NOT NULL,
+			dir          TEXT NOT NULL,
+			word         TEXT NOT NULL,
+			score        INT NOT NULL,
+			username     TEXT,
+			submitted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			UNIQUE (board_hash, x, y, dir, word)
+		);
+		CREATE INDEX IF NOT EXISTS idx_leaderboard_score ON leaderboard(score DESC, submitted_at);
 	`)
 	return err
 }
@@ -105,6 +289,26 @@ func (d *DB) ListBoards(ctx context.Context, userID string) ([]BoardMeta, error)
 	return boards, rows.Err()
 }
 
+// errBoardShape marks stored board_data that isn't a square grid.
+var errBoardShape = errors.New("stored board is malformed")
+
+// splitBoardData splits board_data into its rows. A board keeps the size it
+// was saved under: the row count is its size and every row must be that
+// wide. The GET handlers compare it with boardSize and report a mismatch as
+// sizeMismatch. A single trailing newline is tolerated; any other shape is
+// reported as errBoardShape rather than padded or truncated, so corrupt rows
+// surface instead of being hidden.
+func splitBoardData(data string) ([]string, error) {
+	rows := strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+	for i, row := range rows {
+		if len(row) != len(rows) {
+			return nil, fmt.Errorf("%w: row %d has %d columns, want %d to match its %d rows",
+				errBoardShape, i, len(row), len(rows), len(rows))
+		}
+	}
+	return rows, nil
+}
+
 // GetBoard loads a board by ID. No ownership check — caller decides access.
 func (d *DB) GetBoard(ctx context.Context, id string) (*BoardRecord, error) {
 	var b BoardRecord
@@ -117,12 +321,9 @@ func (d *DB) GetBoard(ctx context.Context, id string) (*BoardRecord, error) {
 		return nil, err
 	}
 
-	b.Board = strings.Split(boardData, "\n")
-	// Ensure exactly 15 rows
-	for len(b.Board) < 15 {
-		b.Board = append(b.Board, "...............")
+	if b.Board, err = splitBoardData(boardData); err != nil {
+		return nil, err
 	}
-	b.Board = b.Board[:15]
 	return &b, nil
 }
 
@@ -137,11 +338,9 @@ func (d *DB) GetBoardByShareToken(ctx context.Context, token string) (*BoardReco
 	if err != nil {
 		return nil, err
 	}
-	b.Board = strings.Split(boardData, "\n")
-	for len(b.Board) < 15 {
-		b.Board = append(b.Board, "...............")
+	if b.Board, err = splitBoardData(boardData); err != nil {
+		return nil, err
 	}
-	b.Board = b.Board[:15]
 	return &b, nil
 }
 
@@ -176,9 +375,9 @@ func (d *DB) SaveBoard(ctx context.Context, id string, userID string, boardRows
 
 // CreateBoard inserts a new blank board and returns its ID.
 func (d *DB) CreateBoard(ctx context.Context, name string, userID string) (string, error) {
-	blankRows := make([]string, 15)
+	blankRows := make([]string, boardSize)
 	for i := range blankRows {
-		blankRows[i] = "..............."
+		blankRows[i] = strings.Repeat(".", boardSize)
 	}
 	boardData := strings.Join(blankRows, "\n")
 
@@ -198,6 +397,25 @@ func (d *DB) CreateBoard(ctx context.Context, name string, userID string) (strin
 	return id, err
 }
 
+// CloneBoard copies srcID's board data into a new board named newName and
+// returns its ID. The clone is owned by userID (no owner when empty) and
+// starts without a share token, annotations or move log. No ownership check —
+// the caller decides who may clone.
+func (d *DB) CloneBoard(ctx context.Context, srcID, userID, newName string) (string, error) {
+	var uid *string
+	if userID != "" {
+		uid = &userID
+	}
+	var id string
+	err := d.pool.QueryRow(ctx,
+		`INSERT INTO boards (name, user_id, board_data)
+			SELECT $1, $2, board_data FROM boards WHERE id = $3
+			RETURNING id`,
+		newName, uid, srcID,
+	).Scan(&id)
+	return id, err
+}
+
 // DeleteBoard removes a board. Checks ownership via userID.
 // Anonymous users (empty userID) can only delete boards with no owner.
 func (d *DB) DeleteBoard(ctx context.Context, id string, userID string) error {
@@ -271,6 +489,134 @@ func (d *DB) GetShareToken(ctx context.Context, id string, userID string) (*stri
 	return token, nil
 }
 
+// ── Annotations ──────────────────────────────────────────────────────────────
+
+// GetAnnotations loads a board's annotations. No ownership check — caller
+// decides access. A board without annotations yields empty lists.
+func (d *DB) GetAnnotations(ctx context.Context, id string) (*Annotations, error) {
+	var raw []byte
+	err := d.pool.QueryRow(ctx,
+		`SELECT board_annotations FROM boards WHERE id = $1`, id,
+	).Scan(&raw)
+	if err != nil {
+		return nil, err
+	}
+	a := &Annotations{}
+	if raw != nil {
+		if err := json.Unmarshal(raw, a); err != nil {
+			return nil, fmt.Errorf("decode annotations: %w", err)
+		}
+	}
+	if a.Notes == nil {
+		a.Notes = []AnnotationNote{}
+	}
+	if a.Arrows == nil {
+		a.Arrows = []AnnotationArrow{}
+	}
+	return a, nil
+}
+
+// SaveAnnotations replaces a board's annotations. Checks ownership via userID.
+// Anonymous users (empty userID) can only annotate boards with no owner.
+func (d *DB) SaveAnnotations(ctx context.Context, id string, userID string, a Annotations) error {
+	raw, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	var n int64
+	if userID != "" {
+		tag, e := d.pool.Exec(ctx,
+			`UPDATE boards SET board_annotations = $1, updated_at = NOW()
+				WHERE id = $2 AND user_id = $3`,
+			raw, id, userID)
+		n, err = tag.RowsAffected(), e
+	} else {
+		tag, e := d.pool.Exec(ctx,
+			`UPDATE boards SET board_annotations = $1, updated_at = NOW()
+				WHERE id = $2 AND user_id IS NULL`,
+			raw, id)
+		n, err = tag.RowsAffected(), e
+	}
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("board not found")
+	}
+	return nil
+}
+
+// checkOwner returns an error unless board id exists and belongs to userID
+// (or is unowned, for an empty userID).
+func (d *DB) checkOwner(ctx context.Context, id string, userID string) error {
+	var exists bool
+	var err error
+	if userID != "" {
+		err = d.pool.QueryRow(ctx,
+			`SELECT EXISTS(SELECT 1 FROM boards WHERE id = $1 AND user_id = $2)`,
+			id, userID).Scan(&exists)
+	} else {
+		err = d.pool.QueryRow(ctx,
+			`SELECT EXISTS(SELECT 1 FROM boards WHERE id = $1 AND user_id IS NULL)`,
+			id).Scan(&exists)
+	}
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("board not found")
+	}
+	return nil
+}
+
+// ── Access log ───────────────────────────────────────────────────────────────
+
+// LogBoardAccess records a view of a board in the background. It never blocks
+// the caller and failures are dropped — the access log is best-effort.
+// An empty userID is stored as NULL (anonymous shared-link viewer).
+func (d *DB) LogBoardAccess(boardID string, userID string, shared bool) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		var uid *string
+		if userID != "" {
+			uid = &userID
+		}
+		d.pool.Exec(ctx,
+			`INSERT INTO board_access (board_id, user_id, shared) VALUES ($1, $2, $3)`,
+			boardID, uid, shared)
+	}()
+}
+
+// ListBoardAccess returns the most recent accesses to a board, newest first.
+// Only the owner may read the log; anonymous users (empty userID) can only
+// read the log of boards with no owner.
+func (d *DB) ListBoardAccess(ctx context.Context, id string, userID string, limit int) ([]BoardAccess, error) {
+	if err := d.checkOwner(ctx, id, userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := d.pool.Query(ctx,
+		`SELECT user_id, shared, accessed_at FROM board_access
+			WHERE board_id = $1 ORDER BY accessed_at DESC LIMIT $2`,
+		id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accesses := []BoardAccess{}
+	for rows.Next() {
+		var a BoardAccess
+		if err := rows.Scan(&a.UserID, &a.Shared, &a.AccessedAt); err != nil {
+			return nil, err
+		}
+		accesses = append(accesses, a)
+	}
+	return accesses, rows.Err()
+}
+
 // MigrateBoards imports board files from a directory into the database.
 // Used for one-time migration of legacy file-based boards.
 func (d *DB) MigrateBoards(ctx context.Context, boardsDir string, userID string) (int, error) {
@@ -281,7 +627,7 @@ func (d *DB) MigrateBoards(ctx context.Context, boardsDir string, userID string)
 
 	count := 0
 	for _, name := range entries {
-		board, err := parseBoardFile(boardsDir + "/" + name + ".txt")
+		board, err := parseBoardFile(filepath.Join(boardsDir, filepath.FromSlash(name)+".txt"))
 		if err != nil {
 			fmt.Printf("  Skipping %s: %v\n", name, err)
 			continue
@@ -326,6 +672,374 @@ func (d *DB) MigrateBoards(ctx context.Context, boardsDir string, userID string)
 	return count, nil
 }
 
+// ── Snapshots ────────────────────────────────────────────────────────────────
+
+// maxSnapshotsPerBoard caps board_snapshots per board; taking one more drops
+// the oldest.
+const maxSnapshotsPerBoard = 20
+
+// SnapshotBoard saves the board's current grid and annotations under label.
+// Owner-only.
+func (d *DB) SnapshotBoard(ctx context.Context, id string, userID string, label string) (*BoardSnapshot, error) {
+	if err := d.checkOwner(ctx, id, userID); err != nil {
+		return nil, err
+	}
+	tx, err := d.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var snap BoardSnapshot
+	var boardData string
+	err = tx.QueryRow(ctx,
+		`INSERT INTO board_snapshots (board_id, label, board_data, board_annotations)
+			SELECT id, $2, board_data, board_annotations FROM boards WHERE id = $1
+			RETURNING id, label, board_data, created_at`,
+		id, label).Scan(&snap.ID, &snap.Label, &boardData, &snap.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(ctx,
+		`DELETE FROM board_snapshots WHERE board_id = $1 AND id NOT IN (
+			SELECT id FROM board_snapshots WHERE board_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2)`,
+		id, maxSnapshotsPerBoard)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	snap.Board = strings.Split(boardData, "\n")
+	return &snap, nil
+}
+
+// ListSnapshots returns the board's snapshots, newest first. Owner-only.
+func (d *DB) ListSnapshots(ctx context.Context, id string, userID string) ([]BoardSnapshot, error) {
+	if err := d.checkOwner(ctx, id, userID); err != nil {
+		return nil, err
+	}
+	rows, err := d.pool.Query(ctx,
+		`SELECT id, label, board_data, created_at FROM board_snapshots
+			WHERE board_id = $1 ORDER BY created_at DESC, id DESC`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snaps := []BoardSnapshot{}
+	for rows.Next() {
+		var snap BoardSnapshot
+		var boardData string
+		if err := rows.Scan(&snap.ID, &snap.Label, &boardData, &snap.CreatedAt); err != nil {
+			return nil, err
+		}
+		snap.Board = strings.Split(boardData, "\n")
+		snaps = append(snaps, snap)
+	}
+	return snaps, rows.Err()
+}
+
+// RestoreSnapshot overwrites the live board's grid and annotations with
+// snapshot snapID. The snapshot itself is kept. Owner-only.
+func (d *DB) RestoreSnapshot(ctx context.Context, id string, userID string, snapID int64) error {
+	if err := d.checkOwner(ctx, id, userID); err != nil {
+		return err
+	}
+	tag, err := d.pool.Exec(ctx,
+		`UPDATE boards b SET board_data = s.board_data, board_annotations = s.board_annotations, updated_at = NOW()
+			FROM board_snapshots s WHERE b.id = $1 AND s.board_id = b.id AND s.id = $2`,
+		id, snapID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("snapshot not found")
+	}
+	return nil
+}
+
+// ── Move log ─────────────────────────────────────────────────────────────────
+
+// AppendMove adds m, played by player, to the end of board id's move log.
+// Ownership is checked as in SaveBoard. The board row is locked while the
+// next seq is taken, so concurrent appends don't collide.
+func (d *DB) AppendMove(ctx context.Context, id string, userID string, m BestMove, player int) error {
+	tx, err := d.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var locked string
+	if userID != "" {
+		err = tx.QueryRow(ctx,
+			`SELECT id FROM boards WHERE id = $1 AND user_id = $2 FOR UPDATE`, id, userID).Scan(&locked)
+	} else {
+		err = tx.QueryRow(ctx,
+			`SELECT id FROM boards WHERE id = $1 AND user_id IS NULL FOR UPDATE`, id).Scan(&locked)
+	}
+	if err != nil {
+		return fmt.Errorf("board not found")
+	}
+	rec := moveRecord(m, player)
+	_, err = tx.Exec(ctx,
+		`INSERT INTO board_moves (board_id, seq, x, y, dir, tiles, score, player)
+			SELECT $1, COALESCE(MAX(seq), 0) + 1, $2, $3, $4, $5, $6, $7 FROM board_moves WHERE board_id = $1`,
+		id, rec.X, rec.Y, rec.Dir, rec.Tiles, rec.Score, rec.Player)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ListMoves returns board id's move log in play order. Owner-only, as in
+// SaveBoard.
+func (d *DB) ListMoves(ctx context.Context, id string, userID string) ([]MoveRecord, error) {
+	if err := d.checkOwner(ctx, id, userID); err != nil {
+		return nil, err
+	}
+	return d.listMoves(ctx, id)
+}
+
+// ListMovesByShareToken returns the move log of the board shared under token,
+// for spectators. Records carry no user identities, only player numbers.
+func (d *DB) ListMovesByShareToken(ctx context.Context, token string) ([]MoveRecord, error) {
+	var id string
+	err := d.pool.QueryRow(ctx, `SELECT id FROM boards WHERE share_token = $1`, token).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	return d.listMoves(ctx, id)
+}
+
+// listMoves reads board id's move log in play order, with no access check.
+func (d *DB) listMoves(ctx context.Context, id string) ([]MoveRecord, error) {
+	rows, err := d.pool.Query(ctx,
+		`SELECT seq, x, y, dir, tiles, score, player, created_at FROM board_moves
+			WHERE board_id = $1 ORDER BY seq`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	moves := []MoveRecord{}
+	for rows.Next() {
+		var m MoveRecord
+		if err := rows.Scan(&m.Seq, &m.X, &m.Y, &m.Dir, &m.Tiles, &m.Score, &m.Player, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		moves = append(moves, m)
+	}
+	return moves, rows.Err()
+}
+
+// ── Edit locks ───────────────────────────────────────────────────────────────
+
+// AcquireLock takes board id's edit lock for userID for ttl. The holder may
+// call it again to extend the lock, and an expired lock goes to whoever asks
+// next. If someone else holds it, the current lock is returned alongside
+// errBoardLocked. Locks are advisory: SaveBoard doesn't check them. No
+// access check — the caller decides who may lock the board.
+func (d *DB) AcquireLock(ctx context.Context, id string, userID string, ttl time.Duration) (*BoardLock, error) {
+	var lock BoardLock
+	err := d.pool.QueryRow(ctx,
+		`INSERT INTO board_locks (board_id, user_id, expires_at)
+			VALUES ($1, $2, NOW() + $3 * INTERVAL '1 millisecond')
+			ON CONFLICT (board_id) DO UPDATE SET user_id = EXCLUDED.user_id, expires_at = EXCLUDED.expires_at
+				WHERE board_locks.user_id = EXCLUDED.user_id OR board_locks.expires_at <= NOW()
+			RETURNING user_id, expires_at`,
+		id, userID, ttl.Milliseconds()).Scan(&lock.UserID, &lock.ExpiresAt)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		if err != nil {
+			return nil, err
+		}
+		return &lock, nil
+	}
+	// The conflict update was skipped: someone else holds a live lock.
+	err = d.pool.QueryRow(ctx,
+		`SELECT user_id, expires_at FROM board_locks WHERE board_id = $1`, id,
+	).Scan(&lock.UserID, &lock.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &lock, errBoardLocked
+}
+
+// ReleaseLock drops board id's edit lock if userID holds it, expired or not.
+func (d *DB) ReleaseLock(ctx context.Context, id string, userID string) error {
+	tag, err := d.pool.Exec(ctx,
+		`DELETE FROM board_locks WHERE board_id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("lock not held")
+	}
+	return nil
+}
+
+// ── Leaderboard ──────────────────────────────────────────────────────────────
+
+// AddLeaderboardEntry records a verified play. The same play on the same
+// board is kept once; resubmitting it reports added = false.
+func (d *DB) AddLeaderboardEntry(ctx context.Context, e LeaderboardEntry) (added bool, err error) {
+	tag, err := d.pool.Exec(ctx,
+		`INSERT INTO leaderboard (board_hash, x, y, dir, word, score, username)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (board_hash, x, y, dir, word) DO NOTHING`,
+		e.BoardHash, e.X, e.Y, e.Dir, e.Word, e.Score, e.Username)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// TopLeaderboard returns the limit highest-scoring plays, earliest first
+// among equal scores.
+func (d *DB) TopLeaderboard(ctx context.Context, limit int) ([]LeaderboardEntry, error) {
+	rows, err := d.pool.Query(ctx,
+		`SELECT id, board_hash, x, y, dir, word, score, username, submitted_at
+			FROM leaderboard ORDER BY score DESC, submitted_at LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []LeaderboardEntry{}
+	for rows.Next() {
+		var e LeaderboardEntry
+		if err := rows.Scan(&e.ID, &e.BoardHash, &e.X, &e.Y, &e.Dir, &e.Word, &e.Score, &e.Username, &e.SubmittedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// ── Usage ────────────────────────────────────────────────────────────────────
+
+// CountBoardsByUser returns the number of boards each user owns, plus the
+// boards with no owner (file imports and pre-auth boards) in unowned.
+func (d *DB) CountBoardsByUser(ctx context.Context) (byUser map[string]int, unowned int, err error) {
+	rows, err := d.pool.Query(ctx,
+		`SELECT user_id, COUNT(*) FROM boards GROUP BY user_id`)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	byUser = make(map[string]int)
+	for rows.Next() {
+		var userID *string
+		var n int
+		if err := rows.Scan(&userID, &n); err != nil {
+			return nil, 0, err
+		}
+		if userID == nil {
+			unowned = n
+		} else {
+			byUser[*userID] = n
+		}
+	}
+	return byUser, unowned, rows.Err()
+}
+
+// BoardStorageBytes returns the total length of every board's board_data.
+func (d *DB) BoardStorageBytes(ctx context.Context) (int64, error) {
+	var n int64
+	err := d.pool.QueryRow(ctx,
+		`SELECT COALESCE(SUM(LENGTH(board_data)), 0) FROM boards`).Scan(&n)
+	return n, err
+}
+
+// ── Backup ───────────────────────────────────────────────────────────────────
+
+// BoardDump is the JSON backup format written by export-db: every board row,
+// independent of pg_dump. Board rows are stored exactly as in board_data.
+type BoardDump struct {
+	Version    int           `json:"version"`
+	ExportedAt time.Time     `json:"exportedAt"`
+	Boards     []BoardExport `json:"boards"`
+}
+
+type BoardExport struct {
+	BoardRecord
+	Annotations json.RawMessage `json:"annotations,omitempty"`
+}
+
+const boardDumpVersion = 1
+
+// ExportBoards reads every board row, oldest first.
+func (d *DB) ExportBoards(ctx context.Context) (*BoardDump, error) {
+	rows, err := d.pool.Query(ctx,
+		`SELECT id, user_id, name, board_data, share_token, created_at, updated_at, board_annotations
+			FROM boards ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dump := &BoardDump{Version: boardDumpVersion, ExportedAt: time.Now().UTC(), Boards: []BoardExport{}}
+	for rows.Next() {
+		var b BoardExport
+		var boardData string
+		var annotations []byte
+		if err := rows.Scan(&b.ID, &b.UserID, &b.Name, &boardData, &b.ShareToken,
+			&b.CreatedAt, &b.UpdatedAt, &annotations); err != nil {
+			return nil, err
+		}
+		b.Board = strings.Split(boardData, "\n")
+		b.Annotations = annotations
+		dump.Boards = append(dump.Boards, b)
+	}
+	return dump, rows.Err()
+}
+
+// ImportBoards writes the boards of dump in one transaction, keeping their
+// IDs, owners, share tokens and timestamps. A board whose ID already exists
+// is skipped, or replaced when overwrite is set. Returns how many rows were
+// inserted or replaced.
+func (d *DB) ImportBoards(ctx context.Context, dump *BoardDump, overwrite bool) (int, error) {
+	if dump.Version != boardDumpVersion {
+		return 0, fmt.Errorf("unsupported dump version %d", dump.Version)
+	}
+	conflict := `ON CONFLICT (id) DO NOTHING`
+	if overwrite {
+		conflict = `ON CONFLICT (id) DO UPDATE SET
+			user_id = EXCLUDED.user_id, name = EXCLUDED.name, board_data = EXCLUDED.board_data,
+			share_token = EXCLUDED.share_token, created_at = EXCLUDED.created_at,
+			updated_at = EXCLUDED.updated_at, board_annotations = EXCLUDED.board_annotations`
+	}
+
+	tx, err := d.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	count := 0
+	for _, b := range dump.Boards {
+		var annotations []byte
+		if len(b.Annotations) > 0 && string(b.Annotations) != "null" {
+			annotations = b.Annotations
+		}
+		tag, err := tx.Exec(ctx,
+			`INSERT INTO boards (id, user_id, name, board_data, share_token, created_at, updated_at, board_annotations)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8) `+conflict,
+			b.ID, b.UserID, b.Name, strings.Join(b.Board, "\n"), b.ShareToken,
+			b.CreatedAt, b.UpdatedAt, annotations)
+		if err != nil {
+			return 0, fmt.Errorf("import board %s (%s): %w", b.ID, b.Name, err)
+		}
+		count += int(tag.RowsAffected())
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
 // ── Helpers ──────────────────────────────────────────────────────────────────
 
 func generateShareToken() string {
@@ -334,17 +1048,27 @@ func generateShareToken() string {
 	return hex.EncodeToString(b)
 }
 
-// readBoardDir returns board names (without .txt extension) from a directory.
+// readBoardDir returns board names (without .txt extension) from a directory,
+// recursing into subdirectories. Nested boards are named by their
+// slash-separated path relative to dir (e.g. "endgames/q-stuck").
 func readBoardDir(dir string) ([]string, error) {
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return nil, err
-	}
 	var names []string
-	for _, e := range entries {
-		if !e.IsDir() && strings.HasSuffix(e.Name(), ".txt") {
-			names = append(names, strings.TrimSuffix(e.Name(), ".txt"))
+	err := filepath.WalkDir(dir, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".txt") {
+			return nil
 		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(strings.TrimSuffix(rel, ".txt")))
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	return names, nil
 }
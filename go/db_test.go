@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testDB connects to the database named by TEST_DATABASE_URL and migrates
+// it, skipping the test when the variable is unset. Tests create their own
+// boards and delete them on cleanup, so a shared dev database is fine.
+func testDB(t *testing.T) *DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+	db, err := NewDB(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// createTestBoard creates a blank board owned by userID and deletes it when
+// the test ends.
+func createTestBoard(t *testing.T, db *DB, userID string) string {
+	t.Helper()
+	ctx := context.Background()
+	id, err := db.CreateBoard(ctx, t.Name(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.DeleteBoard(ctx, id, userID) })
+	return id
+}
+
+func TestBoardLocks(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	const alice, bob = "test-lock-alice", "test-lock-bob"
+	id := createTestBoard(t, db, alice)
+
+	// Acquire, then a second user conflicts and sees the holder.
+	if _, err := db.AcquireLock(ctx, id, alice, time.Minute); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	lock, err := db.AcquireLock(ctx, id, bob, time.Minute)
+	if !errors.Is(err, errBoardLocked) {
+		t.Fatalf("second acquire: err = %v, want errBoardLocked", err)
+	}
+	if lock.UserID != alice {
+		t.Errorf("holder = %q, want %q", lock.UserID, alice)
+	}
+
+	// Only the holder can release; then the board is free again.
+	if err := db.ReleaseLock(ctx, id, bob); err == nil {
+		t.Error("non-holder released the lock")
+	}
+	if err := db.ReleaseLock(ctx, id, alice); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if _, err := db.AcquireLock(ctx, id, bob, time.Millisecond); err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+
+	// An expired lock goes to whoever asks next.
+	time.Sleep(50 * time.Millisecond)
+	lock, err = db.AcquireLock(ctx, id, alice, time.Minute)
+	if err != nil {
+		t.Fatalf("acquire after expiry: %v", err)
+	}
+	if lock.UserID != alice {
+		t.Errorf("holder after expiry = %q, want %q", lock.UserID, alice)
+	}
+	db.ReleaseLock(ctx, id, alice)
+}
+
+func TestSplitBoardData(t *testing.T) {
+	row := strings.Repeat(".", 15)
+	rows15 := strings.Repeat(row+"\n", 15)
+	if rows, err := splitBoardData(rows15); err != nil || len(rows) != 15 {
+		t.Errorf("15x15: %d rows, err %v", len(rows), err)
+	}
+	// A board saved under another ruleset keeps its own size.
+	row21 := strings.Repeat(".", 21)
+	if rows, err := splitBoardData(strings.TrimSuffix(strings.Repeat(row21+"\n", 21), "\n")); err != nil || len(rows) != 21 {
+		t.Errorf("21x21: %d rows, err %v", len(rows), err)
+	}
+	if _, err := splitBoardData(strings.Repeat(row+"\n", 14)); !errors.Is(err, errBoardShape) {
+		t.Errorf("14 rows of 15: err = %v, want errBoardShape", err)
+	}
+	if _, err := splitBoardData(strings.Repeat(row+"\n", 14) + row[:14]); !errors.Is(err, errBoardShape) {
+		t.Errorf("short last row: err = %v, want errBoardShape", err)
+	}
+}
+
+func TestBoardAccessLog(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	const owner, viewer = "test-access-owner", "test-access-viewer"
+	id := createTestBoard(t, db, owner)
+
+	db.LogBoardAccess(id, viewer, true)
+	db.LogBoardAccess(id, "", true)
+
+	// Writes are fire-and-forget, so wait for both rows to land.
+	var accesses []BoardAccess
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		var err error
+		accesses, err = db.ListBoardAccess(ctx, id, owner, 10)
+		if err != nil {
+			t.Fatalf("owner list: %v", err)
+		}
+		if len(accesses) >= 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if len(accesses) != 2 {
+		t.Fatalf("got %d accesses, want 2", len(accesses))
+	}
+	var named, anonymous int
+	for _, a := range accesses {
+		if !a.Shared {
+			t.Error("shared access logged as not shared")
+		}
+		switch {
+		case a.UserID == nil:
+			anonymous++
+		case *a.UserID == viewer:
+			named++
+		}
+	}
+	if named != 1 || anonymous != 1 {
+		t.Errorf("got %d viewer and %d anonymous accesses, want 1 of each", named, anonymous)
+	}
+
+	if _, err := db.ListBoardAccess(ctx, id, viewer, 10); err == nil {
+		t.Error("a non-owner read the access log")
+	}
+	if _, err := db.ListBoardAccess(ctx, id, "", 10); err == nil {
+		t.Error("an anonymous user read an owned board's access log")
+	}
+}
+
+func TestApplyPoolEnv(t *testing.T) {
+	tests := []struct {
+		name                     string
+		maxConns, minConns, idle string
+		wantMax, wantMin         int32
+		wantIdle                 time.Duration
+		wantErr                  bool
+	}{
+		{name: "defaults kept", wantMax: 4, wantMin: 0, wantIdle: 30 * time.Minute},
+		{name: "all set", maxConns: "20", minConns: "2", idle: "5m", wantMax: 20, wantMin: 2, wantIdle: 5 * time.Minute},
+		{name: "min equals max", maxConns: "3", minConns: "3", wantMax: 3, wantMin: 3, wantIdle: 30 * time.Minute},
+		{name: "max not a number", maxConns: "lots", wantErr: true},
+		{name: "max zero", maxConns: "0", wantErr: true},
+		{name: "max too large", maxConns: "1001", wantErr: true},
+		{name: "min negative", minConns: "-1", wantErr: true},
+		{name: "min above max", maxConns: "2", minConns: "5", wantErr: true},
+		{name: "idle not a duration", idle: "5", wantErr: true},
+		{name: "idle zero", idle: "0s", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DB_MAX_CONNS", tt.maxConns)
+			t.Setenv("DB_MIN_CONNS", tt.minConns)
+			t.Setenv("DB_MAX_CONN_IDLE", tt.idle)
+			cfg, err := pgxpool.ParseConfig("postgres://localhost/test?pool_max_conns=4")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = applyPoolEnv(cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.MaxConns != tt.wantMax || cfg.MinConns != tt.wantMin || cfg.MaxConnIdleTime != tt.wantIdle {
+				t.Errorf("got max %d, min %d, idle %v; want %d, %d, %v",
+					cfg.MaxConns, cfg.MinConns, cfg.MaxConnIdleTime, tt.wantMax, tt.wantMin, tt.wantIdle)
+			}
+		})
+	}
+}
+
+func TestExportImportBoards(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	const alice, bob = "test-export-alice", "test-export-bob"
+	shared := createTestBoard(t, db, alice)
+	rows := make([]string, boardSize)
+	for i := range rows {
+		rows[i] = strings.Repeat(".", boardSize)
+	}
+	rows[7] = ".......CAT" + strings.Repeat(".", boardSize-10)
+	if err := db.SaveBoard(ctx, shared, alice, rows); err != nil {
+		t.Fatal(err)
+	}
+	token, err := db.SetShareToken(ctx, shared, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private := createTestBoard(t, db, bob)
+
+	// Export, keeping only this test's boards, and round-trip the dump
+	// through JSON as export-db and import-db do.
+	mine := func(dump *BoardDump) map[string]BoardExport {
+		byID := map[string]BoardExport{}
+		for _, b := range dump.Boards {
+			if b.ID == shared || b.ID == private {
+				byID[b.ID] = b
+			}
+		}
+		return byID
+	}
+	dump, err := db.ExportBoards(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := mine(dump)
+	if len(want) != 2 {
+		t.Fatalf("export has %d of the 2 seeded boards", len(want))
+	}
+	data, err := json.Marshal(&BoardDump{Version: dump.Version, ExportedAt: dump.ExportedAt,
+		Boards: []BoardExport{want[shared], want[private]}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file BoardDump
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+
+	// Empty the table of these boards, then import them back.
+	db.DeleteBoard(ctx, shared, alice)
+	db.DeleteBoard(ctx, private, bob)
+	if n, err := db.ImportBoards(ctx, &file, false); err != nil || n != 2 {
+		t.Fatalf("import: %d, %v; want 2 boards", n, err)
+	}
+	again, err := db.ExportBoards(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := mine(again)
+	for id, w := range want {
+		g, ok := got[id]
+		if !ok {
+			t.Errorf("board %s missing after import", id)
+			continue
+		}
+		if *g.UserID != *w.UserID || g.Name != w.Name || strings.Join(g.Board, "\n") != strings.Join(w.Board, "\n") ||
+			!g.CreatedAt.Equal(w.CreatedAt) || !g.UpdatedAt.Equal(w.UpdatedAt) {
+			t.Errorf("board %s after import = %+v, want %+v", id, g.BoardMeta, w.BoardMeta)
+		}
+	}
+	if b, err := db.GetBoardByShareToken(ctx, token); err != nil || b.ID != shared {
+		t.Errorf("share token after import: %v, %v", b, err)
+	}
+	if b, err := db.GetBoard(ctx, shared); err != nil || b.Board[7] != rows[7] {
+		t.Errorf("imported board row 7 = %v, %v; want %q", b, err, rows[7])
+	}
+
+	// Importing again skips both; --overwrite replaces them.
+	if n, err := db.ImportBoards(ctx, &file, false); err != nil || n != 0 {
+		t.Errorf("re-import: %d, %v; want 0 (skipped)", n, err)
+	}
+	if n, err := db.ImportBoards(ctx, &file, true); err != nil || n != 2 {
+		t.Errorf("overwrite import: %d, %v; want 2", n, err)
+	}
+}
@@ -2,64 +2,182 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
+	"strings"
 )
 
 func main() {
-	if len(os.Args) > 1 {
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
 		switch os.Args[1] {
 		case "solve":
-			runSolve()
+			runSolve(os.Args[2:])
+		case "solve-once":
+			runSolveOnce(os.Args[2:])
 		case "serve":
 			runServer()
 		case "migrate-boards":
 			runMigrateBoards()
+		case "build-trie":
+			runBuildTrie(os.Args[2:])
+		case "export-db":
+			runExportDB(os.Args[2:])
+		case "import-db":
+			runImportDB(os.Args[2:])
 		default:
-			fmt.Fprintf(os.Stderr, "usage: scrabble [solve|serve|migrate-boards]\n")
+			fmt.Fprintf(os.Stderr, "usage: scrabble [-q|-v|--json|-players n] | [solve [--preselect n]|solve-once <board.txt> <RACK>|solve-once --manifest <file>|serve|migrate-boards|build-trie <dict> [out]|export-db <out.json>|import-db [--overwrite] <in.json>]\n")
 			os.Exit(1)
 		}
 	} else {
-		runGame()
+		runGame(os.Args[1:])
 	}
 }
 
-// runMigrateBoards imports board files from the boards/ directory into PostgreSQL.
-// Requires DATABASE_URL to be set. Optionally accepts a user ID as the second argument
-// to assign ownership of migrated boards (e.g., ./scrabble migrate-boards <keycloak-sub>).
-func runMigrateBoards() {
+// mustOpenDB connects to DATABASE_URL and runs migrations, exiting with a
+// message on failure. purpose names the command for the missing-URL error.
+func mustOpenDB(ctx context.Context, purpose string) *DB {
 	dbURL := os.Getenv("DATABASE_URL")
 	if dbURL == "" {
-		fmt.Println("DATABASE_URL is required for board migration.")
+		fmt.Printf("DATABASE_URL is required for %s.\n", purpose)
 		os.Exit(1)
 	}
-
-	ctx := context.Background()
 	db, err := NewDB(ctx, dbURL)
 	if err != nil {
 		fmt.Println("Failed to connect to database:", err)
 		os.Exit(1)
 	}
-	defer db.Close()
-
 	if err := db.Migrate(ctx); err != nil {
+		db.Close()
 		fmt.Println("Failed to run migrations:", err)
 		os.Exit(1)
 	}
+	return db
+}
 
-	boardsDir := "boards"
+// runMigrateBoards imports board files from the boards directory (BOARDS_DIR,
+// default boards/) into PostgreSQL.
+// Requires DATABASE_URL to be set. Optionally accepts a user ID as the second argument
+// to assign ownership of migrated boards (e.g., ./scrabble migrate-boards <keycloak-sub>).
+func runMigrateBoards() {
+	ctx := context.Background()
+	db := mustOpenDB(ctx, "board migration")
+	defer db.Close()
+
+	dir := boardsDir()
 	userID := ""
 	if len(os.Args) > 2 {
 		userID = os.Args[2]
-		fmt.Printf("Migrating boards from %s/ with user_id=%s\n", boardsDir, userID)
+		fmt.Printf("Migrating boards from %s/ with user_id=%s\n", dir, userID)
 	} else {
-		fmt.Printf("Migrating boards from %s/ (no user_id, boards will be unowned)\n", boardsDir)
+		fmt.Printf("Migrating boards from %s/ (no user_id, boards will be unowned)\n", dir)
 	}
 
-	count, err := db.MigrateBoards(ctx, boardsDir, userID)
+	count, err := db.MigrateBoards(ctx, dir, userID)
 	if err != nil {
 		fmt.Printf("Migration error: %v\n", err)
 		os.Exit(1)
 	}
 	fmt.Printf("Done. Imported %d board(s).\n", count)
 }
+
+// runBuildTrie parses a dictionary (minus exclusions.txt) and writes the trie
+// cache that buildTrie loads on startup. out defaults to trieCachePath(dict),
+// the only location buildTrie checks. It also warns about FNV collisions in
+// the dictionary (see loadDictionaryChecked).
+func runBuildTrie(args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, "usage: scrabble build-trie <dict> [out]")
+		os.Exit(1)
+	}
+	dict := args[0]
+	out := trieCachePath(dict)
+	if len(args) == 2 {
+		out = args[1]
+	}
+
+	// The ruleset's alphabet decides which words the trie holds, and the
+	// cache is fingerprinted with it.
+	loadRuleset()
+	excluded, err := loadExclusions("exclusions.txt", alphabet)
+	if err != nil {
+		fmt.Println("Unable to open exclusions:", err)
+		os.Exit(1)
+	}
+	if _, collisions, err := loadDictionaryChecked(dict, alphabet); err == nil && len(collisions) > 0 {
+		fmt.Printf("Warning: %d FNV collision(s) in %s: %s\n", len(collisions), dict, strings.Join(collisions, ", "))
+	}
+	trie, err := parseTrie(dict, alphabet, excluded)
+	if err != nil {
+		fmt.Println("Unable to build trie:", err)
+		os.Exit(1)
+	}
+	if err := saveTrieCache(out, trie, alphabet, excluded); err != nil {
+		fmt.Println("Unable to write trie cache:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %d trie nodes to %s\n", countTrieNodes(trie), out)
+}
+
+// runExportDB writes every board row to a JSON file (see BoardDump) for
+// moving boards between environments without pg_dump.
+func runExportDB(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: scrabble export-db <out.json>")
+		os.Exit(1)
+	}
+	ctx := context.Background()
+	db := mustOpenDB(ctx, "export")
+	defer db.Close()
+
+	dump, err := db.ExportBoards(ctx)
+	if err != nil {
+		fmt.Println("Export failed:", err)
+		os.Exit(1)
+	}
+	data, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		fmt.Println("Export failed:", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(args[0], data, 0644); err != nil {
+		fmt.Println("Unable to write export:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Exported %d board(s) to %s\n", len(dump.Boards), args[0])
+}
+
+// runImportDB loads a file written by export-db. Boards whose ID already
+// exists are skipped unless --overwrite is given.
+func runImportDB(args []string) {
+	fs := flag.NewFlagSet("import-db", flag.ExitOnError)
+	overwrite := fs.Bool("overwrite", false, "replace boards whose ID already exists instead of skipping them")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: scrabble import-db [--overwrite] <in.json>")
+		os.Exit(1)
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Println("Unable to read import:", err)
+		os.Exit(1)
+	}
+	var dump BoardDump
+	if err := json.Unmarshal(data, &dump); err != nil {
+		fmt.Println("Import file is malformed:", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	db := mustOpenDB(ctx, "import")
+	defer db.Close()
+
+	count, err := db.ImportBoards(ctx, &dump, *overwrite)
+	if err != nil {
+		fmt.Println("Import failed:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Imported %d of %d board(s); %d skipped\n", count, len(dump.Boards), len(dump.Boards)-count)
+}
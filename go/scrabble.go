@@ -2,116 +2,155 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"math/rand"
+	"os"
 	"runtime"
 	"sort"
+	"strconv"
 	"time"
 )
 
-func NewBoard(dict string) *Board {
-	board := &Board{}
-	board.board = make([][]byte, 15)
-	for i := 0; i < 15; i++ {
-		board.board[i] = make([]byte, 15)
+// NewBoard sets up a game for numPlayers players: a shuffled bag and one
+// dealt rack each. rng drives the shuffle and every later bag shuffle; nil
+// means a time-seeded source.
+func NewBoard(dict string, numPlayers int, rng *rand.Rand) *Board {
+	if rng == nil {
+		rng = rand.New(rand.NewSource(time.Now().UnixNano()))
 	}
-	board.ptiles = [2][]byte{{}, {}}
+	board := &Board{rng: rng}
+	board.board = make([][]byte, boardSize)
+	for i := 0; i < boardSize; i++ {
+		board.board[i] = make([]byte, boardSize)
+	}
+	board.pscore = make([]int, numPlayers)
+	board.ptiles = make([][]byte, numPlayers)
 	board.tiles = []byte(startTiles)
 	for i := range board.tiles {
-		j := rand.Intn(i + 1)
+		j := rng.Intn(i + 1)
 		board.tiles[i], board.tiles[j] = board.tiles[j], board.tiles[i]
 	}
-	var err error
-	board.wordlist, err = loadDictionary(dict)
+	excluded, err := loadExclusions("exclusions.txt", alphabet)
+	if err != nil {
+		fmt.Println("Unable to open exclusions", err)
+		return nil
+	}
+	board.wordlist, err = loadDictionary(dict, alphabet, excluded)
 	if err != nil {
 		fmt.Println("Unable to open dictionary", err)
 		return nil
 	}
-	board.trie, err = buildTrie(dict)
+	board.trie, err = loadIndex(dict, alphabet, excluded)
 	if err != nil {
 		fmt.Println("Unable to build trie", err)
-		return nil
 	}
-	board.ptiles[0], board.tiles = board.tiles[:7], board.tiles[7:]
-	board.ptiles[1], board.tiles = board.tiles[:7], board.tiles[7:]
+	for p := range board.ptiles {
+		board.ptiles[p], board.tiles = board.tiles[:rackSize], board.tiles[rackSize:]
+	}
 	return board
 }
 
-func (b *Board) play(x, y int, word string, dir direction) {
-	if dir == DIR_VERT {
-		for i := y; len(word) > 0; i++ {
-			if b.board[x][i] != 0 {
-				continue
-			}
-			b.board[x][i] = word[0]
-			word = word[1:]
+// gameTurn is one self-play turn in the transcript format /api/validate-game
+// takes: (X, Y) is the start of Word, the whole main word with blanks
+// lowercase. Exchanges and passes carry only Type and Player.
+type gameTurn struct {
+	Player int    `json:"player"` // 1-based
+	Type   string `json:"type"`   // "play", "exchange" or "pass"
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Dir    string `json:"dir,omitempty"`
+	Word   string `json:"word,omitempty"`
+	Score  int    `json:"score"`
+}
+
+// recordPlay appends m to b.history. Call it before m is placed, so the word
+// start can be found by walking back over tiles already on the board.
+func (b *Board) recordPlay(player int, m BestMove) {
+	t := gameTurn{Player: player + 1, Type: "play", X: m.x, Y: m.y, Dir: "H", Word: fullWord(b, m), Score: m.score}
+	if m.dir == DIR_VERT {
+		t.Dir = "V"
+		for t.Y > 0 && b.board[t.X][t.Y-1] != 0 {
+			t.Y--
 		}
 	} else {
-		for i := x; len(word) > 0; i++ {
-			if b.board[i][y] != 0 {
-				continue
-			}
-			b.board[i][y] = word[0]
-			word = word[1:]
+		for t.X > 0 && b.board[t.X-1][t.Y] != 0 {
+			t.X--
 		}
 	}
+	b.history = append(b.history, t)
 }
 
+// minPlayers and maxPlayers bound the number of players in a self-play game.
+const (
+	minPlayers = 2
+	maxPlayers = 4
+)
+
+// exchangeThreshold is the score below which DoTurn exchanges its whole rack
+// instead of playing, when the bag allows it.
+const exchangeThreshold = 10
+
+// maxScorelessTurns ends a game after this many consecutive turns score
+// nothing, as in the official rules.
+const maxScorelessTurns = 6
+
+// DoTurn plays player's highest-scoring move. If that scores under
+// exchangeThreshold (or there is none) and canExchange allows it, the whole
+// rack is exchanged instead; with no move and no exchange the player passes.
+// Passes and exchanges count towards b.scoreless.
 func (b *Board) DoTurn(player int) {
 	startCount := len(b.ptiles[player])
-	var moves []BestMove
-	seen := make(map[string]bool)
-	rack := make([]byte, startCount)
-	copy(rack, b.ptiles[player])
-	rackLen := startCount
-
-	for x := 0; x < 15; x++ {
-		for y := 0; y < 15; y++ {
-			if b.board[x][y] != 0 {
-				continue
-			}
-			for _, dir := range []direction{DIR_HORIZ, DIR_VERT} {
-				startX, startY, play, crossPlays, room := b.getPlaySpace(x, y, dir)
-				if room == 0 {
-					continue
-				}
-				var offset int
-				if dir == DIR_HORIZ {
-					offset = x - startX
-				} else {
-					offset = y - startY
-				}
-				node := b.trie
-				valid := true
-				for i := 0; i < offset; i++ {
-					idx := int(play[i]&^32) - int('A')
-					if idx < 0 || idx >= 26 || node.children[idx] == nil {
-						valid = false
-						break
-					}
-					node = node.children[idx]
-				}
-				if !valid {
-					continue
-				}
-				b.searchPlay(node, play, crossPlays, offset, rack,
-					make([]byte, 0, 7), x, y, dir, rackLen, seen, &moves)
-			}
-		}
-	}
+	moves := b.generateMoves(b.ptiles[player])
 
+	if b.verbose == verbosityVerbose {
+		fmt.Printf("Player %d considered %d moves\n", player+1, len(moves))
+	}
 	if len(moves) == 0 {
-		fmt.Println("NO WORD FOUND - PASSING")
+		b.scoreless++
+		if b.canExchange(player) {
+			n := len(b.ptiles[player])
+			b.exchange(player, append([]byte(nil), b.ptiles[player]...))
+			b.history = append(b.history, gameTurn{Player: player + 1, Type: "exchange"})
+			if b.verbose != verbosityQuiet {
+				fmt.Printf("NO WORD FOUND - EXCHANGING %d TILES\n", n)
+			}
+			return
+		}
+		b.history = append(b.history, gameTurn{Player: player + 1, Type: "pass"})
+		if b.verbose != verbosityQuiet {
+			fmt.Println("NO WORD FOUND - PASSING")
+		}
 		return
 	}
 	sort.Slice(moves, func(i, j int) bool { return moves[i].score > moves[j].score })
 	m := moves[0]
-
-	b.play(m.x, m.y, m.tiles, m.dir)
-	if startCount == 7 && len(m.tiles) == 7 {
-		fmt.Printf("Play %s for %d points (includes %dpt bingo bonus)\n", m.tiles, m.score, bingoBonus)
+	if m.score < exchangeThreshold && b.canExchange(player) {
+		b.scoreless++
+		n := len(b.ptiles[player])
+		b.exchange(player, append([]byte(nil), b.ptiles[player]...))
+		b.history = append(b.history, gameTurn{Player: player + 1, Type: "exchange"})
+		if b.verbose != verbosityQuiet {
+			fmt.Printf("BEST PLAY %s ONLY %d POINTS - EXCHANGING %d TILES\n", m.tiles, m.score, n)
+		}
+		return
+	}
+	if m.score > 0 {
+		b.scoreless = 0
 	} else {
-		fmt.Println("Play", m.tiles, "for", m.score, "points")
+		b.scoreless++
+	}
+
+	b.recordPlay(player, m)
+	applyMove(b, m)
+	if b.verbose != verbosityQuiet {
+		if isBingo(startCount, len(m.tiles)) {
+			fmt.Printf("Play %s for %d points (includes %dpt bingo bonus)\n", m.tiles, m.score, bingoBonus)
+		} else {
+			fmt.Println("Play", m.tiles, "for", m.score, "points")
+		}
 	}
 	for _, c := range m.tiles {
 		if c >= 'a' && c <= 'z' {
@@ -120,30 +159,61 @@ func (b *Board) DoTurn(player int) {
 		idx := bytes.IndexRune(b.ptiles[player], c)
 		b.ptiles[player] = append(b.ptiles[player][:idx], b.ptiles[player][idx+1:]...)
 	}
-	for len(b.ptiles[player]) < 7 && len(b.tiles) > 0 {
+	for len(b.ptiles[player]) < rackSize && len(b.tiles) > 0 {
 		b.ptiles[player] = append(b.ptiles[player], b.tiles[0])
 		b.tiles = b.tiles[1:]
 	}
 	b.pscore[player] += m.score
 }
 
-func runGame() {
-	runtime.GOMAXPROCS(runtime.NumCPU())
-	rand.Seed(time.Now().Unix())
+// canExchange reports whether player may exchange tiles. Exchanging is only
+// allowed while the bag still holds at least a full rack (rackSize); below
+// that the player must play or pass.
+func (b *Board) canExchange(player int) bool {
+	return len(b.ptiles[player]) > 0 && len(b.tiles) >= rackSize
+}
 
-	ruleset := loadRuleset()
-	fmt.Printf("Ruleset: %s\n", ruleset)
+// exchange swaps tiles from player's rack for new ones from the bag. The
+// replacements are drawn before the old tiles go back in, then the bag is
+// reshuffled. Callers must check canExchange first.
+func (b *Board) exchange(player int, tiles []byte) {
+	for _, t := range tiles {
+		idx := bytes.IndexByte(b.ptiles[player], t)
+		b.ptiles[player] = append(b.ptiles[player][:idx], b.ptiles[player][idx+1:]...)
+	}
+	b.ptiles[player] = append(b.ptiles[player], b.tiles[:len(tiles)]...)
+	b.tiles = append(b.tiles[len(tiles):], tiles...)
+	b.rng.Shuffle(len(b.tiles), func(i, j int) {
+		b.tiles[i], b.tiles[j] = b.tiles[j], b.tiles[i]
+	})
+}
 
-	b := NewBoard("dictionary.txt")
+// playGame runs turns until the game ends. By default the game ends when the
+// bag empties: after that each player gets one more turn, starting from the
+// player after whoever drew the last tile. It also ends at once after
+// maxScorelessTurns scoreless turns in a row. With target > 0 the game also
+// ends as soon as a player's score reaches target, skipping any remaining
+// turns.
+func (b *Board) playGame(target int) {
+	gameOver := func(p int) bool {
+		if b.scoreless >= maxScorelessTurns {
+			if b.verbose != verbosityQuiet {
+				fmt.Printf("%d SCORELESS TURNS - GAME OVER\n", b.scoreless)
+			}
+			return true
+		}
+		return target > 0 && b.pscore[p] >= target
+	}
 
-	// Game ends when the bag empties. After the bag depletes, each player
-	// gets one more turn (starting from the player after whoever drew the last tile).
 	bagDepleted := false
 	finalPlayer := -1
 
 	for !bagDepleted {
-		for p := 0; p < 2; p++ {
+		for p := range b.pscore {
 			b.DoTurn(p)
+			if gameOver(p) {
+				return
+			}
 			if !bagDepleted && len(b.tiles) == 0 {
 				bagDepleted = true
 				finalPlayer = p
@@ -153,9 +223,124 @@ func runGame() {
 	}
 
 	// Each player gets one more turn in order.
-	for i := 0; i < 2; i++ {
-		b.DoTurn((finalPlayer + 1 + i) % 2)
+	for i := range b.pscore {
+		p := (finalPlayer + 1 + i) % len(b.pscore)
+		b.DoTurn(p)
+		if gameOver(p) {
+			return
+		}
+	}
+}
+
+// finalizeScores applies the end-of-game rack adjustment: each player loses
+// the tilePoints of the tiles left on their rack (blanks count 0), and a
+// player who went out gains everyone else's leftovers.
+func (b *Board) finalizeScores() {
+	out := -1
+	total := 0
+	for p, rack := range b.ptiles {
+		left := 0
+		for _, t := range rack {
+			left += tilePoints[t]
+		}
+		if len(rack) == 0 {
+			out = p
+		}
+		b.pscore[p] -= left
+		total += left
+	}
+	if out >= 0 {
+		b.pscore[out] += total
+	}
+}
+
+// runGame plays one AI-vs-AI game. args are the command-line flags:
+// -q suppresses per-move output, -v adds search stats to it, -target n
+// switches to the first-to-n-points variant, -players n seats 2 to 4 AI
+// players, and -json prints only the result as JSON (see writeGameJSON)
+// instead of the board. Unless a target is set, the final scores include the
+// rack adjustment (see finalizeScores). The SEED environment variable fixes
+// the tile shuffle so a game can be replayed exactly.
+func runGame(args []string) {
+	fs := flag.NewFlagSet("scrabble", flag.ExitOnError)
+	quiet := fs.Bool("q", false, "quiet: print only the final board and scores")
+	verbose := fs.Bool("v", false, "verbose: also print how many moves each turn considered")
+	targetFlag := fs.Int("target", 0, "end the game when a player reaches this score (overrides the ruleset's target_score)")
+	jsonOut := fs.Bool("json", false, "print the final board, scores and moves as JSON")
+	players := fs.Int("players", 2, "number of AI players (2-4)")
+	fs.Parse(args)
+	if *players < minPlayers || *players > maxPlayers {
+		fmt.Printf("-players must be between %d and %d\n", minPlayers, maxPlayers)
+		os.Exit(1)
+	}
+
+	runtime.GOMAXPROCS(runtime.NumCPU())
+	seed := time.Now().UnixNano()
+	if v := os.Getenv("SEED"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			fmt.Printf("Invalid SEED %q: %v\n", v, err)
+			os.Exit(1)
+		}
+		seed = n
 	}
 
+	ruleset := loadRuleset()
+	target := targetScore
+	if *targetFlag > 0 {
+		target = *targetFlag
+	}
+	if *jsonOut {
+		*quiet = true
+	}
+	if !*quiet {
+		fmt.Printf("Ruleset: %s\n", ruleset)
+		if target > 0 {
+			fmt.Printf("First to %d points wins\n", target)
+		}
+	}
+
+	b := NewBoard("dictionary.txt", *players, rand.New(rand.NewSource(seed)))
+	if b == nil {
+		os.Exit(1)
+	}
+	switch {
+	case *quiet:
+		b.verbose = verbosityQuiet
+	case *verbose:
+		b.verbose = verbosityVerbose
+	}
+	if !*quiet {
+		fmt.Println("Move generation:", b.moveGenPath())
+		fmt.Println("Seed:", seed)
+	}
+
+	b.playGame(target)
+	if target == 0 {
+		b.finalizeScores()
+	}
+	if *jsonOut {
+		if err := b.writeGameJSON(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing JSON:", err)
+			os.Exit(1)
+		}
+		return
+	}
 	b.PrintBoard()
 }
+
+// writeGameJSON writes a finished game as {finalBoard, scores, moves}:
+// finalBoard as boardToStrings rows, moves as b.history.
+func (b *Board) writeGameJSON(w io.Writer) error {
+	moves := b.history
+	if moves == nil {
+		moves = []gameTurn{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]interface{}{
+		"finalBoard": boardToStrings(b.board),
+		"scores":     b.pscore,
+		"moves":      moves,
+	})
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"math/rand"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFinalizeScores(t *testing.T) {
+	tests := []struct {
+		name      string
+		racks     []string
+		scores    []int
+		scoreless int
+		want      []int
+	}{
+		{
+			name:   "one player out",
+			racks:  []string{"", "QZ", "AE"},
+			scores: []int{300, 310, 250},
+			want:   []int{322, 290, 248},
+		},
+		{
+			name:   "blank counts zero",
+			racks:  []string{"*X", ""},
+			scores: []int{200, 195},
+			want:   []int{192, 203},
+		},
+		{
+			name:      "nobody out after scoreless turns",
+			racks:     []string{"AE*", "JQ"},
+			scores:    []int{150, 160},
+			scoreless: maxScorelessTurns,
+			want:      []int{148, 140},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Board{pscore: append([]int(nil), tt.scores...), scoreless: tt.scoreless}
+			for _, r := range tt.racks {
+				b.ptiles = append(b.ptiles, []byte(r))
+			}
+			b.finalizeScores()
+			if !reflect.DeepEqual(b.pscore, tt.want) {
+				t.Errorf("scores = %v, want %v", b.pscore, tt.want)
+			}
+		})
+	}
+}
+
+// captureStdout returns what f prints to os.Stdout.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	f()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestDoTurnVerbosity(t *testing.T) {
+	turn := func(v verbosity) string {
+		b := newTestBoard(t)
+		b.verbose = v
+		b.rng = rand.New(rand.NewSource(1))
+		b.ptiles = [][]byte{[]byte("CARTSEX"), nil}
+		b.pscore = make([]int, 2)
+		b.tiles = []byte("AEIOUAEIOU")
+		return captureStdout(t, func() { b.DoTurn(0) })
+	}
+
+	if out := turn(verbosityQuiet); out != "" {
+		t.Errorf("quiet turn printed %q", out)
+	}
+	normal := turn(verbosityNormal)
+	if !strings.HasPrefix(normal, "Play ") || strings.Contains(normal, "considered") {
+		t.Errorf("normal turn printed %q, want just the play", normal)
+	}
+	verbose := turn(verbosityVerbose)
+	if !strings.Contains(verbose, "Player 1 considered") || !strings.HasSuffix(verbose, normal) {
+		t.Errorf("verbose turn printed %q, want the move count and then %q", verbose, normal)
+	}
+}
+
+func TestNoExchangeFromShortBag(t *testing.T) {
+	turn := func(rack, bag string) *Board {
+		b := newTestBoard(t)
+		place(b, "CAT", 6, 7, DIR_HORIZ)
+		b.verbose = verbosityQuiet
+		b.rng = rand.New(rand.NewSource(1))
+		b.ptiles = [][]byte{[]byte(rack), nil}
+		b.pscore = make([]int, 2)
+		b.tiles = []byte(bag)
+		b.DoTurn(0)
+		return b
+	}
+
+	// With no move, a full rack's worth in the bag allows an exchange...
+	if b := turn("QQJJVVW", "EEEEEEE"); b.history[0].Type != "exchange" {
+		t.Errorf("bag of 7, no move: %s, want exchange", b.history[0].Type)
+	}
+	// ...but with 5 left the player must pass and keep the rack.
+	b := turn("QQJJVVW", "EEEEE")
+	if b.history[0].Type != "pass" || string(b.ptiles[0]) != "QQJJVVW" || string(b.tiles) != "EEEEE" {
+		t.Errorf("bag of 5, no move: %s with rack %s and bag %s, want a pass leaving both alone",
+			b.history[0].Type, b.ptiles[0], b.tiles)
+	}
+
+	// A weak play is exchanged only while the bag allows it; otherwise it's played.
+	if b := turn("TQQJJVV", "EEEEEEE"); b.history[0].Type != "exchange" {
+		t.Errorf("bag of 7, weak play: %s, want exchange", b.history[0].Type)
+	}
+	if b := turn("TQQJJVV", "EEEEE"); b.history[0].Type != "play" {
+		t.Errorf("bag of 5, weak play: %s, want play", b.history[0].Type)
+	}
+}
+
+func TestNewBoardSeededDeal(t *testing.T) {
+	dict := writeDict(t, testWords)
+	deal := func(seed int64) *Board {
+		t.Helper()
+		var b *Board
+		captureStdout(t, func() { b = NewBoard(dict, 3, rand.New(rand.NewSource(seed))) })
+		if b == nil {
+			t.Fatal("NewBoard failed")
+		}
+		return b
+	}
+
+	a, b := deal(42), deal(42)
+	if !reflect.DeepEqual(a.ptiles, b.ptiles) || string(a.tiles) != string(b.tiles) {
+		t.Errorf("seed 42 dealt %q then %q", a.ptiles, b.ptiles)
+	}
+	if c := deal(43); reflect.DeepEqual(a.ptiles, c.ptiles) {
+		t.Errorf("seeds 42 and 43 both dealt %q", a.ptiles)
+	}
+}
+
+func TestTargetScoreEndsGame(t *testing.T) {
+	dict := writeDict(t, testWords)
+	play := func(target int) *Board {
+		t.Helper()
+		var b *Board
+		captureStdout(t, func() { b = NewBoard(dict, 2, rand.New(rand.NewSource(13))) })
+		if b == nil {
+			t.Fatal("NewBoard failed")
+		}
+		b.verbose = verbosityQuiet
+		b.playGame(target)
+		return b
+	}
+
+	// With seed 13 player 1 reaches 32 on the third turn (TAX, ER, TRACE),
+	// while the full game goes on to six scoreless turns.
+	full := play(0)
+	const target = 30
+	b := play(target)
+	if len(b.history) >= len(full.history) {
+		t.Fatalf("target game ran %d turns, the full game only %d", len(b.history), len(full.history))
+	}
+
+	// The game stops on the very turn someone first reaches the target.
+	totals := make([]int, 2)
+	for i, turn := range b.history {
+		totals[turn.Player-1] += turn.Score
+		reached := totals[turn.Player-1] >= target
+		if last := i == len(b.history)-1; reached != last {
+			t.Fatalf("turn %d: player %d at %d, reached target %v, last turn %v", i+1, turn.Player, totals[turn.Player-1], reached, last)
+		}
+	}
+	if !reflect.DeepEqual(totals, b.pscore) {
+		t.Errorf("history totals %v, scores %v", totals, b.pscore)
+	}
+}
+
+func TestWriteGameJSON(t *testing.T) {
+	dict := writeDict(t, testWords)
+	var b *Board
+	captureStdout(t, func() { b = NewBoard(dict, 3, rand.New(rand.NewSource(13))) })
+	if b == nil {
+		t.Fatal("NewBoard failed")
+	}
+	b.verbose = verbosityQuiet
+	b.playGame(0)
+	b.finalizeScores()
+
+	var out strings.Builder
+	if err := b.writeGameJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "\x1b[") {
+		t.Error("JSON output contains ANSI escapes")
+	}
+	var game struct {
+		FinalBoard []string   `json:"finalBoard"`
+		Scores     []int      `json:"scores"`
+		Moves      []gameTurn `json:"moves"`
+	}
+	if err := json.Unmarshal([]byte(out.String()), &game); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out.String())
+	}
+	if len(game.Scores) != 3 || !reflect.DeepEqual(game.Scores, b.pscore) {
+		t.Errorf("scores = %v, want the 3 players' %v", game.Scores, b.pscore)
+	}
+	if !reflect.DeepEqual(game.FinalBoard, boardToStrings(b.board)) || len(game.FinalBoard) != boardSize {
+		t.Errorf("finalBoard does not match the board:\n%s", strings.Join(game.FinalBoard, "\n"))
+	}
+	if len(game.Moves) == 0 || !reflect.DeepEqual(game.Moves, b.history) {
+		t.Errorf("moves = %+v, want the %d turns played", game.Moves, len(b.history))
+	}
+}
@@ -1,16 +1,25 @@
 package main
 
 import (
+	"container/list"
 	"context"
 	"embed"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
+	"math"
+	"math/rand"
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 //go:embed all:static
@@ -25,26 +34,80 @@ type MoveResponse struct {
 	Tiles        string   `json:"tiles"`
 	Word         string   `json:"word"`
 	Score        int      `json:"score"`
+	MainScore    int      `json:"mainScore"`  // main word, plus any bingo bonus
+	CrossScore   int      `json:"crossScore"` // all cross-words formed; MainScore+CrossScore == Score
 	NewPositions [][2]int `json:"newPositions"`
+	// Blanks are the NewPositions where a blank was placed, in the same
+	// (x, y) form.
+	Blanks [][2]int `json:"blanks,omitempty"`
+	// BlankCost is how many more points the move would score if each blank
+	// were the real tile it stands for, premiums included. 0 without blanks.
+	BlankCost int `json:"blankCost,omitempty"`
+	// Warnings are advisory notes on premium tiles spent cheaply (see
+	// moveWarnings), e.g. "uses blank".
+	Warnings []string `json:"warnings,omitempty"`
+	// BestElsewhere is the same word's best other placement (solve with
+	// showPotential only; absent if the word fits nowhere else).
+	BestElsewhere *MoveResponse `json:"bestElsewhere,omitempty"`
+	// LeaveValue is leaveValue of the tiles the move keeps (solve with
+	// useLeave only).
+	LeaveValue *float64 `json:"leaveValue,omitempty"`
+}
+
+type RackAnalysisResponse struct {
+	Vowels     int            `json:"vowels"`
+	Consonants int            `json:"consonants"`
+	Blanks     int            `json:"blanks"`
+	Duplicates map[string]int `json:"duplicates"`
+	Balance    string         `json:"balance"`
+}
+
+type TileResponse struct {
+	Letter string `json:"letter"` // "A"–"Z", or "*" for the blank
+	Count  int    `json:"count"`
+	Points int    `json:"points"`
+}
+
+type ConfigResponse struct {
+	ActiveRuleset         string   `json:"activeRuleset"`
+	AvailableRulesets     []string `json:"availableRulesets"`
+	ActiveDictionary      string   `json:"activeDictionary"`
+	AvailableDictionaries []string `json:"availableDictionaries"`
+	AuthEnabled           bool     `json:"authEnabled"`
+	DBBacked              bool     `json:"dbBacked"`
 }
 
 type RulesetResponse struct {
 	Name         string         `json:"name"`
 	BingoBonus   int            `json:"bingoBonus"`
+	BoardSize    int            `json:"boardSize"`
+	RackSize     int            `json:"rackSize"`
+	Center       [2]int         `json:"center"`
 	LetterPoints map[string]int `json:"letterPoints"`
 	TripleWord   [][2]int       `json:"tripleWord"`
 	DoubleWord   [][2]int       `json:"doubleWord"`
 	TripleLetter [][2]int       `json:"tripleLetter"`
 	DoubleLetter [][2]int       `json:"doubleLetter"`
+
+	// ChallengeRule is "lose-turn" or "penalty"; ChallengePenalty is what
+	// "penalty" docks for a phony.
+	ChallengeRule    string `json:"challengeRule"`
+	ChallengePenalty int    `json:"challengePenalty"`
+
+	// Alphabet lists the letters in tile order: board rows, racks and
+	// move tiles use the byte 'A'+i for Alphabet[i] (lowercase for a
+	// blank). LetterPoints is keyed by letter.
+	Alphabet      []string `json:"alphabet"`
+	CaseSensitive bool     `json:"caseSensitive"`
 }
 
 // ── Helpers ──────────────────────────────────────────────────────────────────
 
 func boardToStrings(board [][]byte) []string {
-	rows := make([]string, 15)
-	for y := 0; y < 15; y++ {
+	rows := make([]string, boardSize)
+	for y := 0; y < boardSize; y++ {
 		var sb strings.Builder
-		for x := 0; x < 15; x++ {
+		for x := 0; x < boardSize; x++ {
 			if board[x][y] == 0 {
 				sb.WriteByte('.')
 			} else {
@@ -57,12 +120,12 @@ func boardToStrings(board [][]byte) []string {
 }
 
 func stringsToBoard(rows []string) [][]byte {
-	board := make([][]byte, 15)
+	board := make([][]byte, boardSize)
 	for i := range board {
-		board[i] = make([]byte, 15)
+		board[i] = make([]byte, boardSize)
 	}
-	for y := 0; y < 15 && y < len(rows); y++ {
-		for x := 0; x < 15 && x < len(rows[y]); x++ {
+	for y := 0; y < boardSize && y < len(rows); y++ {
+		for x := 0; x < boardSize && x < len(rows[y]); x++ {
 			c := rows[y][x]
 			if c != '.' {
 				board[x][y] = c
@@ -72,6 +135,19 @@ func stringsToBoard(rows []string) [][]byte {
 	return board
 }
 
+// boardHash returns a stable hex digest of a board grid, for clients caching
+// results per board. Rows are normalized through stringsToBoard first, so
+// short or ragged rows hash the same as their padded full-size form. Blanks
+// (lowercase) hash differently from real tiles since they score differently.
+func boardHash(rows []string) string {
+	h := NewFNV()
+	for _, row := range boardToStrings(stringsToBoard(rows)) {
+		h.AddString(row)
+		h.AddString("\n")
+	}
+	return fmt.Sprintf("%016x", h.Val())
+}
+
 func bestMoveToResponse(b *Board, m BestMove) MoveResponse {
 	dirStr := "H"
 	if m.dir == DIR_VERT {
@@ -79,26 +155,16 @@ func bestMoveToResponse(b *Board, m BestMove) MoveResponse {
 	}
 	word := fullWord(b, m)
 
-	var newPos [][2]int
-	tileIdx := 0
-	if m.dir == DIR_VERT {
-		for i := m.y; tileIdx < len(m.tiles); i++ {
-			if b.board[m.x][i] != 0 {
-				continue
-			}
-			newPos = append(newPos, [2]int{m.x, i})
-			tileIdx++
-		}
-	} else {
-		for i := m.x; tileIdx < len(m.tiles); i++ {
-			if b.board[i][m.y] != 0 {
-				continue
-			}
-			newPos = append(newPos, [2]int{i, m.y})
-			tileIdx++
+	var newPos, blanks [][2]int
+	for _, p := range newTilePositions(b.board, m) {
+		newPos = append(newPos, [2]int{p.X, p.Y})
+		if p.Blank {
+			blanks = append(blanks, [2]int{p.X, p.Y})
 		}
 	}
 
+	_, cross := b.scoreMoveParts(m.x, m.y, m.tiles, m.dir)
+
 	return MoveResponse{
 		X:            m.x,
 		Y:            m.y,
@@ -106,7 +172,64 @@ func bestMoveToResponse(b *Board, m BestMove) MoveResponse {
 		Tiles:        m.tiles,
 		Word:         word,
 		Score:        m.score,
+		MainScore:    m.score - cross,
+		CrossScore:   cross,
 		NewPositions: newPos,
+		Blanks:       blanks,
+		BlankCost:    b.blankCost(m),
+		Warnings:     moveWarnings(m),
+	}
+}
+
+// decodeRack parses a rack field of a request with parseRack. If it holds
+// characters other than letters and '*', it writes a 400 naming them (as
+// "invalid") and returns ok = false.
+func decodeRack(w http.ResponseWriter, field, input string) (rack []byte, ok bool) {
+	rack, invalid := parseRack(input)
+	if len(invalid) > 0 {
+		writeJSON(w, 400, map[string]interface{}{
+			"error":   fmt.Sprintf("%s contains invalid characters: %s", field, quoteInvalid(invalid)),
+			"invalid": invalid,
+		})
+		return nil, false
+	}
+	return rack, true
+}
+
+// checkBoardRows reports whether rows is a full grid for the active ruleset:
+// boardSize rows of exactly boardSize bytes each. stringsToBoard would
+// otherwise pad short rows with empty cells and drop the tail of long ones,
+// silently changing the user's board. On failure it writes a 400 naming the
+// first bad row and its length.
+func checkBoardRows(w http.ResponseWriter, rows []string) bool {
+	if len(rows) != boardSize {
+		writeError(w, 400, fmt.Sprintf("board must have %d rows", boardSize))
+		return false
+	}
+	for i, row := range rows {
+		if len(row) != boardSize {
+			writeJSON(w, 400, map[string]interface{}{
+				"error":  fmt.Sprintf("board row %d has %d columns, want %d", i, len(row), boardSize),
+				"row":    i,
+				"length": len(row),
+			})
+			return false
+		}
+	}
+	return true
+}
+
+func rackAnalysisToResponse(a rackAnalysis) RackAnalysisResponse {
+	dups := make(map[string]int, len(a.duplicates))
+	for c, n := range a.duplicates {
+		dups[string(c)] = n
+	}
+	return RackAnalysisResponse{
+		Vowels:     a.vowels,
+		Consonants: a.consonants,
+		Blanks:     a.blanks,
+		Duplicates: dups,
+		Balance:    a.balance,
 	}
 }
 
@@ -120,20 +243,222 @@ func writeError(w http.ResponseWriter, status int, msg string) {
 	writeJSON(w, status, map[string]string{"error": msg})
 }
 
+// ── Analysis snapshots ───────────────────────────────────────────────────────
+
+// AnalysisSnapshot is a shared solver state: the board, the rack and the move
+// the user had selected, for deep-linking into the analysis UI.
+type AnalysisSnapshot struct {
+	Board        []string      `json:"board"`
+	Rack         string        `json:"rack"`
+	SelectedMove *MoveResponse `json:"selectedMove,omitempty"`
+	ExpiresAt    time.Time     `json:"expiresAt"`
+}
+
+// analysisStore keeps snapshots in memory for a fixed TTL. Entries are held
+// in insertion order, which is also expiry order, so expired ones are
+// trimmed from the front on every put; past max the oldest is dropped
+// early. Snapshots do not survive a restart. Safe for concurrent use.
+type analysisStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	order   *list.List // front = oldest; values are *analysisEntry
+	entries map[string]*list.Element
+}
+
+type analysisEntry struct {
+	token    string
+	snapshot AnalysisSnapshot
+}
+
+func newAnalysisStore(ttl time.Duration, max int) *analysisStore {
+	return &analysisStore{ttl: ttl, max: max, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// put stores snap and returns its token; snap.ExpiresAt is set here.
+func (s *analysisStore) put(snap AnalysisSnapshot) (string, AnalysisSnapshot) {
+	now := time.Now()
+	snap.ExpiresAt = now.Add(s.ttl).UTC()
+	token := generateShareToken()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for el := s.order.Front(); el != nil; el = s.order.Front() {
+		e := el.Value.(*analysisEntry)
+		if now.Before(e.snapshot.ExpiresAt) && s.order.Len() < s.max {
+			break
+		}
+		s.order.Remove(el)
+		delete(s.entries, e.token)
+	}
+	s.entries[token] = s.order.PushBack(&analysisEntry{token: token, snapshot: snap})
+	return token, snap
+}
+
+// get returns the snapshot for token if it exists and hasn't expired.
+func (s *analysisStore) get(token string) (AnalysisSnapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[token]
+	if !ok {
+		return AnalysisSnapshot{}, false
+	}
+	e := el.Value.(*analysisEntry)
+	if time.Now().After(e.snapshot.ExpiresAt) {
+		s.order.Remove(el)
+		delete(s.entries, token)
+		return AnalysisSnapshot{}, false
+	}
+	return e.snapshot, true
+}
+
+func handleShareAnalysis(store *analysisStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		var req AnalysisSnapshot
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+		if !checkBoardRows(w, req.Board) {
+			return
+		}
+		rack, ok := decodeRack(w, "rack", req.Rack)
+		if !ok {
+			return
+		}
+		if len(rack) > maxRackLen {
+			writeError(w, 400, fmt.Sprintf("rack must have at most %d tiles", maxRackLen))
+			return
+		}
+		token, snap := store.put(req)
+		writeJSON(w, 200, map[string]interface{}{"token": token, "expiresAt": snap.ExpiresAt})
+	}
+}
+
+func handleGetAnalysis(store *analysisStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		token := strings.TrimPrefix(r.URL.Path, "/api/analysis/")
+		snap, ok := store.get(token)
+		if !ok {
+			writeError(w, 404, "analysis not found or expired")
+			return
+		}
+		writeJSON(w, 200, snap)
+	}
+}
+
 // ── File-based board handlers (fallback when no DATABASE_URL) ────────────────
 
+// boardCache is a small LRU of parsed board files, keyed by path. An entry is
+// reused only while the file's mtime is unchanged, so edits made outside the
+// server (e.g. by the terminal solver) are picked up. Safe for concurrent use.
+type boardCache struct {
+	mu      sync.Mutex
+	max     int
+	order   *list.List // front = most recently used; values are *boardCacheEntry
+	entries map[string]*list.Element
+}
+
+type boardCacheEntry struct {
+	path    string
+	modTime time.Time
+	board   [][]byte
+}
+
+func newBoardCache(max int) *boardCache {
+	return &boardCache{max: max, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+var fileBoardCache = newBoardCache(64)
+
+// get returns the parsed board at path, from cache if the file hasn't changed.
+// The returned board is shared with the cache and must not be modified.
+func (c *boardCache) get(path string) ([][]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		c.invalidate(path)
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if el, ok := c.entries[path]; ok {
+		e := el.Value.(*boardCacheEntry)
+		if e.modTime.Equal(info.ModTime()) {
+			c.order.MoveToFront(el)
+			c.mu.Unlock()
+			return e.board, nil
+		}
+	}
+	c.mu.Unlock()
+
+	board, err := parseBoardFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[path]; ok {
+		el.Value = &boardCacheEntry{path: path, modTime: info.ModTime(), board: board}
+		c.order.MoveToFront(el)
+	} else {
+		c.entries[path] = c.order.PushFront(&boardCacheEntry{path: path, modTime: info.ModTime(), board: board})
+		if c.order.Len() > c.max {
+			oldest := c.order.Back()
+			c.order.Remove(oldest)
+			delete(c.entries, oldest.Value.(*boardCacheEntry).path)
+		}
+	}
+	return board, nil
+}
+
+// invalidate drops path from the cache. Called after every write.
+func (c *boardCache) invalidate(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[path]; ok {
+		c.order.Remove(el)
+		delete(c.entries, path)
+	}
+}
+
+// handleBoardFile routes /api/boards/{name} in file mode: GET loads the
+// board, POST saves it, and POST /api/boards/{name}/clone clones it.
+// boardFilePath reserves "clone" as a last segment, so a board's own name
+// never ends in /clone.
+func handleBoardFile(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/boards/")
+	if name == "" {
+		writeError(w, 400, "board name required")
+		return
+	}
+	if clone := strings.TrimSuffix(name, "/clone"); clone != name && r.Method == http.MethodPost {
+		handleCloneBoardFile(w, r, clone)
+		return
+	}
+	if r.Method == http.MethodGet {
+		handleGetBoardFile(w, r, name)
+	} else if r.Method == http.MethodPost {
+		handleSaveBoardFile(w, r, name)
+	} else {
+		writeError(w, 405, "method not allowed")
+	}
+}
+
 func handleListBoardsFile(w http.ResponseWriter, r *http.Request) {
-	entries, err := os.ReadDir("boards")
+	names, err := readBoardDir(boardsDir())
 	if err != nil {
 		writeJSON(w, 200, map[string][]string{"boards": {}})
 		return
 	}
-	var names []string
-	for _, e := range entries {
-		if !e.IsDir() && strings.HasSuffix(e.Name(), ".txt") {
-			names = append(names, strings.TrimSuffix(e.Name(), ".txt"))
-		}
-	}
 	if names == nil {
 		names = []string{}
 	}
@@ -141,15 +466,21 @@ func handleListBoardsFile(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleGetBoardFile(w http.ResponseWriter, r *http.Request, name string) {
-	path := filepath.Join("boards", name+".txt")
-	board, err := parseBoardFile(path)
+	path, err := boardFilePath(name)
+	if err != nil {
+		writeError(w, 400, err.Error())
+		return
+	}
+	board, err := fileBoardCache.get(path)
 	if err != nil {
 		writeError(w, 404, "board not found")
 		return
 	}
+	rows := boardToStrings(board)
 	writeJSON(w, 200, map[string]interface{}{
-		"name":  name,
-		"board": boardToStrings(board),
+		"name":      name,
+		"board":     rows,
+		"boardHash": boardHash(rows),
 	})
 }
 
@@ -161,19 +492,67 @@ func handleSaveBoardFile(w http.ResponseWriter, r *http.Request, name string) {
 		writeError(w, 400, "invalid JSON")
 		return
 	}
-	if len(req.Board) != 15 {
-		writeError(w, 400, "board must have 15 rows")
+	if !checkBoardRows(w, req.Board) {
+		return
+	}
+	path, err := boardFilePath(name)
+	if err != nil {
+		writeError(w, 400, err.Error())
 		return
 	}
 	board := stringsToBoard(req.Board)
-	path := filepath.Join("boards", name+".txt")
-	if err := saveBoard(board, path); err != nil {
+	err = saveBoard(board, path)
+	fileBoardCache.invalidate(path)
+	if err != nil {
 		writeError(w, 500, "failed to save board")
 		return
 	}
 	writeJSON(w, 200, map[string]bool{"ok": true})
 }
 
+// handleCloneBoardFile copies board file name to a new board (POST {name}).
+// An existing board of that name is not overwritten. Answers {ok, name}.
+func handleCloneBoardFile(w http.ResponseWriter, r *http.Request, name string) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, 400, "invalid JSON")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeError(w, 400, "name is required")
+		return
+	}
+	srcPath, err := boardFilePath(name)
+	if err != nil {
+		writeError(w, 400, err.Error())
+		return
+	}
+	path, err := boardFilePath(req.Name)
+	if err != nil {
+		writeError(w, 400, err.Error())
+		return
+	}
+	board, err := fileBoardCache.get(srcPath)
+	if err != nil {
+		writeError(w, 404, "board not found")
+		return
+	}
+	if _, err := os.Stat(path); err == nil {
+		writeError(w, 409, "a board with that name already exists")
+		return
+	}
+	err = saveBoard(board, path)
+	fileBoardCache.invalidate(path)
+	if err != nil {
+		writeError(w, 500, "failed to clone board")
+		return
+	}
+	writeJSON(w, 200, map[string]interface{}{"ok": true, "name": req.Name})
+}
+
 func handleCreateBoardFile(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Name string `json:"name"`
@@ -187,8 +566,14 @@ func handleCreateBoardFile(w http.ResponseWriter, r *http.Request) {
 		writeError(w, 400, "name is required")
 		return
 	}
-	path := filepath.Join("boards", req.Name+".txt")
-	if err := createBlankBoard(path); err != nil {
+	path, err := boardFilePath(req.Name)
+	if err != nil {
+		writeError(w, 400, err.Error())
+		return
+	}
+	err = createBlankBoard(path)
+	fileBoardCache.invalidate(path)
+	if err != nil {
 		writeError(w, 500, "failed to create board")
 		return
 	}
@@ -225,9 +610,13 @@ func handleGetBoardDB(db *DB) http.HandlerFunc {
 			return
 		}
 
-		// Route: /api/boards/shared/{token} — public, no auth required
+		// Route: /api/boards/shared/{token}[/moves] — public, no auth required
 		if strings.HasPrefix(id, "shared/") {
 			token := strings.TrimPrefix(id, "shared/")
+			if strings.HasSuffix(token, "/moves") {
+				handleGetSharedMovesDB(db, strings.TrimSuffix(token, "/moves"), w, r)
+				return
+			}
 			handleGetSharedBoardDB(db, token, w, r)
 			return
 		}
@@ -239,22 +628,73 @@ func handleGetBoardDB(db *DB) http.HandlerFunc {
 			return
 		}
 
+		// Route: /api/boards/{id}/annotations
+		if strings.HasSuffix(id, "/annotations") {
+			id = strings.TrimSuffix(id, "/annotations")
+			handleAnnotationsDB(db, id, w, r)
+			return
+		}
+
+		// Route: /api/boards/{id}/snapshots[/{snapID}/restore]
+		if i := strings.Index(id, "/snapshots"); i > 0 {
+			handleSnapshotsDB(db, id[:i], strings.TrimPrefix(id[i:], "/snapshots"), w, r)
+			return
+		}
+
+		// Route: /api/boards/{id}/moves
+		if strings.HasSuffix(id, "/moves") {
+			id = strings.TrimSuffix(id, "/moves")
+			handleBoardMovesDB(db, id, w, r)
+			return
+		}
+
+		// Route: /api/boards/{id}/lock
+		if strings.HasSuffix(id, "/lock") {
+			id = strings.TrimSuffix(id, "/lock")
+			handleBoardLockDB(db, id, w, r)
+			return
+		}
+
+		// Route: /api/boards/{id}/clone
+		if strings.HasSuffix(id, "/clone") {
+			id = strings.TrimSuffix(id, "/clone")
+			handleCloneBoardDB(db, id, w, r)
+			return
+		}
+
+		// Route: /api/boards/{id}/access
+		if strings.HasSuffix(id, "/access") {
+			id = strings.TrimSuffix(id, "/access")
+			handleBoardAccessDB(db, id, w, r)
+			return
+		}
+
 		userID := getUserIDFromContext(r.Context())
 
 		switch r.Method {
 		case http.MethodGet:
 			board, err := db.GetBoard(r.Context(), id)
+			if errors.Is(err, errBoardShape) {
+				writeBoardShapeError(w, err)
+				return
+			}
 			if err != nil {
 				writeError(w, 404, "board not found")
 				return
 			}
+			if userID != "" && !isOwner(userID, board.UserID) {
+				db.LogBoardAccess(board.ID, userID, false)
+			}
 			writeJSON(w, 200, map[string]interface{}{
-				"id":        board.ID,
-				"name":      board.Name,
-				"board":     board.Board,
-				"createdAt": board.CreatedAt,
-				"updatedAt": board.UpdatedAt,
-				"isOwner":   isOwner(userID, board.UserID),
+				"id":           board.ID,
+				"name":         board.Name,
+				"board":        board.Board,
+				"boardHash":    boardHash(board.Board),
+				"boardSize":    len(board.Board),
+				"sizeMismatch": len(board.Board) != boardSize,
+				"createdAt":    board.CreatedAt,
+				"updatedAt":    board.UpdatedAt,
+				"isOwner":      isOwner(userID, board.UserID),
 			})
 
 		case http.MethodPost:
@@ -265,8 +705,7 @@ func handleGetBoardDB(db *DB) http.HandlerFunc {
 				writeError(w, 400, "invalid JSON")
 				return
 			}
-			if len(req.Board) != 15 {
-				writeError(w, 400, "board must have 15 rows")
+			if !checkBoardRows(w, req.Board) {
 				return
 			}
 			if err := db.SaveBoard(r.Context(), id, userID, req.Board); err != nil {
@@ -288,7 +727,97 @@ func handleGetBoardDB(db *DB) http.HandlerFunc {
 	}
 }
 
-func handleCreateBoardDB(db *DB) http.HandlerFunc {
+// maxIdempotencyKeyLen bounds the Idempotency-Key header so the store can't be
+// filled with arbitrarily large keys.
+const maxIdempotencyKeyLen = 128
+
+// idempotencyStore remembers which board an Idempotency-Key created, so a
+// client retrying POST /api/boards gets the same board back instead of a
+// duplicate. Keys are scoped per user and forgotten after ttl; like
+// analysisStore, entries are held in insertion (= expiry) order and trimmed
+// from the front, with the oldest dropped early past max. A key whose create
+// is still in flight makes concurrent retries wait for it rather than insert
+// a second board, so trimming skips in-flight entries (the store can run
+// over max by however many creates are in flight). Safe for concurrent use.
+type idempotencyStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	order   *list.List // front = oldest; values are *idempotencyEntry
+	entries map[string]*list.Element
+}
+
+type idempotencyEntry struct {
+	key       string
+	expiresAt time.Time
+	done      chan struct{} // closed once id is set
+	id        string
+}
+
+func newIdempotencyStore(ttl time.Duration, max int) *idempotencyStore {
+	return &idempotencyStore{ttl: ttl, max: max, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// claim returns the entry for key. owner is true when the key was new (or had
+// expired) and the caller must create the board and call finish or release;
+// otherwise the caller waits on e.done and reuses e.id.
+func (s *idempotencyStore) claim(key string) (e *idempotencyEntry, owner bool) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[key]; ok {
+		e := el.Value.(*idempotencyEntry)
+		if now.Before(e.expiresAt) {
+			return e, false
+		}
+		s.order.Remove(el)
+		delete(s.entries, key)
+	}
+	for el := s.order.Front(); el != nil; {
+		old, next := el.Value.(*idempotencyEntry), el.Next()
+		if now.Before(old.expiresAt) && s.order.Len() < s.max {
+			break
+		}
+		if old.finished() {
+			s.order.Remove(el)
+			delete(s.entries, old.key)
+		}
+		el = next
+	}
+	e = &idempotencyEntry{key: key, expiresAt: now.Add(s.ttl), done: make(chan struct{})}
+	s.entries[key] = s.order.PushBack(e)
+	return e, true
+}
+
+// finished reports whether e's create has finished or been released.
+func (e *idempotencyEntry) finished() bool {
+	select {
+	case <-e.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// finish records the board created for a claimed entry and wakes waiters.
+func (s *idempotencyStore) finish(e *idempotencyEntry, id string) {
+	e.id = id
+	close(e.done)
+}
+
+// release forgets a claimed entry whose create failed, so a retry can try
+// again. Waiters see an empty id.
+func (s *idempotencyStore) release(e *idempotencyEntry) {
+	s.mu.Lock()
+	if el, ok := s.entries[e.key]; ok && el.Value == e {
+		s.order.Remove(el)
+		delete(s.entries, e.key)
+	}
+	s.mu.Unlock()
+	close(e.done)
+}
+
+func handleCreateBoardDB(db *DB, keys *idempotencyStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID := getUserIDFromContext(r.Context())
 
@@ -305,11 +834,41 @@ func handleCreateBoardDB(db *DB) http.HandlerFunc {
 			return
 		}
 
+		key := r.Header.Get("Idempotency-Key")
+		if len(key) > maxIdempotencyKeyLen {
+			writeError(w, 400, fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLen))
+			return
+		}
+		var claimed *idempotencyEntry
+		if key != "" {
+			e, owner := keys.claim(userID + "\x00" + key)
+			if !owner {
+				select {
+				case <-e.done:
+				case <-r.Context().Done():
+					return
+				}
+				if e.id == "" {
+					writeError(w, 500, "failed to create board")
+					return
+				}
+				writeJSON(w, 200, map[string]interface{}{"ok": true, "id": e.id})
+				return
+			}
+			claimed = e
+		}
+
 		id, err := db.CreateBoard(r.Context(), req.Name, userID)
 		if err != nil {
+			if claimed != nil {
+				keys.release(claimed)
+			}
 			writeError(w, 500, "failed to create board")
 			return
 		}
+		if claimed != nil {
+			keys.finish(claimed, id)
+		}
 		writeJSON(w, 200, map[string]interface{}{"ok": true, "id": id})
 	}
 }
@@ -320,17 +879,49 @@ func handleGetSharedBoardDB(db *DB, token string, w http.ResponseWriter, r *http
 		return
 	}
 	board, err := db.GetBoardByShareToken(r.Context(), token)
+	if errors.Is(err, errBoardShape) {
+		writeBoardShapeError(w, err)
+		return
+	}
 	if err != nil {
 		writeError(w, 404, "shared board not found")
 		return
 	}
+	userID := getUserIDFromContext(r.Context())
+	if !isOwner(userID, board.UserID) {
+		db.LogBoardAccess(board.ID, userID, true)
+	}
+	annotations, err := db.GetAnnotations(r.Context(), board.ID)
+	if err != nil {
+		writeError(w, 500, "failed to load annotations")
+		return
+	}
 	writeJSON(w, 200, map[string]interface{}{
-		"id":    board.ID,
-		"name":  board.Name,
-		"board": board.Board,
+		"id":           board.ID,
+		"name":         board.Name,
+		"board":        board.Board,
+		"boardHash":    boardHash(board.Board),
+		"boardSize":    len(board.Board),
+		"sizeMismatch": len(board.Board) != boardSize,
+		"annotations":  annotations,
 	})
 }
 
+// handleGetSharedMovesDB returns the move log of a shared board so
+// spectators can replay the game. Moves name players by number only.
+func handleGetSharedMovesDB(db *DB, token string, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, 405, "method not allowed")
+		return
+	}
+	moves, err := db.ListMovesByShareToken(r.Context(), token)
+	if err != nil {
+		writeError(w, 404, "shared board not found")
+		return
+	}
+	writeJSON(w, 200, map[string]interface{}{"moves": moves})
+}
+
 func handleShareBoardDB(db *DB, id string, w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeError(w, 405, "method not allowed")
@@ -358,71 +949,2073 @@ func handleShareBoardDB(db *DB, id string, w http.ResponseWriter, r *http.Reques
 	writeJSON(w, 200, map[string]string{"shareToken": token})
 }
 
-// ── Stateless computation handlers ──────────────────────────────────────────
-
-func handleSolve(wordlist map[uint64]struct{}, trie *TrieNode) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			writeError(w, 405, "method not allowed")
+// handleAnnotationsDB reads (GET, anyone with the board ID) or replaces
+// (POST, owner-only) a board's teaching annotations.
+func handleAnnotationsDB(db *DB, id string, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		annotations, err := db.GetAnnotations(r.Context(), id)
+		if err != nil {
+			writeError(w, 404, "board not found")
 			return
 		}
-		var req struct {
-			Board []string `json:"board"`
-			Rack  string   `json:"rack"`
-		}
+		writeJSON(w, 200, annotations)
+
+	case http.MethodPost:
+		var req Annotations
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			writeError(w, 400, "invalid JSON")
 			return
 		}
-		if len(req.Board) != 15 {
-			writeError(w, 400, "board must have 15 rows")
+		onBoard := func(p [2]int) bool { return p[0] >= 0 && p[0] < boardSize && p[1] >= 0 && p[1] < boardSize }
+		for _, n := range req.Notes {
+			if !onBoard([2]int{n.X, n.Y}) {
+				writeError(w, 400, "note position off the board")
+				return
+			}
+			if n.Text == "" || len(n.Text) > 500 {
+				writeError(w, 400, "note text must be 1-500 characters")
+				return
+			}
+		}
+		for _, a := range req.Arrows {
+			if !onBoard(a.From) || !onBoard(a.To) {
+				writeError(w, 400, "arrow endpoint off the board")
+				return
+			}
+		}
+		userID := getUserIDFromContext(r.Context())
+		if err := db.SaveAnnotations(r.Context(), id, userID, req); err != nil {
+			writeError(w, 404, "board not found or not owned by you")
 			return
 		}
-		board := stringsToBoard(req.Board)
-		rack := parseRack(req.Rack)
-
-		b := &Board{board: board, wordlist: wordlist, trie: trie}
-		moves := b.findTopNMoves(rack, 20)
+		writeJSON(w, 200, map[string]bool{"ok": true})
 
-		results := make([]MoveResponse, len(moves))
-		for i, m := range moves {
-			results[i] = bestMoveToResponse(b, m)
-		}
-		writeJSON(w, 200, map[string]interface{}{"moves": results})
+	default:
+		writeError(w, 405, "method not allowed")
 	}
 }
 
-func handleOpponent(wordlist map[uint64]struct{}, trie *TrieNode) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			writeError(w, 405, "method not allowed")
+// handleSnapshotsDB serves a board's snapshots. rest is the path after
+// "/snapshots": "" for GET (list) and POST {label} (take a snapshot), or
+// "/{snapID}/restore" for POST (roll the live board back). Owner-only.
+func handleSnapshotsDB(db *DB, id string, rest string, w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r.Context())
+
+	if rest != "" {
+		snapID, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(rest, "/"), "/restore"), 10, 64)
+		if err != nil || !strings.HasSuffix(rest, "/restore") {
+			writeError(w, 404, "not found")
+			return
+		}
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		if err := db.RestoreSnapshot(r.Context(), id, userID, snapID); err != nil {
+			writeError(w, 404, "snapshot not found or board not owned by you")
+			return
+		}
+		writeJSON(w, 200, map[string]bool{"ok": true})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		snaps, err := db.ListSnapshots(r.Context(), id, userID)
+		if err != nil {
+			writeError(w, 404, "board not found or not owned by you")
+			return
+		}
+		writeJSON(w, 200, map[string]interface{}{"snapshots": snaps})
+
+	case http.MethodPost:
+		var req struct {
+			Label string `json:"label"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+		req.Label = strings.TrimSpace(req.Label)
+		if req.Label == "" || len(req.Label) > 100 {
+			writeError(w, 400, "label must be 1-100 characters")
+			return
+		}
+		snap, err := db.SnapshotBoard(r.Context(), id, userID, req.Label)
+		if err != nil {
+			writeError(w, 404, "board not found or not owned by you")
+			return
+		}
+		writeJSON(w, 200, snap)
+
+	default:
+		writeError(w, 405, "method not allowed")
+	}
+}
+
+// handleBoardAccessDB returns the board's recent access log. Owner-only.
+func handleBoardAccessDB(db *DB, id string, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, 405, "method not allowed")
+		return
+	}
+
+	userID := getUserIDFromContext(r.Context())
+	accesses, err := db.ListBoardAccess(r.Context(), id, userID, 100)
+	if err != nil {
+		writeError(w, 404, "board not found or not owned by you")
+		return
+	}
+	writeJSON(w, 200, map[string]interface{}{"accesses": accesses})
+}
+
+// handleBoardMovesDB serves a board's move log: GET lists it in play order,
+// POST {x, y, dir, tiles, score, player} appends a play the client has just
+// applied. (x, y) is the first new tile and tiles the new tiles only, as in
+// /api/solve's moves. Owner-only.
+func handleBoardMovesDB(db *DB, id string, w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r.Context())
+
+	switch r.Method {
+	case http.MethodGet:
+		moves, err := db.ListMoves(r.Context(), id, userID)
+		if err != nil {
+			writeError(w, 404, "board not found or not owned by you")
+			return
+		}
+		writeJSON(w, 200, map[string]interface{}{"moves": moves})
+
+	case http.MethodPost:
+		var req struct {
+			X      int    `json:"x"`
+			Y      int    `json:"y"`
+			Dir    string `json:"dir"`
+			Tiles  string `json:"tiles"`
+			Score  int    `json:"score"`
+			Player int    `json:"player"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+		if req.X < 0 || req.X >= boardSize || req.Y < 0 || req.Y >= boardSize {
+			writeError(w, 400, "start square is off the board")
+			return
+		}
+		dir := DIR_HORIZ
+		if req.Dir == "V" {
+			dir = DIR_VERT
+		} else if req.Dir != "H" {
+			writeError(w, 400, `dir must be "H" or "V"`)
+			return
+		}
+		if req.Tiles == "" || len(req.Tiles) > maxRackLen {
+			writeError(w, 400, fmt.Sprintf("tiles must be 1-%d letters", maxRackLen))
+			return
+		}
+		for i := 0; i < len(req.Tiles); i++ {
+			if !alphabet.isTile(req.Tiles[i]) {
+				writeError(w, 400, "tiles must contain only letters (lowercase for blanks)")
+				return
+			}
+		}
+		if req.Player != 1 && req.Player != 2 {
+			writeError(w, 400, "player must be 1 or 2")
+			return
+		}
+		m := BestMove{x: req.X, y: req.Y, dir: dir, tiles: req.Tiles, score: req.Score}
+		if err := db.AppendMove(r.Context(), id, userID, m, req.Player); err != nil {
+			writeError(w, 404, "board not found or not owned by you")
+			return
+		}
+		writeJSON(w, 200, map[string]bool{"ok": true})
+
+	default:
+		writeError(w, 405, "method not allowed")
+	}
+}
+
+// defaultLockTTL and maxLockTTL bound how long an edit lock lasts when the
+// client asks for none or for too long.
+const (
+	defaultLockTTL = 5 * time.Minute
+	maxLockTTL     = time.Hour
+)
+
+// writeBoardShapeError answers a request for a stored board that fails
+// splitBoardData with a 422: the request is fine, the board it names isn't.
+func writeBoardShapeError(w http.ResponseWriter, err error) {
+	writeError(w, 422, err.Error()+"; save a complete board over it to repair it")
+}
+
+// handleCloneBoardDB copies a board into a new one owned by the caller
+// (POST {name, shareToken}). The caller must own the source, or send the
+// source's current share token. Answers {ok, id} with the new board's ID.
+func handleCloneBoardDB(db *DB, id string, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, 405, "method not allowed")
+		return
+	}
+	var req struct {
+		Name       string `json:"name"`
+		ShareToken string `json:"shareToken"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, 400, "invalid JSON")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeError(w, 400, "name is required")
+		return
+	}
+	userID := getUserIDFromContext(r.Context())
+	src, err := db.GetBoard(r.Context(), id)
+	if errors.Is(err, errBoardShape) {
+		writeBoardShapeError(w, err)
+		return
+	}
+	if err != nil {
+		writeError(w, 404, "board not found")
+		return
+	}
+	owned := isOwner(userID, src.UserID) || (userID == "" && src.UserID == nil)
+	shared := req.ShareToken != "" && src.ShareToken != nil && *src.ShareToken == req.ShareToken
+	if !owned && !shared {
+		writeError(w, 404, "board not found or not owned by you")
+		return
+	}
+	newID, err := db.CloneBoard(r.Context(), id, userID, req.Name)
+	if err != nil {
+		writeError(w, 500, "failed to clone board")
+		return
+	}
+	writeJSON(w, 200, map[string]interface{}{"ok": true, "id": newID})
+}
+
+// handleBoardLockDB takes (POST {ttlSeconds}) or releases (DELETE) a board's
+// edit lock. Signed-in owners only, since a lock needs a holder and only the
+// owner may edit the board. A lock held by someone else answers 423 with the
+// holder and its expiry.
+func handleBoardLockDB(db *DB, id string, w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r.Context())
+
+	switch r.Method {
+	case http.MethodPost:
+		var req struct {
+			TTLSeconds int `json:"ttlSeconds"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, 400, "invalid JSON")
+				return
+			}
+		}
+		if userID == "" {
+			writeError(w, 401, "sign in to lock a board")
+			return
+		}
+		ttl := defaultLockTTL
+		if req.TTLSeconds < 0 {
+			writeError(w, 400, "ttlSeconds must not be negative")
+			return
+		}
+		if req.TTLSeconds > 0 {
+			ttl = min(time.Duration(req.TTLSeconds)*time.Second, maxLockTTL)
+		}
+		if err := db.checkOwner(r.Context(), id, userID); err != nil {
+			writeError(w, 404, "board not found or not owned by you")
+			return
+		}
+		lock, err := db.AcquireLock(r.Context(), id, userID, ttl)
+		if errors.Is(err, errBoardLocked) {
+			writeJSON(w, 423, map[string]interface{}{
+				"error":     "board is locked",
+				"holder":    lock.UserID,
+				"expiresAt": lock.ExpiresAt,
+			})
+			return
+		}
+		if err != nil {
+			writeError(w, 404, "board not found")
+			return
+		}
+		writeJSON(w, 200, lock)
+
+	case http.MethodDelete:
+		if userID == "" {
+			writeError(w, 401, "sign in to lock a board")
+			return
+		}
+		if err := db.ReleaseLock(r.Context(), id, userID); err != nil {
+			writeError(w, 404, "no lock held by you")
+			return
+		}
+		writeJSON(w, 200, map[string]bool{"ok": true})
+
+	default:
+		writeError(w, 405, "method not allowed")
+	}
+}
+
+// ── Stateless computation handlers ──────────────────────────────────────────
+
+// defaultSolveCacheSize is how many /api/solve results solveCache keeps when
+// SOLVE_CACHE_SIZE is unset.
+const defaultSolveCacheSize = 1024
+
+// solveCache is an LRU of /api/solve results keyed by solveCacheKey, so the
+// web UI re-asking for the same position skips the search. hits and misses
+// are reported by /api/stats. A max of 0 disables it. Safe for concurrent use.
+//
+// entries is indexed by bucket(key), a 64-bit FNV hash. FNV collisions are
+// easy to produce, so each entry keeps its full key and get only returns it
+// when that matches; a colliding put replaces the entry.
+type solveCache struct {
+	mu      sync.Mutex
+	max     int
+	order   *list.List // front = most recently used; values are *solveCacheEntry
+	entries map[uint64]*list.Element
+	bucket  func(key string) uint64
+	hits    int
+	misses  int
+}
+
+// solveCacheEntry is everything handleSolve's response needs besides the
+// parts derived cheaply from the request itself. The slices are shared by
+// every hit and must not be modified.
+type solveCacheEntry struct {
+	key        string
+	moves      []MoveResponse
+	removed    [][2]int
+	unverified int
+}
+
+func newSolveCache(max int) *solveCache {
+	return &solveCache{max: max, order: list.New(), entries: make(map[uint64]*list.Element), bucket: solveCacheBucket}
+}
+
+// solveCacheSize reads SOLVE_CACHE_SIZE, falling back to
+// defaultSolveCacheSize when it is unset or not a non-negative integer.
+func solveCacheSize() int {
+	v := os.Getenv("SOLVE_CACHE_SIZE")
+	if v == "" {
+		return defaultSolveCacheSize
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		fmt.Fprintf(os.Stderr, "Warning: invalid SOLVE_CACHE_SIZE %q — using %d\n", v, defaultSolveCacheSize)
+		return defaultSolveCacheSize
+	}
+	return n
+}
+
+// solveCacheKey is the canonical form of a solve request: the parsed board,
+// the rack's tiles in sorted order and opts, which must encode every request
+// field that changes the moves.
+func solveCacheKey(board [][]byte, rack []byte, opts string) string {
+	var sb strings.Builder
+	for _, row := range boardToStrings(board) {
+		sb.WriteString(row)
+		sb.WriteByte('\n')
+	}
+	sb.WriteString(sortedRack(rack))
+	sb.WriteByte('\n')
+	sb.WriteString(opts)
+	return sb.String()
+}
+
+// solveCacheBucket is the FNV-1a hash of a solveCacheKey.
+func solveCacheBucket(key string) uint64 {
+	h := NewFNV()
+	h.AddString(key)
+	return h.Val()
+}
+
+// get returns the entry for key, counting a hit or a miss.
+func (c *solveCache) get(key string) (*solveCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[c.bucket(key)]; ok && el.Value.(*solveCacheEntry).key == key {
+		c.hits++
+		c.order.MoveToFront(el)
+		return el.Value.(*solveCacheEntry), true
+	}
+	c.misses++
+	return nil, false
+}
+
+// put stores e, evicting the least recently used entry past max.
+func (c *solveCache) put(e *solveCacheEntry) {
+	if c.max == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	bucket := c.bucket(e.key)
+	if el, ok := c.entries[bucket]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[bucket] = c.order.PushFront(e)
+	if c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, c.bucket(oldest.Value.(*solveCacheEntry).key))
+	}
+}
+
+// stats returns the cache's size, capacity and hit/miss counts.
+func (c *solveCache) stats() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return map[string]int{"size": c.order.Len(), "max": c.max, "hits": c.hits, "misses": c.misses}
+}
+
+// handleStats serves GET /api/stats: counters for the server's caches.
+func handleStats(cache *solveCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		writeJSON(w, 200, map[string]interface{}{"solveCache": cache.stats()})
+	}
+}
+
+func handleSolve(wordlist map[uint64]struct{}, trie *TrieNode, cache *solveCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		var req struct {
+			Board       []string `json:"board"`
+			Rack        string   `json:"rack"`
+			Sort        string   `json:"sort"`
+			MaxNewTiles int      `json:"maxNewTiles"`
+			MinScore    int      `json:"minScore"`
+			MinWordLen  int      `json:"minWordLen"`
+			// MaxBlanks caps the blanks a single move may use; 0 is
+			// no cap.
+			MaxBlanks int `json:"maxBlanks"`
+			// ShowPotential adds each move's best placement elsewhere.
+			ShowPotential bool `json:"showPotential"`
+			// Verify re-checks each move's words against the trie and
+			// drops any that only passed through a hash collision.
+			Verify bool `json:"verify"`
+			// AllowedWords, if non-empty, keeps only moves forming one
+			// of these words (e.g. a study list).
+			AllowedWords []string `json:"allowedWords"`
+			// Tentative lists tiles the user isn't sure of. Any that
+			// validateBoard flags as suspect are lifted before solving.
+			Tentative [][2]int `json:"tentative"`
+			// YIsVowel counts Y as a vowel in rackAnalysis.
+			YIsVowel bool `json:"yIsVowel"`
+			// EchoBoard returns the grid as parsed, to catch client
+			// desyncs.
+			EchoBoard bool `json:"echoBoard"`
+			// UseLeave ranks by score + leaveValue of the tiles kept
+			// (sort "score" only) and reports each move's leaveValue.
+			UseLeave bool `json:"useLeave"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+		if !checkBoardRows(w, req.Board) {
+			return
+		}
+		if req.Sort == "" {
+			req.Sort = "score"
+		}
+		if !validMoveSort(req.Sort) {
+			writeError(w, 400, "sort must be one of: "+strings.Join(moveSortModes, ", "))
+			return
+		}
+		if req.MaxNewTiles < 0 {
+			writeError(w, 400, "maxNewTiles must not be negative")
+			return
+		}
+		if req.MaxBlanks < 0 {
+			writeError(w, 400, "maxBlanks must not be negative")
+			return
+		}
+		if req.MinScore < 0 {
+			writeError(w, 400, "minScore must not be negative")
+			return
+		}
+		if req.MinWordLen < 0 {
+			writeError(w, 400, "minWordLen must not be negative")
+			return
+		}
+		board := stringsToBoard(req.Board)
+		var echoed []string
+		if req.EchoBoard {
+			// Taken before any tentative tiles are lifted.
+			echoed = boardToStrings(board)
+		}
+		// A rack shorter than rackSize (tiles partly unknown) is solved with
+		// exactly those tiles; it can never earn the bingo bonus.
+		rack, ok := decodeRack(w, "rack", req.Rack)
+		if !ok {
+			return
+		}
+
+		b := &Board{board: board, wordlist: wordlist, trie: trie, maxNewTiles: req.MaxNewTiles, maxBlanks: req.MaxBlanks, minScore: req.MinScore, minWordLen: req.MinWordLen}
+		// Keyed on the board before tentative tiles are lifted; which
+		// ones go depends only on the board and req.Tentative.
+		key := solveCacheKey(board, rack, fmt.Sprintf("%s|%d|%d|%d|%d|%t|%t|%t|%q|%v",
+			req.Sort, req.MaxNewTiles, req.MaxBlanks, req.MinScore, req.MinWordLen,
+			req.ShowPotential, req.Verify, req.UseLeave, req.AllowedWords, req.Tentative))
+		entry, hit := cache.get(key)
+		if hit {
+			// Lift the same tiles, so CSV notation matches a fresh solve.
+			for _, pos := range entry.removed {
+				board[pos[0]][pos[1]] = 0
+			}
+		} else {
+			removed := [][2]int{}
+			if len(req.Tentative) > 0 {
+				_, suspect, _ := b.validateBoard()
+				for _, pos := range req.Tentative {
+					x, y := pos[0], pos[1]
+					if x < 0 || x >= boardSize || y < 0 || y >= boardSize {
+						writeError(w, 400, "tentative position off the board")
+						return
+					}
+					if suspect[cti(x, y)] {
+						board[x][y] = 0
+						removed = append(removed, pos)
+					}
+				}
+			}
+			allowed := make(map[string]bool, len(req.AllowedWords))
+			for _, word := range req.AllowedWords {
+				// A word the alphabet can't spell stays in, matching nothing.
+				tiles, ok := alphabet.encode(strings.TrimSpace(word))
+				if !ok {
+					tiles = word
+				}
+				allowed[upperTiles(tiles)] = true
+			}
+			// Filter before taking the top 20 so a restricted list still
+			// fills up with lower-scoring matches.
+			moves, err := b.findAllMoves(rack)
+			if err != nil {
+				writeError(w, 400, err.Error())
+				return
+			}
+			moves = filterMovesByWord(b, moves, allowed)
+			unverified := 0
+			if req.Verify {
+				kept := moves[:0]
+				for _, m := range moves {
+					if b.verifyMove(m) {
+						kept = append(kept, m)
+					} else {
+						unverified++
+					}
+				}
+				moves = kept
+			}
+			// Sort before taking the top 20, so "word" lists the
+			// alphabetically first moves rather than the best 20 reordered.
+			if req.UseLeave && req.Sort == "score" {
+				b.sortByEquity(rack, moves)
+			} else {
+				sortMoves(b, moves, req.Sort)
+			}
+			if len(moves) > 20 {
+				moves = moves[:20]
+			}
+
+			results := make([]MoveResponse, len(moves))
+			for i, m := range moves {
+				results[i] = bestMoveToResponse(b, m)
+				if req.UseLeave {
+					leave, _ := rackLeave(rack, m.tiles)
+					v := leaveValue(leave)
+					results[i].LeaveValue = &v
+				}
+				if req.ShowPotential {
+					if alt, ok := b.bestElsewhere(m); ok {
+						ar := bestMoveToResponse(b, alt)
+						results[i].BestElsewhere = &ar
+					}
+				}
+			}
+			entry = &solveCacheEntry{key: key, moves: results, removed: removed, unverified: unverified}
+			cache.put(entry)
+		}
+		if wantsCSV(r) {
+			writeMovesCSV(w, b, entry.moves)
+			return
+		}
+		resp := map[string]interface{}{
+			"moves":        entry.moves,
+			"rackAnalysis": rackAnalysisToResponse(analyzeRack(rack, req.YIsVowel)),
+			"boardHash":    boardHash(req.Board),
+			"removed":      entry.removed,
+		}
+		if req.EchoBoard {
+			resp["board"] = echoed
+		}
+		if req.Verify {
+			resp["unverified"] = entry.unverified
+		}
+		writeJSON(w, 200, resp)
+	}
+}
+
+// wantsCSV reports whether a request asked for CSV instead of JSON, with
+// ?format=csv or an Accept header naming text/csv.
+func wantsCSV(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "csv" || strings.Contains(r.Header.Get("Accept"), "text/csv")
+}
+
+// moveNotation gives a move's standard coordinate: row then column letter
+// ("8H") for a horizontal word, column letter then row ("H8") for a vertical
+// one, taken from the first letter of the main word, not the first new tile.
+func moveNotation(b *Board, m MoveResponse) string {
+	x, y := m.X, m.Y
+	if m.Dir == "V" {
+		for y > 0 && b.board[x][y-1] != 0 {
+			y--
+		}
+		return fmt.Sprintf("%c%d", 'A'+x, y+1)
+	}
+	for x > 0 && b.board[x-1][y] != 0 {
+		x--
+	}
+	return fmt.Sprintf("%d%c", y+1, 'A'+x)
+}
+
+// writeMovesCSV writes moves as a CSV download: a header row, then one row
+// per move with the same values the JSON response carries.
+func writeMovesCSV(w http.ResponseWriter, b *Board, moves []MoveResponse) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="moves.csv"`)
+	w.WriteHeader(200)
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"word", "tiles", "score", "x", "y", "dir", "notation"})
+	for _, m := range moves {
+		cw.Write([]string{m.Word, m.Tiles, strconv.Itoa(m.Score), strconv.Itoa(m.X), strconv.Itoa(m.Y), m.Dir, moveNotation(b, m)})
+	}
+	cw.Flush()
+}
+
+func handleRackAnalysis() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		var req struct {
+			Rack     string `json:"rack"`
+			YIsVowel bool   `json:"yIsVowel"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+		rack, ok := decodeRack(w, "rack", req.Rack)
+		if !ok {
+			return
+		}
+		writeJSON(w, 200, rackAnalysisToResponse(analyzeRack(rack, req.YIsVowel)))
+	}
+}
+
+func handleOpponent(wordlist map[uint64]struct{}, trie *TrieNode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		var req struct {
+			Board   []string `json:"board"`
+			Word    string   `json:"word"`
+			Explain bool     `json:"explain"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+		if !checkBoardRows(w, req.Board) {
+			return
+		}
+		board := stringsToBoard(req.Board)
+
+		b := &Board{board: board, wordlist: wordlist, trie: trie}
+		placements := b.findOpponentPlacements(req.Word)
+
+		results := make([]MoveResponse, len(placements))
+		for i, m := range placements {
+			results[i] = bestMoveToResponse(b, m)
+		}
+		resp := map[string]interface{}{"placements": results}
+
+		// Diagnostic mode: when nothing fits, say why the closest attempts failed.
+		if req.Explain && len(placements) == 0 {
+			type failureResponse struct {
+				X      int    `json:"x"`
+				Y      int    `json:"y"`
+				Dir    string `json:"dir"`
+				Reason string `json:"reason"`
+			}
+			failures := b.explainPlacementFailures(req.Word, 5)
+			explained := make([]failureResponse, len(failures))
+			for i, f := range failures {
+				dirStr := "H"
+				if f.dir == DIR_VERT {
+					dirStr = "V"
+				}
+				explained[i] = failureResponse{X: f.x, Y: f.y, Dir: dirStr, Reason: f.reason}
+			}
+			resp["failures"] = explained
+		}
+		writeJSON(w, 200, resp)
+	}
+}
+
+// drawSearchTimeout bounds the per-tile searches of /api/best-possible and
+// /api/best-draw; tiles not reached in time are left out and the response
+// says complete: false.
+const drawSearchTimeout = 5 * time.Second
+
+type drawResult struct {
+	Tile string        `json:"tile"`
+	Move *MoveResponse `json:"move"` // nil if the completed rack has no play
+}
+
+func drawOutcomeToResult(b *Board, o drawOutcome) drawResult {
+	r := drawResult{Tile: string(o.tile)}
+	if o.ok {
+		mr := bestMoveToResponse(b, o.move)
+		r.Move = &mr
+	}
+	return r
+}
+
+// handleBestDraw ranks the tiles still unseen by how much drawing each would
+// raise the rack's top score — a guide to what an exchange is fishing for.
+func handleBestDraw(wordlist map[uint64]struct{}, trie *TrieNode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		var req struct {
+			Board []string `json:"board"`
+			Rack  string   `json:"rack"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+		if !checkBoardRows(w, req.Board) {
+			return
+		}
+		rack, ok := decodeRack(w, "rack", req.Rack)
+		if !ok {
+			return
+		}
+		if len(rack) >= rackSize {
+			writeError(w, 400, fmt.Sprintf("rack must have at most %d tiles", rackSize-1))
+			return
+		}
+
+		b := &Board{board: stringsToBoard(req.Board), wordlist: wordlist, trie: trie}
+		unseen, err := remainingTiles([]string{b.placedTiles(), string(rack)})
+		if err != nil {
+			writeError(w, 400, err.Error())
+			return
+		}
+
+		moves, err := b.findTopNMoves(rack, 1, false)
+		if err != nil {
+			writeError(w, 400, err.Error())
+			return
+		}
+		current := 0
+		if len(moves) > 0 {
+			current = moves[0].score
+		}
+		outcomes, complete := b.bestMovePerDraw(rack, unseen, time.Now().Add(drawSearchTimeout))
+		sort.SliceStable(outcomes, func(i, j int) bool { return outcomes[i].move.score > outcomes[j].move.score })
+
+		type drawGain struct {
+			drawResult
+			TopScore    int `json:"topScore"`
+			Improvement int `json:"improvement"`
+		}
+		draws := make([]drawGain, len(outcomes))
+		for i, o := range outcomes {
+			draws[i] = drawGain{drawResult: drawOutcomeToResult(b, o), TopScore: o.move.score, Improvement: o.move.score - current}
+		}
+		writeJSON(w, 200, map[string]interface{}{
+			"currentScore": current,
+			"draws":        draws,
+			"complete":     complete,
+		})
+	}
+}
+
+// blankOptionsTimeout bounds /api/blank-options; letters not reached in time
+// are left out and the response says complete: false.
+const blankOptionsTimeout = 5 * time.Second
+
+// handleBlankOptions answers "what should my blank be?": for each letter the
+// rack's blank could stand for, the best play that uses it as that letter,
+// highest score first.
+func handleBlankOptions(wordlist map[uint64]struct{}, trie *TrieNode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		var req struct {
+			Board []string `json:"board"`
+			Rack  string   `json:"rack"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+		if !checkBoardRows(w, req.Board) {
+			return
+		}
+		rack, ok := decodeRack(w, "rack", req.Rack)
+		if !ok {
+			return
+		}
+		if len(rack) > maxRackLen {
+			writeError(w, 400, fmt.Sprintf("rack must have at most %d tiles", maxRackLen))
+			return
+		}
+		if strings.IndexByte(string(rack), '*') < 0 {
+			writeError(w, 400, "rack must contain a blank (*)")
+			return
+		}
+
+		b := &Board{board: stringsToBoard(req.Board), wordlist: wordlist, trie: trie}
+		options, complete := b.bestMovePerBlank(rack, time.Now().Add(blankOptionsTimeout))
+		sort.SliceStable(options, func(i, j int) bool { return options[i].move.score > options[j].move.score })
+
+		type blankOptionResponse struct {
+			Letter string       `json:"letter"`
+			Move   MoveResponse `json:"move"`
+		}
+		results := make([]blankOptionResponse, len(options))
+		for i, o := range options {
+			results[i] = blankOptionResponse{Letter: string(o.letter), Move: bestMoveToResponse(b, o.move)}
+		}
+		writeJSON(w, 200, map[string]interface{}{
+			"options":  results,
+			"complete": complete,
+		})
+	}
+}
+
+// compareReplyTimeout bounds the opponent-reply sampling of
+// /api/compare-moves, shared by both moves.
+const compareReplyTimeout = 5 * time.Second
+
+// handleCompareMoves sets two plays from the same rack side by side for
+// coaching: score, the leave and its leaveValue, and with ply2 the expected
+// best opponent reply (see expectedReply). Equity is score + leaveValue, less
+// the expected reply when ply2 is on; the verdict names the higher-equity
+// move, so a lower-scoring play can win on its leave. Both moves must be
+// legal on the board and formable from the rack. With ply2, both moves face
+// the same sampled opponent racks so the comparison is like for like.
+func handleCompareMoves(wordlist map[uint64]struct{}, trie *TrieNode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		var req struct {
+			Board []string `json:"board"`
+			Rack  string   `json:"rack"`
+			Moves []struct {
+				X    int    `json:"x"`
+				Y    int    `json:"y"`
+				Dir  string `json:"dir"`
+				Word string `json:"word"` // full word from (x,y); lowercase = blank
+			} `json:"moves"`
+			Ply2 bool `json:"ply2"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+		if !checkBoardRows(w, req.Board) {
+			return
+		}
+		rack, ok := decodeRack(w, "rack", req.Rack)
+		if !ok {
+			return
+		}
+		if len(rack) > maxRackLen {
+			writeError(w, 400, fmt.Sprintf("rack must have at most %d tiles", maxRackLen))
+			return
+		}
+		if len(req.Moves) != 2 {
+			writeError(w, 400, "moves must hold exactly two moves")
+			return
+		}
+
+		b := &Board{board: stringsToBoard(req.Board), wordlist: wordlist, trie: trie}
+		var unseen map[byte]int
+		if req.Ply2 {
+			var err error
+			unseen, err = remainingTiles([]string{b.placedTiles(), string(rack)})
+			if err != nil {
+				writeError(w, 400, err.Error())
+				return
+			}
+		}
+		deadline := time.Now().Add(compareReplyTimeout)
+
+		type comparedMove struct {
+			Move          MoveResponse `json:"move"`
+			Leave         string       `json:"leave"`
+			LeaveValue    float64      `json:"leaveValue"`
+			ExpectedReply *float64     `json:"expectedReply,omitempty"`
+			ReplySamples  int          `json:"replySamples,omitempty"`
+			Equity        float64      `json:"equity"`
+		}
+		labels := [2]string{"A", "B"}
+		var compared [2]comparedMove
+		for i, mv := range req.Moves {
+			if mv.X < 0 || mv.X >= boardSize || mv.Y < 0 || mv.Y >= boardSize {
+				writeError(w, 400, fmt.Sprintf("move %s starts off the board", labels[i]))
+				return
+			}
+			dir := DIR_HORIZ
+			if mv.Dir == "V" {
+				dir = DIR_VERT
+			} else if mv.Dir != "H" {
+				writeError(w, 400, fmt.Sprintf(`move %s: dir must be "H" or "V"`, labels[i]))
+				return
+			}
+			m, problems := b.validatePlay(mv.Word, mv.X, mv.Y, dir)
+			if len(problems) > 0 {
+				writeJSON(w, 400, map[string]interface{}{
+					"error":    fmt.Sprintf("move %s is not legal", labels[i]),
+					"problems": problems,
+				})
+				return
+			}
+			leave, ok := rackLeave(rack, m.tiles)
+			if !ok {
+				writeError(w, 400, fmt.Sprintf("move %s uses tiles not on the rack", labels[i]))
+				return
+			}
+			c := comparedMove{
+				Move:       bestMoveToResponse(b, m),
+				Leave:      string(leave),
+				LeaveValue: leaveValue(leave),
+			}
+			c.Equity = float64(m.score) + c.LeaveValue
+			if req.Ply2 {
+				avg, n := b.expectedReply(m, unseen, rand.New(rand.NewSource(1)), deadline)
+				c.ExpectedReply, c.ReplySamples = &avg, n
+				c.Equity -= avg
+			}
+			compared[i] = c
+		}
+
+		verdict := "equal"
+		if compared[0].Equity > compared[1].Equity {
+			verdict = "A"
+		} else if compared[1].Equity > compared[0].Equity {
+			verdict = "B"
+		}
+		writeJSON(w, 200, map[string]interface{}{
+			"moves":   compared,
+			"verdict": verdict,
+			"margin":  math.Abs(compared[0].Equity - compared[1].Equity),
+		})
+	}
+}
+
+// hasBingoTimeout bounds /api/has-bingo; it answers "no" with complete:
+// false if the search runs out of time.
+const hasBingoTimeout = 2 * time.Second
+
+// handleHooks lists, for each word on the board, the single letters that
+// can be played just before or after it to make a longer valid word.
+func handleHooks(wordlist map[uint64]struct{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		var req struct {
+			Board []string `json:"board"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+		if !checkBoardRows(w, req.Board) {
+			return
+		}
+
+		type hookResponse struct {
+			Word  string `json:"word"`
+			X     int    `json:"x"`
+			Y     int    `json:"y"`
+			Dir   string `json:"dir"`
+			Front string `json:"front"` // letters that can go before the word
+			Back  string `json:"back"`  // letters that can go after it
+		}
+		b := &Board{board: stringsToBoard(req.Board), wordlist: wordlist}
+		hooks := []hookResponse{}
+		for _, h := range b.findHooks() {
+			dir := "H"
+			if h.dir == DIR_VERT {
+				dir = "V"
+			}
+			hooks = append(hooks, hookResponse{
+				Word: h.word, X: h.x, Y: h.y, Dir: dir,
+				Front: string(h.front), Back: string(h.back),
+			})
+		}
+		writeJSON(w, 200, map[string]interface{}{"hooks": hooks})
+	}
+}
+
+// handleTransform rotates or reflects a board. scoresPreserved reports
+// whether the active ruleset's premium layout is symmetric under op, i.e.
+// whether every play scores the same on the result.
+func handleTransform() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		var req struct {
+			Board []string `json:"board"`
+			Op    string   `json:"op"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+		if !checkBoardRows(w, req.Board) {
+			return
+		}
+		t, ok := boardTransforms[req.Op]
+		if !ok {
+			writeError(w, 400, "op must be one of: rotate90, rotate180, rotate270, flipH, flipV")
+			return
+		}
+		writeJSON(w, 200, map[string]interface{}{
+			"board":           boardToStrings(t(stringsToBoard(req.Board))),
+			"scoresPreserved": premiumsInvariant(t),
+		})
+	}
+}
+
+// handleHasBingo answers "is there a bingo?" without a full solve: the
+// search keeps only bingos and stops at the first one.
+func handleHasBingo(wordlist map[uint64]struct{}, trie *TrieNode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		var req struct {
+			Board []string `json:"board"`
+			Rack  string   `json:"rack"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+		if !checkBoardRows(w, req.Board) {
+			return
+		}
+		rack, ok := decodeRack(w, "rack", req.Rack)
+		if !ok {
+			return
+		}
+		if len(rack) > maxRackLen {
+			writeError(w, 400, fmt.Sprintf("rack must have at most %d tiles", maxRackLen))
+			return
+		}
+
+		b := &Board{board: stringsToBoard(req.Board), wordlist: wordlist, trie: trie,
+			bingoOnly: true, deadline: time.Now().Add(hasBingoTimeout)}
+		resp := map[string]interface{}{"hasBingo": false, "example": nil, "complete": true}
+		if len(rack) >= rackSize {
+			if moves := b.generateMoves(rack); len(moves) > 0 {
+				resp["hasBingo"] = true
+				resp["example"] = bestMoveToResponse(b, moves[0])
+			} else if time.Now().After(b.deadline) {
+				resp["complete"] = false
+			}
+		}
+		writeJSON(w, 200, resp)
+	}
+}
+
+// handleBestPossible answers "if I drew one more tile, what's the best I
+// could play?". For each distinct tile still unseen (distribution minus the
+// board minus partialRack) it completes the rack and solves, returning the
+// best move per tile and the overall best.
+func handleBestPossible(wordlist map[uint64]struct{}, trie *TrieNode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		var req struct {
+			Board       []string `json:"board"`
+			PartialRack string   `json:"partialRack"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+		if !checkBoardRows(w, req.Board) {
+			return
+		}
+		partial, ok := decodeRack(w, "partialRack", req.PartialRack)
+		if !ok {
+			return
+		}
+		if len(partial) >= rackSize {
+			writeError(w, 400, fmt.Sprintf("partialRack must have at most %d tiles", rackSize-1))
+			return
+		}
+
+		b := &Board{board: stringsToBoard(req.Board), wordlist: wordlist, trie: trie}
+		unseen, err := remainingTiles([]string{b.placedTiles(), string(partial)})
+		if err != nil {
+			writeError(w, 400, err.Error())
+			return
+		}
+
+		outcomes, complete := b.bestMovePerDraw(partial, unseen, time.Now().Add(drawSearchTimeout))
+		results := make([]drawResult, len(outcomes))
+		var best *drawResult
+		for i, o := range outcomes {
+			results[i] = drawOutcomeToResult(b, o)
+			if o.ok && (best == nil || o.move.score > best.Move.Score) {
+				best = &results[i]
+			}
+		}
+		resp := map[string]interface{}{"tiles": results, "best": nil, "complete": complete}
+		if best != nil {
+			resp["best"] = *best
+		}
+		writeJSON(w, 200, resp)
+	}
+}
+
+// handlePuzzleCheck checks that a board+rack puzzle isn't trivial: the rack
+// must have at least minBingos (default 2) distinct bingos on the board.
+func handlePuzzleCheck(wordlist map[uint64]struct{}, trie *TrieNode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		var req struct {
+			Board     []string `json:"board"`
+			Rack      string   `json:"rack"`
+			MinBingos int      `json:"minBingos"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+		if !checkBoardRows(w, req.Board) {
+			return
+		}
+		rack, ok := decodeRack(w, "rack", req.Rack)
+		if !ok {
+			return
+		}
+		if len(rack) != rackSize {
+			writeError(w, 400, fmt.Sprintf("rack must have %d tiles", rackSize))
+			return
+		}
+		if req.MinBingos <= 0 {
+			req.MinBingos = 2
+		}
+		b := &Board{board: stringsToBoard(req.Board), wordlist: wordlist, trie: trie}
+		words := b.distinctBingos(rack)
+		if words == nil {
+			words = []string{}
+		}
+		writeJSON(w, 200, map[string]interface{}{
+			"bingos": len(words),
+			"words":  words,
+			"valid":  len(words) >= req.MinBingos,
+		})
+	}
+}
+
+// handleValidateBoard reports runs on the board that aren't words, the tiles
+// most likely to be mis-entered, and tiles not connected to the main group.
+// If tentative positions are given, only those tiles can be flagged; the rest
+// are treated as confirmed.
+func handleValidateBoard(wordlist map[uint64]struct{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		var req struct {
+			Board     []string `json:"board"`
+			Tentative [][2]int `json:"tentative"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+		if !checkBoardRows(w, req.Board) {
+			return
+		}
+		b := &Board{board: stringsToBoard(req.Board), wordlist: wordlist}
+		invalid, suspect, disconnected := b.validateBoard()
+
+		type invalidWord struct {
+			X    int    `json:"x"`
+			Y    int    `json:"y"`
+			Dir  string `json:"dir"`
+			Word string `json:"word"`
+		}
+		words := make([]invalidWord, len(invalid))
+		for i, bw := range invalid {
+			dirStr := "H"
+			if bw.dir == DIR_VERT {
+				dirStr = "V"
+			}
+			words[i] = invalidWord{X: bw.x, Y: bw.y, Dir: dirStr, Word: bw.word}
+		}
+
+		suspectTiles := [][2]int{}
+		if len(req.Tentative) > 0 {
+			for _, pos := range req.Tentative {
+				if pos[0] >= 0 && pos[0] < boardSize && pos[1] >= 0 && pos[1] < boardSize && suspect[cti(pos[0], pos[1])] {
+					suspectTiles = append(suspectTiles, pos)
+				}
+			}
+		} else {
+			for i := 0; i < boardSize*boardSize; i++ {
+				if suspect[i] {
+					suspectTiles = append(suspectTiles, [2]int{i % boardSize, i / boardSize})
+				}
+			}
+		}
+		disconnectedTiles := [][2]int{}
+		for i := 0; i < boardSize*boardSize; i++ {
+			if disconnected[i] {
+				disconnectedTiles = append(disconnectedTiles, [2]int{i % boardSize, i / boardSize})
+			}
+		}
+		writeJSON(w, 200, map[string]interface{}{
+			"valid":             len(invalid) == 0 && len(disconnectedTiles) == 0,
+			"invalidWords":      words,
+			"suspectTiles":      suspectTiles,
+			"disconnectedTiles": disconnectedTiles,
+		})
+	}
+}
+
+// handleRevalue scores every word on a board under both the active ruleset
+// and a target one (see runValue), to show how a position revalues when moved
+// to a different premium layout. The board comes back unchanged; nothing
+// stored is touched.
+func handleRevalue() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		var req struct {
+			Board   []string `json:"board"`
+			Ruleset string   `json:"ruleset"` // key in rulesets.json or display name
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+		if !checkBoardRows(w, req.Board) {
+			return
+		}
+		def, ok := findRuleset(req.Ruleset)
+		if !ok {
+			writeError(w, 400, fmt.Sprintf("unknown ruleset %q", req.Ruleset))
+			return
+		}
+		if def.size() != boardSize {
+			writeError(w, 400, fmt.Sprintf("ruleset %q is %d×%d; the board is %d×%d", req.Ruleset, def.size(), def.size(), boardSize, boardSize))
+			return
+		}
+		if err := def.validate(); err != nil {
+			writeError(w, 400, fmt.Sprintf("ruleset %q: %v", req.Ruleset, err))
+			return
+		}
+
+		type wordValue struct {
+			X        int    `json:"x"`
+			Y        int    `json:"y"`
+			Dir      string `json:"dir"`
+			Word     string `json:"word"` // lowercase = blank
+			Score    int    `json:"score"`
+			NewScore int    `json:"newScore"`
+		}
+		b := &Board{board: stringsToBoard(req.Board)}
+		from, to := activeScoringTable(), def.scoringTable()
+		words := []wordValue{}
+		total, newTotal := 0, 0
+		for _, run := range b.boardRuns() {
+			dirStr := "H"
+			if run.dir == DIR_VERT {
+				dirStr = "V"
+			}
+			wv := wordValue{X: run.x, Y: run.y, Dir: dirStr, Word: run.word,
+				Score: runValue(run, &from), NewScore: runValue(run, &to)}
+			total += wv.Score
+			newTotal += wv.NewScore
+			words = append(words, wv)
+		}
+		writeJSON(w, 200, map[string]interface{}{
+			"board":    boardToStrings(b.board),
+			"ruleset":  def.Name,
+			"words":    words,
+			"total":    total,
+			"newTotal": newTotal,
+		})
+	}
+}
+
+// handleHotspots ranks a board's most promising empty squares (see findHotspots).
+func handleHotspots() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		var req struct {
+			Board []string `json:"board"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+		if !checkBoardRows(w, req.Board) {
+			return
+		}
+		b := &Board{board: stringsToBoard(req.Board)}
+		type hotspotResponse struct {
+			X     int `json:"x"`
+			Y     int `json:"y"`
+			Score int `json:"score"`
+		}
+		spots := b.findHotspots(10)
+		results := make([]hotspotResponse, len(spots))
+		for i, h := range spots {
+			results[i] = hotspotResponse{X: h.x, Y: h.y, Score: h.heat}
+		}
+		writeJSON(w, 200, map[string]interface{}{"hotspots": results})
+	}
+}
+
+// handleScore scores a play the user chose rather than one the solver found:
+// tiles laid from (x, y) in dir, skipping squares already filled, as in a
+// BestMove. It reports the total (with any bingo bonus), each word formed
+// with its own score (see scoreBreakdown), and valid, true when every one of
+// those words is in the dictionary. Placement rules beyond fitting on empty
+// squares (connection, the opening square) aren't checked.
+func handleScore(wordlist map[uint64]struct{}, trie *TrieNode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		var req struct {
+			Board []string `json:"board"`
+			X     int      `json:"x"`
+			Y     int      `json:"y"`
+			Dir   string   `json:"dir"`
+			Tiles string   `json:"tiles"` // new tiles only; lowercase = blank
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+		if !checkBoardRows(w, req.Board) {
+			return
+		}
+		if req.Tiles == "" {
+			writeError(w, 400, "tiles is required")
+			return
+		}
+		for i := 0; i < len(req.Tiles); i++ {
+			if !alphabet.isTile(req.Tiles[i]) {
+				writeError(w, 400, "tiles must contain only letters (lowercase for blanks)")
+				return
+			}
+		}
+		dir := DIR_HORIZ
+		if req.Dir == "V" {
+			dir = DIR_VERT
+		} else if req.Dir != "H" {
+			writeError(w, 400, `dir must be "H" or "V"`)
+			return
+		}
+		if req.X < 0 || req.X >= boardSize || req.Y < 0 || req.Y >= boardSize {
+			writeError(w, 400, "start square is off the board")
+			return
+		}
+		b := &Board{board: stringsToBoard(req.Board), wordlist: wordlist, trie: trie}
+		if t := b.board[req.X][req.Y]; t != 0 {
+			writeError(w, 400, fmt.Sprintf("start square (%d,%d) already holds %c", req.X, req.Y, t))
+			return
+		}
+		if !b.fitsOnBoard(req.X, req.Y, len(req.Tiles), dir) {
+			writeError(w, 400, "tiles run off the board")
+			return
+		}
+
+		m := BestMove{x: req.X, y: req.Y, dir: dir, tiles: req.Tiles}
+		m.score = b.scoreMove(m.x, m.y, m.tiles, m.dir)
+		if isBingo(rackSize, len(m.tiles)) {
+			m.score += bingoBonus
+		}
+		type wordScore struct {
+			Word  string `json:"word"`
+			Score int    `json:"score"`
+			Valid bool   `json:"valid"`
+		}
+		valid := true
+		words := []wordScore{}
+		for _, sw := range b.scoreBreakdown(m) {
+			words = append(words, wordScore{Word: sw.word, Score: sw.score, Valid: sw.valid})
+			valid = valid && sw.valid
+		}
+		writeJSON(w, 200, map[string]interface{}{
+			"score": m.score,
+			"bingo": isBingo(rackSize, len(m.tiles)),
+			"valid": valid && len(words) > 0,
+			"words": words,
+		})
+	}
+}
+
+// handleEvaluate describes a position without a rack: its openness (see
+// evaluateOpenness) and the words on it, as scanned by boardRuns.
+func handleEvaluate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		var req struct {
+			Board []string `json:"board"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+		if !checkBoardRows(w, req.Board) {
+			return
+		}
+		b := &Board{board: stringsToBoard(req.Board)}
+		type wordResponse struct {
+			X    int    `json:"x"`
+			Y    int    `json:"y"`
+			Dir  string `json:"dir"`
+			Word string `json:"word"`
+		}
+		runs := b.boardRuns()
+		words := make([]wordResponse, len(runs))
+		for i, run := range runs {
+			words[i] = wordResponse{X: run.x, Y: run.y, Dir: "H", Word: run.word}
+			if run.dir == DIR_VERT {
+				words[i].Dir = "V"
+			}
+		}
+		openness, anchors := b.evaluateOpenness()
+		writeJSON(w, 200, map[string]interface{}{
+			"openness": openness,
+			"anchors":  anchors,
+			"words":    words,
+		})
+	}
+}
+
+// handleTiles returns the full tile distribution with point values, in the
+// alphabet's tile order followed by the blank.
+func handleTiles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		counts, _ := remainingTiles(nil)
+		tiles := make([]TileResponse, 0, alphabet.size()+1)
+		for c := byte('A'); c <= alphabet.lastTile(); c++ {
+			if counts[c] > 0 {
+				tiles = append(tiles, TileResponse{Letter: string(c), Count: counts[c], Points: tilePoints[c]})
+			}
+		}
+		tiles = append(tiles, TileResponse{Letter: "*", Count: counts['*'], Points: 0})
+		writeJSON(w, 200, map[string]interface{}{"tiles": tiles, "total": len(startTiles)})
+	}
+}
+
+// handleWordScore returns the face value of a word: the sum of its letter
+// points under the active ruleset, with no board and no premium squares.
+// Also reports whether playing all its letters from a full rack would earn the bingo
+// bonus.
+func handleWordScore() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		word := strings.TrimSpace(r.URL.Query().Get("word"))
+		if word == "" {
+			writeError(w, 400, "word is required")
+			return
+		}
+		tiles, ok := alphabet.encode(word)
+		if !ok {
+			writeError(w, 400, "word must contain only letters of the ruleset's alphabet")
+			return
+		}
+		tiles = upperTiles(tiles)
+		word = alphabet.decode(tiles)
+		score := 0
+		for i := 0; i < len(tiles); i++ {
+			score += tilePoints[tiles[i]]
+		}
+		bingo := isBingo(rackSize, len(tiles))
+		resp := map[string]interface{}{
+			"word":  word,
+			"score": score,
+			"bingo": bingo,
+		}
+		if bingo {
+			resp["bingoBonus"] = bingoBonus
+		}
+		writeJSON(w, 200, resp)
+	}
+}
+
+// handleWordTiles shows how each tile of word would score if played at
+// (x, y) in dir: its letter, face value, the premium beneath it and its value
+// after that premium, plus the word multiplier. Only newly placed tiles use
+// premiums; letters already on the board must match word and count at face
+// value. The dictionary and cross-words aren't checked — this is the per-cell
+// view behind a move's main-word score, for tooltips.
+func handleWordTiles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		var req struct {
+			Board []string `json:"board"`
+			Word  string   `json:"word"` // lowercase = blank
+			X     int      `json:"x"`
+			Y     int      `json:"y"`
+			Dir   string   `json:"dir"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+		if !checkBoardRows(w, req.Board) {
+			return
+		}
+		word := strings.TrimSpace(req.Word)
+		if word == "" {
+			writeError(w, 400, "word is required")
+			return
+		}
+		for i := 0; i < len(word); i++ {
+			if !alphabet.isTile(word[i]) {
+				writeError(w, 400, "word must contain only letters (lowercase for blanks)")
+				return
+			}
+		}
+		dx, dy := 1, 0
+		if req.Dir == "V" {
+			dx, dy = 0, 1
+		} else if req.Dir != "H" {
+			writeError(w, 400, `dir must be "H" or "V"`)
+			return
+		}
+		endX, endY := req.X+dx*(len(word)-1), req.Y+dy*(len(word)-1)
+		if req.X < 0 || req.Y < 0 || endX >= boardSize || endY >= boardSize {
+			writeError(w, 400, "word does not fit on the board there")
+			return
+		}
+
+		type tileValue struct {
+			X              int    `json:"x"`
+			Y              int    `json:"y"`
+			Letter         string `json:"letter"`
+			Existing       bool   `json:"existing"` // already on the board
+			BaseValue      int    `json:"baseValue"`
+			Premium        string `json:"premium,omitempty"` // "DL", "TL", "DW" or "TW"
+			EffectiveValue int    `json:"effectiveValue"`
+		}
+		board := stringsToBoard(req.Board)
+		tiles := make([]tileValue, len(word))
+		sum, wordMult := 0, 1
+		for i := 0; i < len(word); i++ {
+			x, y := req.X+dx*i, req.Y+dy*i
+			t := tileValue{X: x, Y: y, Letter: string(word[i])}
+			if have := board[x][y]; have != 0 {
+				if have|0x20 != word[i]|0x20 {
+					writeError(w, 400, fmt.Sprintf("(%d,%d) holds %c, not %c", x, y, have, word[i]))
+					return
+				}
+				t.Letter, t.Existing = string(have), true
+			}
+			t.BaseValue = tilePoints[t.Letter[0]]
+			t.EffectiveValue = t.BaseValue
+			if idx := cti(x, y); !t.Existing {
+				switch {
+				case dw[idx]:
+					t.Premium = "DW"
+					wordMult *= 2
+				case tw[idx]:
+					t.Premium = "TW"
+					wordMult *= 3
+				case dl[idx]:
+					t.Premium = "DL"
+					t.EffectiveValue *= 2
+				case tl[idx]:
+					t.Premium = "TL"
+					t.EffectiveValue *= 3
+				}
+			}
+			sum += t.EffectiveValue
+			tiles[i] = t
+		}
+		writeJSON(w, 200, map[string]interface{}{
+			"tiles":          tiles,
+			"wordMultiplier": wordMult,
+			"score":          sum * wordMult,
+		})
+	}
+}
+
+// maxStemWords caps how many bingos /api/stems lists per completing letter.
+const maxStemWords = 25
+
+// handleStems is a study tool: for a rack one tile short of full, it lists
+// each letter that completes a bingo and the words it makes (see bingoStems).
+func handleStems(trie *TrieNode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
 			return
 		}
 		var req struct {
-			Board []string `json:"board"`
-			Word  string   `json:"word"`
+			Rack string `json:"rack"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			writeError(w, 400, "invalid JSON")
 			return
 		}
-		if len(req.Board) != 15 {
-			writeError(w, 400, "board must have 15 rows")
+		rack, ok := decodeRack(w, "rack", req.Rack)
+		if !ok {
 			return
 		}
-		board := stringsToBoard(req.Board)
+		if len(rack) != rackSize-1 {
+			writeError(w, 400, fmt.Sprintf("rack must have %d tiles", rackSize-1))
+			return
+		}
+		if trie == nil {
+			writeError(w, 503, "trie not loaded")
+			return
+		}
+		stems, truncated := bingoStems(rack, trie, maxStemWords)
+		out := make(map[string][]string, len(stems))
+		for c, words := range stems {
+			out[string(c)] = words
+		}
+		writeJSON(w, 200, map[string]interface{}{"stems": out, "truncated": truncated})
+	}
+}
 
-		b := &Board{board: board, wordlist: wordlist, trie: trie}
-		placements := b.findOpponentPlacements(req.Word)
-		sort.Slice(placements, func(i, j int) bool {
-			return placements[i].score > placements[j].score
+// handleValidate checks a single word against the dictionary, with the same
+// FNV lookup the solver uses, so a word reported valid is one it will play.
+// The word is typed in the ruleset's alphabet (see Alphabet.encode). A word
+// dropped by exclusions.txt is invalid and also reported as excluded.
+func handleValidate(wordlist, excluded map[uint64]struct{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		var req struct {
+			Word string `json:"word"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+		word := strings.TrimSpace(req.Word)
+		tiles, ok := alphabet.encode(word)
+		if !ok {
+			writeError(w, 400, "word must contain only letters of the ruleset's alphabet")
+			return
+		}
+		tiles = upperTiles(tiles)
+		_, valid := wordlist[wordHash(tiles)]
+		resp := map[string]interface{}{
+			"word":  alphabet.decode(tiles),
+			"valid": len(tiles) >= 2 && valid,
+		}
+		if isExcluded(excluded, tiles) {
+			resp["excluded"] = true
+		}
+		writeJSON(w, 200, resp)
+	}
+}
+
+// handleDefine looks up a word in definitions.txt: GET /api/define?word=.
+// Answers 503 when no definitions were loaded and 404 for an unknown word.
+func handleDefine(defs map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		word := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("word")))
+		if word == "" {
+			writeError(w, 400, "word is required")
+			return
+		}
+		if defs == nil {
+			writeError(w, 503, "definitions not loaded")
+			return
+		}
+		def, ok := defs[word]
+		if !ok {
+			writeError(w, 404, "no definition for "+word)
+			return
+		}
+		writeJSON(w, 200, map[string]string{"word": word, "definition": def})
+	}
+}
+
+// handleLongest returns the longest dictionary word spellable from a rack on
+// its own, with no board: GET /api/longest?rack=.
+func handleLongest(trie *TrieNode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		rack, ok := decodeRack(w, "rack", r.URL.Query().Get("rack"))
+		if !ok {
+			return
+		}
+		if len(rack) == 0 {
+			writeError(w, 400, "rack is required")
+			return
+		}
+		if len(rack) > maxRackLen {
+			writeError(w, 400, fmt.Sprintf("rack must have at most %d tiles", maxRackLen))
+			return
+		}
+		if trie == nil {
+			writeError(w, 503, "trie not loaded")
+			return
+		}
+		word := longestPlayable(rack, trie)
+		writeJSON(w, 200, map[string]interface{}{"word": word, "length": len(word)})
+	}
+}
+
+// handleValidateGame replays a transcript on an empty board, checking each play
+// with validatePlay and recomputing its score. Players alternate starting with
+// player 1. Replay stops at the first illegal play, since later plays depend on
+// the board it would have produced.
+func handleValidateGame(wordlist map[uint64]struct{}, trie *TrieNode, excluded map[uint64]struct{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		var req struct {
+			Moves []struct {
+				Type string `json:"type"` // "play" (default), "exchange", or "pass"
+				X    int    `json:"x"`
+				Y    int    `json:"y"`
+				Dir  string `json:"dir"`
+				Word string `json:"word"` // full word from (x,y); lowercase = blank
+				// Challenged plays that isPhony rules a phony are
+				// withdrawn and scored by phonyScore instead of
+				// ending the replay.
+				Challenged bool `json:"challenged"`
+			} `json:"moves"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+
+		type moveResult struct {
+			Legal    bool     `json:"legal"`
+			Score    int      `json:"score"`
+			Problems []string `json:"problems"`
+			Totals   [2]int   `json:"totals"`
+			Excluded bool     `json:"excluded,omitempty"` // word is on the exclusion list
+			Phony    []string `json:"phony,omitempty"`    // why a challenged play was withdrawn
+		}
+
+		b := &Board{board: stringsToBoard(nil), wordlist: wordlist, trie: trie}
+		var totals [2]int
+		results := []moveResult{}
+		firstIllegal := -1
+		for i, mv := range req.Moves {
+			player := i % 2
+			res := moveResult{Legal: true, Problems: []string{}}
+			switch mv.Type {
+			case "", "play":
+				if mv.X < 0 || mv.X >= boardSize || mv.Y < 0 || mv.Y >= boardSize {
+					res.Problems = append(res.Problems, "start square is off the board")
+					break
+				}
+				dir := DIR_HORIZ
+				if mv.Dir == "V" {
+					dir = DIR_VERT
+				} else if mv.Dir != "H" {
+					res.Problems = append(res.Problems, `dir must be "H" or "V"`)
+					break
+				}
+				m, problems := b.validatePlay(mv.Word, mv.X, mv.Y, dir)
+				res.Excluded = isExcluded(excluded, mv.Word)
+				if len(problems) > 0 && mv.Challenged && b.isPhony(mv.Word, mv.X, mv.Y, dir) {
+					res.Phony = problems
+					res.Score = phonyScore()
+					break
+				}
+				if len(problems) > 0 {
+					res.Problems = problems
+					break
+				}
+				applyMove(b, m)
+				res.Score = m.score
+			case "exchange", "pass":
+			default:
+				res.Problems = append(res.Problems, fmt.Sprintf("unknown type %q", mv.Type))
+			}
+			if len(res.Problems) > 0 {
+				res.Legal = false
+			}
+			totals[player] += res.Score
+			res.Totals = totals
+			results = append(results, res)
+			if !res.Legal {
+				firstIllegal = i
+				break
+			}
+		}
+		writeJSON(w, 200, map[string]interface{}{
+			"moves":        results,
+			"firstIllegal": firstIllegal,
+			"totals":       totals,
 		})
+	}
+}
 
-		results := make([]MoveResponse, len(placements))
-		for i, m := range placements {
-			results[i] = bestMoveToResponse(b, m)
+// handleBagFromMoves derives the unseen tiles from a move transcript. Plays
+// remove their tiles from the distribution; exchanges and passes put nothing
+// on the board, so exchanged tiles go back to the bag and are still unseen.
+func handleBagFromMoves() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		var req struct {
+			Moves []struct {
+				Type  string `json:"type"` // "play" (default), "exchange", or "pass"
+				Tiles string `json:"tiles"`
+			} `json:"moves"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, 400, "invalid JSON")
+			return
+		}
+
+		var plays []string
+		for i, m := range req.Moves {
+			switch m.Type {
+			case "", "play":
+				for j := 0; j < len(m.Tiles); j++ {
+					c := m.Tiles[j]
+					if !alphabet.isTile(c) {
+						writeError(w, 400, fmt.Sprintf("move %d: invalid tile %q", i+1, c))
+						return
+					}
+				}
+				plays = append(plays, m.Tiles)
+			case "exchange", "pass":
+			default:
+				writeError(w, 400, fmt.Sprintf("move %d: unknown type %q", i+1, m.Type))
+				return
+			}
+		}
+
+		counts, err := remainingTiles(plays)
+		if err != nil {
+			writeError(w, 400, err.Error())
+			return
+		}
+		remaining := make(map[string]int)
+		total := 0
+		for t, n := range counts {
+			if n > 0 {
+				remaining[string(t)] = n
+				total += n
+			}
+		}
+		writeJSON(w, 200, map[string]interface{}{"remaining": remaining, "total": total})
+	}
+}
+
+// maxUsernameLen bounds the display name stored with a leaderboard entry.
+const maxUsernameLen = 32
+
+// handleLeaderboard serves GET (top plays, ?limit=n, default 10, max 100) and
+// POST (submit a play). A submission is re-scored on the given board with
+// validatePlay and rejected unless it is legal and scores exactly what was
+// claimed. Requires the database.
+func handleLeaderboard(db *DB, wordlist map[uint64]struct{}, trie *TrieNode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if db == nil {
+			writeError(w, 503, "leaderboard requires a database")
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			limit := 10
+			if v := r.URL.Query().Get("limit"); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 1 || n > 100 {
+					writeError(w, 400, "limit must be between 1 and 100")
+					return
+				}
+				limit = n
+			}
+			entries, err := db.TopLeaderboard(r.Context(), limit)
+			if err != nil {
+				writeError(w, 500, "failed to load leaderboard")
+				return
+			}
+			writeJSON(w, 200, map[string]interface{}{"entries": entries})
+
+		case http.MethodPost:
+			var req struct {
+				Board    []string `json:"board"`
+				X        int      `json:"x"`
+				Y        int      `json:"y"`
+				Dir      string   `json:"dir"`
+				Word     string   `json:"word"` // full word from (x,y); lowercase = blank
+				Score    int      `json:"score"`
+				Username string   `json:"username"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, 400, "invalid JSON")
+				return
+			}
+			if !checkBoardRows(w, req.Board) {
+				return
+			}
+			if req.X < 0 || req.X >= boardSize || req.Y < 0 || req.Y >= boardSize {
+				writeError(w, 400, "start square is off the board")
+				return
+			}
+			dir := DIR_HORIZ
+			if req.Dir == "V" {
+				dir = DIR_VERT
+			} else if req.Dir != "H" {
+				writeError(w, 400, `dir must be "H" or "V"`)
+				return
+			}
+			username := strings.TrimSpace(req.Username)
+			if len(username) > maxUsernameLen {
+				writeError(w, 400, fmt.Sprintf("username must be at most %d characters", maxUsernameLen))
+				return
+			}
+
+			b := &Board{board: stringsToBoard(req.Board), wordlist: wordlist, trie: trie}
+			m, problems := b.validatePlay(req.Word, req.X, req.Y, dir)
+			if len(problems) > 0 {
+				writeError(w, 400, "play is not legal: "+strings.Join(problems, "; "))
+				return
+			}
+			if m.score != req.Score {
+				writeError(w, 400, fmt.Sprintf("claimed score %d does not match computed score %d", req.Score, m.score))
+				return
+			}
+
+			e := LeaderboardEntry{
+				BoardHash: boardHash(req.Board),
+				X:         req.X,
+				Y:         req.Y,
+				Dir:       req.Dir,
+				Word:      strings.ToUpper(req.Word),
+				Score:     m.score,
+			}
+			if username != "" {
+				e.Username = &username
+			}
+			added, err := db.AddLeaderboardEntry(r.Context(), e)
+			if err != nil {
+				writeError(w, 500, "failed to record play")
+				return
+			}
+			writeJSON(w, 200, map[string]interface{}{"added": added, "score": m.score})
+
+		default:
+			writeError(w, 405, "method not allowed")
+		}
+	}
+}
+
+// serverConfig describes the server for /api/config: the active ruleset and
+// dictionary and the ones available in the working directory.
+func serverConfig(rulesetName string, authEnabled, dbBacked bool) ConfigResponse {
+	return ConfigResponse{
+		ActiveRuleset:         rulesetName,
+		AvailableRulesets:     listRulesets(),
+		ActiveDictionary:      "dictionary.txt",
+		AvailableDictionaries: listDictionaries(),
+		AuthEnabled:           authEnabled,
+		DBBacked:              dbBacked,
+	}
+}
+
+// handleConfig reports how the server was started. It exposes only names and
+// flags — never connection strings or OIDC settings.
+func handleConfig(cfg ConfigResponse) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, 405, "method not allowed")
+			return
 		}
-		writeJSON(w, 200, map[string]interface{}{"placements": results})
+		writeJSON(w, 200, cfg)
 	}
 }
 
@@ -434,15 +3027,15 @@ func handleRuleset(rulesetName string) http.HandlerFunc {
 		}
 
 		letterPoints := make(map[string]int)
-		for i := byte('A'); i <= 'Z'; i++ {
-			if tilePoints[i] > 0 {
-				letterPoints[string(i)] = tilePoints[i]
+		for i, letter := range alphabet.letters {
+			if pts := tilePoints['A'+i]; pts > 0 {
+				letterPoints[letter] = pts
 			}
 		}
 
 		var tripleWord, doubleWord, tripleLetter, doubleLetter [][2]int
-		for i := 0; i < 225; i++ {
-			x, y := i%15, i/15
+		for i := 0; i < boardSize*boardSize; i++ {
+			x, y := i%boardSize, i/boardSize
 			if tw[i] {
 				tripleWord = append(tripleWord, [2]int{x, y})
 			}
@@ -458,13 +3051,75 @@ func handleRuleset(rulesetName string) http.HandlerFunc {
 		}
 
 		writeJSON(w, 200, RulesetResponse{
-			Name:         rulesetName,
-			BingoBonus:   bingoBonus,
-			LetterPoints: letterPoints,
-			TripleWord:   tripleWord,
-			DoubleWord:   doubleWord,
-			TripleLetter: tripleLetter,
-			DoubleLetter: doubleLetter,
+			Name:             rulesetName,
+			BingoBonus:       bingoBonus,
+			BoardSize:        boardSize,
+			RackSize:         rackSize,
+			Center:           center,
+			LetterPoints:     letterPoints,
+			TripleWord:       tripleWord,
+			DoubleWord:       doubleWord,
+			TripleLetter:     tripleLetter,
+			DoubleLetter:     doubleLetter,
+			ChallengeRule:    challengeRule,
+			ChallengePenalty: challengePenalty,
+			Alphabet:         alphabet.letters,
+			CaseSensitive:    alphabet.caseSensitive,
+		})
+	}
+}
+
+// handleAdminStats reports the memory footprint of the loaded dictionary
+// structures, for capacity planning. Admin-only (see requireAdmin).
+func handleAdminStats(wordlist map[uint64]struct{}, trie *TrieNode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		writeJSON(w, 200, map[string]interface{}{
+			"wordCount":     len(wordlist),
+			"trieNodeCount": countTrieNodes(trie),
+			"heapAlloc":     mem.HeapAlloc,
+			"heapSys":       mem.HeapSys,
+			"numGC":         mem.NumGC,
+		})
+	}
+}
+
+// handleAdminUsage reports board counts per owner, the total, and the bytes
+// of board data stored. It needs the database; file mode answers 503.
+func handleAdminUsage(db *DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, 405, "method not allowed")
+			return
+		}
+		if db == nil {
+			writeError(w, 503, "usage stats require a database")
+			return
+		}
+		byUser, unowned, err := db.CountBoardsByUser(r.Context())
+		if err != nil {
+			writeError(w, 500, "failed to count boards")
+			return
+		}
+		storage, err := db.BoardStorageBytes(r.Context())
+		if err != nil {
+			writeError(w, 500, "failed to measure board storage")
+			return
+		}
+		total := unowned
+		for _, n := range byUser {
+			total += n
+		}
+		writeJSON(w, 200, map[string]interface{}{
+			"boardsByUser":  byUser,
+			"unownedBoards": unowned,
+			"totalBoards":   total,
+			"storageBytes":  storage,
 		})
 	}
 }
@@ -485,25 +3140,100 @@ func getUserIDFromContext(ctx context.Context) string {
 	return ""
 }
 
+// immutableAssetPrefix is where the SvelteKit build puts content-hashed
+// assets, whose names change whenever their contents do.
+const immutableAssetPrefix = "/_app/immutable/"
+
+// staticContentTypes pins the Content-Type of asset types whose detection
+// depends on the host's MIME tables (.mjs and .wasm are often missing, and
+// browsers refuse modules and streaming wasm served with the wrong type).
+var staticContentTypes = map[string]string{
+	".js":   "text/javascript; charset=utf-8",
+	".mjs":  "text/javascript; charset=utf-8",
+	".wasm": "application/wasm",
+	".json": "application/json",
+}
+
+// setStaticHeaders sets caching and, where staticContentTypes knows better
+// than the file server, Content-Type for the static file at path. Hashed
+// assets are cached for a year; everything else, index.html included, must
+// be revalidated so a deploy is picked up at once.
+func setStaticHeaders(w http.ResponseWriter, path string) {
+	if strings.HasPrefix(path, immutableAssetPrefix) {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	if ct, ok := staticContentTypes[filepath.Ext(path)]; ok {
+		w.Header().Set("Content-Type", ct)
+	}
+}
+
+// handleStatic serves the embedded SPA from staticFS with setStaticHeaders.
+// A path that isn't a file gets index.html, so client-side routes load.
+func handleStatic(staticFS fs.FS) http.HandlerFunc {
+	fileServer := http.FileServer(http.FS(staticFS))
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if path == "/" {
+			path = "/index.html"
+		}
+		f, err := staticFS.Open(strings.TrimPrefix(path, "/"))
+		if err == nil {
+			f.Close()
+			setStaticHeaders(w, path)
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+		// SPA fallback
+		r.URL.Path = "/"
+		setStaticHeaders(w, "/index.html")
+		fileServer.ServeHTTP(w, r)
+	}
+}
+
 // ── Server ───────────────────────────────────────────────────────────────────
 
 func runServer() {
 	rulesetName := loadRuleset()
+	if n, err := loadLeaveTable("leaves.json"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v — using the built-in leave heuristic\n", err)
+	} else if n > 0 {
+		fmt.Printf("Loaded %d leave value(s) from leaves.json\n", n)
+	}
 
 	fmt.Println("Loading dictionary...")
-	wordlist, err := loadDictionary("dictionary.txt")
+	excluded, err := loadExclusions("exclusions.txt", alphabet)
+	if err != nil {
+		fmt.Println("Unable to load exclusions:", err)
+		os.Exit(1)
+	}
+	if len(excluded) > 0 {
+		fmt.Printf("Excluding %d word(s) listed in exclusions.txt\n", len(excluded))
+	}
+	wordlist, err := loadDictionary("dictionary.txt", alphabet, excluded)
 	if err != nil {
 		fmt.Println("Unable to load dictionary:", err)
 		os.Exit(1)
 	}
 
+	// Without a trie every search would take the unpruned wordlist
+	// fallback, which a single long rack can keep busy for minutes, so the
+	// server won't run on it.
 	fmt.Println("Building trie...")
-	trie, err := buildTrie("dictionary.txt")
+	trie, err := loadIndex("dictionary.txt", alphabet, excluded)
 	if err != nil {
 		fmt.Println("Unable to build trie:", err)
 		os.Exit(1)
 	}
 
+	definitions, err := loadDefinitions("definitions.txt")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v — /api/define disabled\n", err)
+	} else if definitions != nil {
+		fmt.Printf("Loaded %d definition(s) from definitions.txt\n", len(definitions))
+	}
+
 	// Database connection (optional — falls back to file-based if not configured)
 	var db *DB
 	if dbURL := os.Getenv("DATABASE_URL"); dbURL != "" {
@@ -522,8 +3252,8 @@ func runServer() {
 		fmt.Println("Database ready.")
 	} else {
 		fmt.Println("No DATABASE_URL set, using file-based board storage.")
-		if err := os.MkdirAll("boards", 0755); err != nil {
-			fmt.Println("Cannot create boards/ directory:", err)
+		if err := os.MkdirAll(boardsDir(), 0755); err != nil {
+			fmt.Println("Cannot create boards directory:", err)
 			os.Exit(1)
 		}
 	}
@@ -551,18 +3281,54 @@ func runServer() {
 	mux := http.NewServeMux()
 
 	// Stateless computation routes (always public, no auth needed)
-	mux.HandleFunc("/api/solve", handleSolve(wordlist, trie))
+	solves := newSolveCache(solveCacheSize())
+	mux.HandleFunc("/api/solve", handleSolve(wordlist, trie, solves))
+	mux.HandleFunc("/api/stats", handleStats(solves))
 	mux.HandleFunc("/api/opponent", handleOpponent(wordlist, trie))
 	mux.HandleFunc("/api/ruleset", handleRuleset(rulesetName))
+	mux.HandleFunc("/api/config", handleConfig(serverConfig(rulesetName, av != nil, db != nil)))
+	mux.HandleFunc("/api/bag-from-moves", handleBagFromMoves())
+	mux.HandleFunc("/api/validate-game", handleValidateGame(wordlist, trie, excluded))
+	mux.HandleFunc("/api/rack-analysis", handleRackAnalysis())
+	mux.HandleFunc("/api/validate", handleValidate(wordlist, excluded))
+	mux.HandleFunc("/api/word-score", handleWordScore())
+	mux.HandleFunc("/api/word-tiles", handleWordTiles())
+	mux.HandleFunc("/api/score", handleScore(wordlist, trie))
+	mux.HandleFunc("/api/longest", handleLongest(trie))
+	mux.HandleFunc("/api/stems", handleStems(trie))
+	mux.HandleFunc("/api/define", handleDefine(definitions))
+	mux.HandleFunc("/api/tiles", handleTiles())
+	mux.HandleFunc("/api/hotspots", handleHotspots())
+	mux.HandleFunc("/api/evaluate", handleEvaluate())
+	mux.HandleFunc("/api/validate-board", handleValidateBoard(wordlist))
+	mux.HandleFunc("/api/puzzle-check", handlePuzzleCheck(wordlist, trie))
+	mux.HandleFunc("/api/best-possible", handleBestPossible(wordlist, trie))
+	mux.HandleFunc("/api/best-draw", handleBestDraw(wordlist, trie))
+	mux.HandleFunc("/api/has-bingo", handleHasBingo(wordlist, trie))
+	mux.HandleFunc("/api/compare-moves", handleCompareMoves(wordlist, trie))
+	mux.HandleFunc("/api/blank-options", handleBlankOptions(wordlist, trie))
+	mux.HandleFunc("/api/transform", handleTransform())
+	mux.HandleFunc("/api/revalue", handleRevalue())
+	mux.HandleFunc("/api/hooks", handleHooks(wordlist))
+	analyses := newAnalysisStore(24*time.Hour, 10000)
+	mux.HandleFunc("/api/share-analysis", handleShareAnalysis(analyses))
+	mux.HandleFunc("/api/analysis/", handleGetAnalysis(analyses))
+	mux.HandleFunc("/api/leaderboard", handleLeaderboard(db, wordlist, trie))
 	mux.HandleFunc("/api/me", handleMe())
 
+	// Admin routes (authenticated users listed in ADMIN_USERS only)
+	admins := loadAdminSubjects()
+	mux.HandleFunc("/api/admin/stats", requireAdmin(admins, handleAdminStats(wordlist, trie)))
+	mux.HandleFunc("/api/admin/usage", requireAdmin(admins, handleAdminUsage(db)))
+
 	// Board CRUD routes — DB or file-based
 	if db != nil {
+		createKeys := newIdempotencyStore(24*time.Hour, 10000)
 		mux.HandleFunc("/api/boards", func(w http.ResponseWriter, r *http.Request) {
 			if r.Method == http.MethodGet {
 				handleListBoardsDB(db)(w, r)
 			} else if r.Method == http.MethodPost {
-				handleCreateBoardDB(db)(w, r)
+				handleCreateBoardDB(db, createKeys)(w, r)
 			} else {
 				writeError(w, 405, "method not allowed")
 			}
@@ -578,20 +3344,7 @@ func runServer() {
 				writeError(w, 405, "method not allowed")
 			}
 		})
-		mux.HandleFunc("/api/boards/", func(w http.ResponseWriter, r *http.Request) {
-			name := strings.TrimPrefix(r.URL.Path, "/api/boards/")
-			if name == "" {
-				writeError(w, 400, "board name required")
-				return
-			}
-			if r.Method == http.MethodGet {
-				handleGetBoardFile(w, r, name)
-			} else if r.Method == http.MethodPost {
-				handleSaveBoardFile(w, r, name)
-			} else {
-				writeError(w, 405, "method not allowed")
-			}
-		})
+		mux.HandleFunc("/api/boards/", handleBoardFile)
 	}
 
 	// Static files with SPA fallback
@@ -602,22 +3355,7 @@ func runServer() {
 	}
 
 	if staticFS != nil {
-		fileServer := http.FileServer(http.FS(staticFS))
-		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-			path := r.URL.Path
-			if path == "/" {
-				path = "/index.html"
-			}
-			f, err := staticFS.Open(strings.TrimPrefix(path, "/"))
-			if err == nil {
-				f.Close()
-				fileServer.ServeHTTP(w, r)
-				return
-			}
-			// SPA fallback
-			r.URL.Path = "/"
-			fileServer.ServeHTTP(w, r)
-		})
+		mux.HandleFunc("/", handleStatic(staticFS))
 	}
 
 	port := "8080"
@@ -654,7 +3392,7 @@ func runServer() {
 	if db != nil {
 		fmt.Println("  Board storage: PostgreSQL")
 	} else {
-		fmt.Println("  Board storage: file-based (boards/)")
+		fmt.Printf("  Board storage: file-based (%s/)\n", boardsDir())
 	}
 	if av != nil {
 		fmt.Println("  Authentication: OIDC")
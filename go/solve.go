@@ -2,12 +2,20 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
+	"math/rand"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"sort"
 	"strings"
 	"syscall"
+	"time"
+	"unicode/utf8"
 )
 
 // ── Key input ─────────────────────────────────────────────────────────────────
@@ -19,11 +27,15 @@ const (
 	keyDown
 	keyEnter
 	keyQ
+	keyS
+	keyU
+	keyPageUp
+	keyPageDown
 	keyOther
 )
 
 func readKey() key {
-	var buf [3]byte
+	var buf [4]byte
 	n, _ := os.Stdin.Read(buf[:1])
 	if n == 0 {
 		return keyOther
@@ -33,6 +45,10 @@ func readKey() key {
 		return keyEnter
 	case 'q', 'Q':
 		return keyQ
+	case 's', 'S':
+		return keyS
+	case 'u', 'U':
+		return keyU
 	case 0x03: // Ctrl+C
 		disableRaw()
 		fmt.Println()
@@ -45,20 +61,88 @@ func readKey() key {
 				return keyUp
 			case 'B':
 				return keyDown
+			case '5', '6': // PageUp / PageDown: ESC [ 5 ~ and ESC [ 6 ~
+				if n, _ = os.Stdin.Read(buf[3:4]); n == 1 && buf[3] == '~' {
+					if buf[2] == '5' {
+						return keyPageUp
+					}
+					return keyPageDown
+				}
 			}
 		}
 	}
 	return keyOther
 }
 
+// pickerPageSize is how far PageUp/PageDown move the selection in the pickers.
+// Set with solve --page-size.
+var pickerPageSize = 10
+
+// pickerWrap makes arrow keys wrap from the last item to the first and back.
+// Set with solve --wrap. Page jumps never wrap; they stop at the ends.
+var pickerWrap = false
+
+// navigate returns the selection after pressing k in a list of n items with
+// sel highlighted. Keys other than arrows and paging leave sel unchanged.
+func navigate(sel, n int, k key) int {
+	if n == 0 {
+		return 0
+	}
+	switch k {
+	case keyUp:
+		if sel > 0 {
+			return sel - 1
+		}
+		if pickerWrap {
+			return n - 1
+		}
+	case keyDown:
+		if sel < n-1 {
+			return sel + 1
+		}
+		if pickerWrap {
+			return 0
+		}
+	case keyPageUp:
+		return clampIndex(sel-pickerPageSize, n)
+	case keyPageDown:
+		return clampIndex(sel+pickerPageSize, n)
+	}
+	return sel
+}
+
 // ── Board rendering ───────────────────────────────────────────────────────────
 
+// cellWidth is the number of terminal columns each board cell occupies,
+// including the trailing gap. Set with solve --cell-width; values below 2 are
+// raised to 2 so adjacent cells never touch.
+var cellWidth = 2
+
+// padCell left-aligns sym in a cell of cellWidth columns. Width is counted in
+// runes so multi-character tiles (digraphs) keep columns aligned; a symbol
+// wider than the cell still gets one separating space.
+func padCell(sym string) string {
+	w := cellWidth
+	if w < 2 {
+		w = 2
+	}
+	pad := w - utf8.RuneCountInString(sym)
+	if pad < 1 {
+		pad = 1
+	}
+	return strings.Repeat(" ", pad)
+}
+
+// buildBoardLines renders b as ANSI lines: empty premium squares in their
+// colours, highlighted (newly placed) tiles on green, and blanks (lowercase)
+// in magenta — on a magenta background when newly placed.
 func buildBoardLines(b *Board, highlight map[int]bool) []string {
-	lines := make([]string, 15)
-	for y := 0; y < 15; y++ {
+	lines := make([]string, boardSize)
+	for y := 0; y < boardSize; y++ {
 		var sb strings.Builder
-		for x := 0; x < 15; x++ {
+		for x := 0; x < boardSize; x++ {
 			idx := cti(x, y)
+			sym := "."
 			if b.board[x][y] == 0 {
 				switch {
 				case dw[idx]:
@@ -70,48 +154,67 @@ func buildBoardLines(b *Board, highlight map[int]bool) []string {
 				case tl[idx]:
 					sb.WriteString("\x1b[32;1m")
 				}
-				sb.WriteByte('.')
 			} else {
-				if highlight[idx] {
+				blank := isBlankTile(b.board[x][y])
+				switch {
+				case highlight[idx] && blank:
+					sb.WriteString("\x1b[45;1m")
+				case highlight[idx]:
 					sb.WriteString("\x1b[42;1m")
+				case blank:
+					sb.WriteString("\x1b[35;1m")
 				}
-				sb.WriteByte(b.board[x][y])
+				sym = alphabet.decode(string(b.board[x][y]))
 			}
-			sb.WriteString("\x1b[0m ")
+			sb.WriteString(sym)
+			sb.WriteString("\x1b[0m")
+			sb.WriteString(padCell(sym))
 		}
 		lines[y] = sb.String()
 	}
 	return lines
 }
 
+// placedTile is one of a move's new tiles and the square it lands on. Blank
+// tiles are lowercase.
+type placedTile struct {
+	X, Y  int
+	Tile  byte
+	Blank bool
+}
+
+// newTilePositions lays m's tiles from (m.x, m.y) along m.dir, skipping
+// squares already filled on board, and returns where each one lands. It is
+// the one place a BestMove is mapped onto squares; tiles that would run off
+// the edge are left out (see fitsOnBoard).
+func newTilePositions(board [][]byte, m BestMove) []placedTile {
+	dx, dy := 1, 0
+	if m.dir == DIR_VERT {
+		dx, dy = 0, 1
+	}
+	ps := make([]placedTile, 0, len(m.tiles))
+	for x, y := m.x, m.y; len(ps) < len(m.tiles) && x < boardSize && y < boardSize; x, y = x+dx, y+dy {
+		if board[x][y] != 0 {
+			continue
+		}
+		t := m.tiles[len(ps)]
+		ps = append(ps, placedTile{X: x, Y: y, Tile: t, Blank: isBlankTile(t)})
+	}
+	return ps
+}
+
 // previewMove returns a deep copy of b's board with m applied, plus the set of
 // newly-placed positions. The original board is not modified.
 func previewMove(b *Board, m BestMove) ([][]byte, map[int]bool) {
-	board := make([][]byte, 15)
+	board := make([][]byte, boardSize)
 	for i := range board {
-		board[i] = make([]byte, 15)
+		board[i] = make([]byte, boardSize)
 		copy(board[i], b.board[i])
 	}
 	h := make(map[int]bool)
-	tiles := m.tiles
-	if m.dir == DIR_VERT {
-		for i := m.y; len(tiles) > 0; i++ {
-			if board[m.x][i] != 0 {
-				continue
-			}
-			board[m.x][i] = tiles[0]
-			h[cti(m.x, i)] = true
-			tiles = tiles[1:]
-		}
-	} else {
-		for i := m.x; len(tiles) > 0; i++ {
-			if board[i][m.y] != 0 {
-				continue
-			}
-			board[i][m.y] = tiles[0]
-			h[cti(i, m.y)] = true
-			tiles = tiles[1:]
-		}
+	for _, p := range newTilePositions(b.board, m) {
+		board[p.X][p.Y] = p.Tile
+		h[cti(p.X, p.Y)] = true
 	}
 	return board, h
 }
@@ -121,8 +224,9 @@ func previewMove(b *Board, m BestMove) ([][]byte, map[int]bool) {
 const leftWidth = 30
 
 // renderSideBySide clears the screen and prints leftLines (plain text, padded
-// to leftWidth) next to rightLines (ANSI board). selIdx highlights one left row.
-func renderSideBySide(header string, leftLines []string, selIdx int, rightLines []string) {
+// to leftWidth) next to rightLines (ANSI board). selIdx highlights one left row;
+// rows in dim (may be nil) are drawn faint.
+func renderSideBySide(header string, leftLines []string, selIdx int, rightLines []string, dim map[int]bool) {
 	fmt.Print("\x1b[2J\x1b[H")
 	fmt.Print(header + "\r\n\r\n")
 
@@ -139,9 +243,14 @@ func renderSideBySide(header string, leftLines []string, selIdx int, rightLines
 			}
 			// Pad to exact leftWidth BEFORE applying ANSI (fmt.Sprintf counts bytes, not columns).
 			padded := fmt.Sprintf("%-*s", leftWidth, s)
-			if i == selIdx {
+			switch {
+			case i == selIdx && dim[i]:
+				left = "\x1b[2;7m" + padded + "\x1b[0m"
+			case i == selIdx:
 				left = "\x1b[7m" + padded + "\x1b[0m"
-			} else {
+			case dim[i]:
+				left = "\x1b[2m" + padded + "\x1b[0m"
+			default:
 				left = padded
 			}
 		} else {
@@ -157,10 +266,35 @@ func renderSideBySide(header string, leftLines []string, selIdx int, rightLines
 
 // ── Board file I/O ────────────────────────────────────────────────────────────
 
+// boardsDir returns the directory holding board files: $BOARDS_DIR if set,
+// otherwise "boards".
+func boardsDir() string {
+	if d := os.Getenv("BOARDS_DIR"); d != "" {
+		return d
+	}
+	return "boards"
+}
+
+// boardFilePath maps a board name, which may include a slash-separated
+// subpath (e.g. "openings/sicilian"), to its .txt file under boardsDir.
+// Names that are absolute or climb out of the directory are rejected, as is
+// a last segment of "clone": POST /api/boards/{name}/clone clones {name}.
+func boardFilePath(name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if clean == "." || filepath.IsAbs(clean) || clean == ".." ||
+		strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid board name %q", name)
+	}
+	if filepath.Base(clean) == "clone" {
+		return "", fmt.Errorf("invalid board name %q: \"clone\" is reserved", name)
+	}
+	return filepath.Join(boardsDir(), clean+".txt"), nil
+}
+
 func parseBoardFile(path string) ([][]byte, error) {
-	board := make([][]byte, 15)
+	board := make([][]byte, boardSize)
 	for i := range board {
-		board[i] = make([]byte, 15)
+		board[i] = make([]byte, boardSize)
 	}
 	f, err := os.Open(path)
 	if err != nil {
@@ -168,12 +302,12 @@ func parseBoardFile(path string) ([][]byte, error) {
 	}
 	defer f.Close()
 	r := bufio.NewReader(f)
-	for y := 0; y < 15; y++ {
+	for y := 0; y < boardSize; y++ {
 		line, _, err := r.ReadLine()
 		if err != nil {
 			break
 		}
-		for x := 0; x < 15 && x < len(line); x++ {
+		for x := 0; x < boardSize && x < len(line); x++ {
 			c := line[x]
 			if c != '.' {
 				board[x][y] = c &^ byte(32) // uppercase
@@ -184,14 +318,17 @@ func parseBoardFile(path string) ([][]byte, error) {
 }
 
 func saveBoard(board [][]byte, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
 	f, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 	w := bufio.NewWriter(f)
-	for y := 0; y < 15; y++ {
-		for x := 0; x < 15; x++ {
+	for y := 0; y < boardSize; y++ {
+		for x := 0; x < boardSize; x++ {
 			if board[x][y] == 0 {
 				w.WriteByte('.')
 			} else {
@@ -204,14 +341,17 @@ func saveBoard(board [][]byte, path string) error {
 }
 
 func createBlankBoard(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
 	f, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 	w := bufio.NewWriter(f)
-	for y := 0; y < 15; y++ {
-		for x := 0; x < 15; x++ {
+	for y := 0; y < boardSize; y++ {
+		for x := 0; x < boardSize; x++ {
 			w.WriteByte('.')
 		}
 		w.WriteByte('\n')
@@ -224,40 +364,27 @@ func createBlankBoard(path string) error {
 // fullWord reconstructs the complete word formed by m, including tiles already
 // on the board before and after the new tiles.
 func fullWord(b *Board, m BestMove) string {
-	var sb strings.Builder
-	tileIdx := 0
+	dx, dy := 1, 0
 	if m.dir == DIR_VERT {
-		startY := m.y
-		for startY > 0 && b.board[m.x][startY-1] != 0 {
-			startY--
-		}
-		for i := startY; i < 15; i++ {
-			if b.board[m.x][i] != 0 {
-				sb.WriteByte(b.board[m.x][i])
-			} else if tileIdx < len(m.tiles) {
-				sb.WriteByte(m.tiles[tileIdx])
-				tileIdx++
-			} else {
-				break
-			}
-		}
-	} else {
-		startX := m.x
-		for startX > 0 && b.board[startX-1][m.y] != 0 {
-			startX--
-		}
-		for i := startX; i < 15; i++ {
-			if b.board[i][m.y] != 0 {
-				sb.WriteByte(b.board[i][m.y])
-			} else if tileIdx < len(m.tiles) {
-				sb.WriteByte(m.tiles[tileIdx])
-				tileIdx++
-			} else {
-				break
-			}
+		dx, dy = 0, 1
+	}
+	x, y := m.x, m.y
+	for x-dx >= 0 && y-dy >= 0 && b.board[x-dx][y-dy] != 0 {
+		x, y = x-dx, y-dy
+	}
+	var sb strings.Builder
+	ps := newTilePositions(b.board, m)
+	for ; x < boardSize && y < boardSize; x, y = x+dx, y+dy {
+		if b.board[x][y] != 0 {
+			sb.WriteByte(b.board[x][y])
+		} else if len(ps) > 0 && ps[0].X == x && ps[0].Y == y {
+			sb.WriteByte(ps[0].Tile)
+			ps = ps[1:]
+		} else {
+			break
 		}
 	}
-	return strings.ToUpper(sb.String())
+	return upperTiles(sb.String())
 }
 
 type BestMove struct {
@@ -265,257 +392,1234 @@ type BestMove struct {
 	dir   direction
 	tiles string
 	score int
+	// impossible marks a move needing more of some tile than the set has
+	// left besides the board (see flagImpossible).
+	impossible bool
+}
+
+// flagImpossible marks each move whose tiles the board's TileBag can't cover,
+// e.g. a blank when both blanks are already on the board. The rack is not
+// taken out first: a move's tiles are the rack's own, so they are checked
+// against the same supply the rack was drawn from.
+func (b *Board) flagImpossible(moves []BestMove) {
+	bag := newTileBag(b.board)
+	for i := range moves {
+		moves[i].impossible = !bag.covers(moves[i].tiles)
+	}
+}
+
+// findTopNMoves returns the top n of findAllMoves. With useLeave they are
+// ranked by score plus the leaveValue of what each move keeps from rack
+// (see Board.sortByEquity) rather than by score alone.
+func (b *Board) findTopNMoves(rack []byte, n int, useLeave bool) ([]BestMove, error) {
+	moves, err := b.findAllMoves(rack)
+	if err != nil {
+		return nil, err
+	}
+	if useLeave {
+		b.sortByEquity(rack, moves)
+	}
+	if len(moves) > n {
+		moves = moves[:n]
+	}
+	return moves, nil
+}
+
+// filterMovesByWord keeps the moves whose full word is in allowed (uppercase
+// keys), preserving order. An empty allowed set keeps every move.
+func filterMovesByWord(b *Board, moves []BestMove, allowed map[string]bool) []BestMove {
+	if len(allowed) == 0 {
+		return moves
+	}
+	kept := moves[:0]
+	for _, m := range moves {
+		if allowed[fullWord(b, m)] {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+// moveWords returns every word m forms, uppercase: the main word first,
+// then each cross-word through a newly placed tile.
+func (b *Board) moveWords(m BestMove) []string {
+	board, placed := previewMove(b, m)
+	after := &Board{board: board}
+	cross := DIR_VERT
+	if m.dir == DIR_VERT {
+		cross = DIR_HORIZ
+	}
+	words := []string{upperTiles(after.runThrough(m.x, m.y, m.dir))}
+	for idx := range placed {
+		if w := after.runThrough(idx%boardSize, idx/boardSize, cross); len(w) >= 2 {
+			words = append(words, upperTiles(w))
+		}
+	}
+	return words
+}
+
+// scoredWord is one word a move forms, what it scores on its own and whether
+// it is in the wordlist.
+type scoredWord struct {
+	word  string
+	score int
+	valid bool
+}
+
+// scoreBreakdown lists the words m forms — the main word first, then each
+// cross-word of two or more letters in board order — scored by scoreWord as
+// scoreMoveParts does, so the scores add up to scoreMove (no bingo bonus). A
+// single tile's one-letter main word is left out.
+func (b *Board) scoreBreakdown(m BestMove) []scoredWord {
+	board, _ := previewMove(b, m)
+	after := &Board{board: board}
+	plays := make([]byte, boardSize*boardSize)
+	cross := DIR_VERT
+	if m.dir == DIR_VERT {
+		cross = DIR_HORIZ
+	}
+	var crossWords []scoredWord
+	for _, p := range newTilePositions(b.board, m) {
+		plays[cti(p.X, p.Y)] = p.Tile
+		if w := after.runThrough(p.X, p.Y, cross); len(w) >= 2 {
+			crossWords = append(crossWords, scoredWord{word: w, score: b.scoreWord(p.X, p.Y, cross, plays), valid: b.isWord(w)})
+		}
+	}
+	var words []scoredWord
+	if w := after.runThrough(m.x, m.y, m.dir); len(w) >= 2 {
+		words = append(words, scoredWord{word: w, score: b.scoreWord(m.x, m.y, m.dir, plays), valid: b.isWord(w)})
+	}
+	return append(words, crossWords...)
+}
+
+// mainWordLen is the length of m's main word. A single tile laid against a
+// word in the other direction has a one-letter main word, so the word it
+// does form counts instead.
+func (b *Board) mainWordLen(m BestMove) int {
+	words := b.moveWords(m)
+	if len(words[0]) < 2 && len(words) > 1 {
+		return len(words[1])
+	}
+	return len(words[0])
+}
+
+// verifyMove re-checks every word m forms without trusting the hashed
+// wordlist: each must be 2+ letters of the alphabet and, when a trie is loaded, spelled
+// out in it. A move found through an FNV collision fails here.
+func (b *Board) verifyMove(m BestMove) bool {
+	for _, w := range b.moveWords(m) {
+		if len(w) < 2 || strings.IndexFunc(w, func(r rune) bool { return r < 'A' || r > rune(alphabet.lastTile()) }) >= 0 {
+			return false
+		}
+		if b.trie != nil && !trieContains(b.trie, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// blankCost re-scores m as if every blank it places were the real tile for
+// its letter, on the same squares, and returns the difference. Blanks already
+// on the board stay worth 0 either way.
+func (b *Board) blankCost(m BestMove) int {
+	real := upperTiles(m.tiles)
+	if real == m.tiles {
+		return 0
+	}
+	return b.scoreMove(m.x, m.y, real, m.dir) - b.scoreMove(m.x, m.y, m.tiles, m.dir)
 }
 
-// findTopNMoves finds all valid moves for rack, deduplicates by visual placement,
-// sorts by score descending, and returns the top n.
-func (b *Board) findTopNMoves(rack []byte, n int) []BestMove {
+// sDumpScore is the score below which playing an S (outside a bingo) draws
+// a warning: an S held back is worth more as a hook or bingo letter.
+const sDumpScore = 20
+
+// blankDumpScore is the score below which playing a blank (outside a bingo)
+// draws a warning: a blank held back is worth far more than a small play.
+const blankDumpScore = 30
+
+// moveWarnings flags moves that spend a premium tile cheaply. It looks only
+// at the tiles placed and the score, so it is advisory: a blank and an S are
+// both worth keeping for all but bigger plays, and a bingo is never flagged.
+func moveWarnings(m BestMove) []string {
+	if len(m.tiles) == rackSize {
+		return nil
+	}
+	var warnings []string
+	if strings.IndexFunc(m.tiles, func(r rune) bool { return r < 0x80 && isBlankTile(byte(r)) }) >= 0 && m.score < blankDumpScore {
+		warnings = append(warnings, "uses blank")
+	}
+	if strings.IndexByte(m.tiles, 'S') >= 0 && m.score < sDumpScore {
+		warnings = append(warnings, "breaks up potential bingo by playing S")
+	}
+	return warnings
+}
+
+// maxRackLen bounds the rack the search will accept. Each extra tile (and
+// especially each extra blank) multiplies the branching of searchPlay, so a
+// pathological rack could otherwise pin the CPU and grow the move list
+// without limit. Real racks never exceed 7.
+const maxRackLen = 10
+
+// errRackTooLong is findAllMoves' error for a rack over maxRackLen.
+var errRackTooLong = fmt.Errorf("rack must have at most %d tiles", maxRackLen)
+
+// findAllMoves finds all valid moves for rack scoring at least b.minScore,
+// deduplicates by visual placement, and sorts them with sortByScore. A rack
+// longer than maxRackLen is refused with errRackTooLong.
+func (b *Board) findAllMoves(rack []byte) ([]BestMove, error) {
+	if len(rack) > maxRackLen {
+		return nil, errRackTooLong
+	}
+	var moves []BestMove
+	if b.cache != nil {
+		moves = b.cache.generate(b, rack)
+	} else {
+		moves = b.generateMoves(rack)
+	}
+	if b.minScore > 0 {
+		kept := moves[:0]
+		for _, m := range moves {
+			if m.score >= b.minScore {
+				kept = append(kept, m)
+			}
+		}
+		moves = kept
+	}
+	if b.minWordLen > 0 {
+		kept := moves[:0]
+		for _, m := range moves {
+			if b.mainWordLen(m) >= b.minWordLen {
+				kept = append(kept, m)
+			}
+		}
+		moves = kept
+	}
+	sortByScore(b, moves)
+	return moves, nil
+}
+
+// ── Incremental re-solve ──────────────────────────────────────────────────────
+
+// moveCache holds the unfiltered generateMoves result for one board and rack
+// so the live solve loop can re-solve after the opponent plays without
+// scanning the whole board. Only tiles added since the cached board are
+// handled incrementally; any other difference forces a full search.
+type moveCache struct {
+	board       [][]byte
+	rack        string // sorted, uppercase except '*'
+	maxNewTiles int
+	maxBlanks   int
+	moves       []BestMove
+}
+
+// generate returns generateMoves(rack) for b, reusing the cache when it was
+// built for the same rack on a board that b only adds tiles to. Cached moves
+// whose words and their bounding squares saw no change are kept as they are;
+// moves in the rows and columns the new tiles can reach are searched afresh.
+func (c *moveCache) generate(b *Board, rack []byte) []BestMove {
+	key := sortedRack(rack)
+	if b.bingoOnly || !b.deadline.IsZero() {
+		return b.generateMoves(rack)
+	}
+	changed, ok := addedSquares(c.board, b.board)
 	var moves []BestMove
+	switch {
+	case !ok || c.rack != key || c.maxNewTiles != b.maxNewTiles || c.maxBlanks != b.maxBlanks || boardEmpty(c.board):
+		moves = b.generateMoves(rack)
+	case len(changed) == 0:
+		moves = append([]BestMove(nil), c.moves...)
+	default:
+		old := &Board{board: c.board}
+		seen := make(map[string]bool)
+		for _, m := range c.moves {
+			if !old.footprintTouches(m, changed) {
+				seen[moveKey(m.x, m.y, m.dir, m.tiles)] = true
+				moves = append(moves, m)
+			}
+		}
+		b.onlyRows, b.onlyCols = b.affectedLines(changed)
+		fresh := b.generateMoves(rack)
+		b.onlyRows, b.onlyCols = nil, nil
+		for _, m := range fresh {
+			if k := moveKey(m.x, m.y, m.dir, m.tiles); !seen[k] {
+				seen[k] = true
+				moves = append(moves, m)
+			}
+		}
+	}
+	c.board = copyBoard(b.board)
+	c.rack = key
+	c.maxNewTiles = b.maxNewTiles
+	c.maxBlanks = b.maxBlanks
+	c.moves = append([]BestMove(nil), moves...)
+	return moves
+}
+
+// sortedRack returns rack's tiles in sorted order, so racks holding the same
+// tiles compare equal.
+func sortedRack(rack []byte) string {
+	r := []byte(upperTiles(string(rack)))
+	sort.Slice(r, func(i, j int) bool { return r[i] < r[j] })
+	return string(r)
+}
+
+// addedSquares lists the squares empty in old and filled in cur. ok is false
+// if old is nil or any square was cleared or changed letter.
+func addedSquares(old, cur [][]byte) (added map[int]bool, ok bool) {
+	if old == nil {
+		return nil, false
+	}
+	added = make(map[int]bool)
+	for x := 0; x < boardSize; x++ {
+		for y := 0; y < boardSize; y++ {
+			switch {
+			case old[x][y] == cur[x][y]:
+			case old[x][y] == 0:
+				added[cti(x, y)] = true
+			default:
+				return nil, false
+			}
+		}
+	}
+	return added, true
+}
+
+// boardEmpty reports whether board has no tiles. The first move's center
+// rule makes an empty board a poor base for incremental re-solves.
+func boardEmpty(board [][]byte) bool {
+	for x := 0; x < boardSize; x++ {
+		for y := 0; y < boardSize; y++ {
+			if board[x][y] != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func copyBoard(board [][]byte) [][]byte {
+	c := make([][]byte, boardSize)
+	for i := range board {
+		c[i] = append([]byte(nil), board[i]...)
+	}
+	return c
+}
+
+// lineSpan walks from (x, y) along dir over filled squares of board and
+// calls visit for every square of the run plus the square just beyond each
+// end, when on the board.
+func lineSpan(board [][]byte, x, y int, dir direction, visit func(x, y int)) {
+	dx, dy := 1, 0
+	if dir == DIR_VERT {
+		dx, dy = 0, 1
+	}
+	for x-dx >= 0 && y-dy >= 0 && board[x-dx][y-dy] != 0 {
+		x, y = x-dx, y-dy
+	}
+	if x-dx >= 0 && y-dy >= 0 {
+		visit(x-dx, y-dy)
+	}
+	for ; x < boardSize && y < boardSize; x, y = x+dx, y+dy {
+		visit(x, y)
+		if board[x][y] == 0 {
+			return
+		}
+	}
+}
+
+// footprintTouches reports whether any square in changed could alter m on
+// b: a square of its main word or of a cross-word one of its tiles forms, or
+// a square just beyond either end of those words.
+func (b *Board) footprintTouches(m BestMove, changed map[int]bool) bool {
+	board, placed := previewMove(b, m)
+	hit := false
+	visit := func(x, y int) {
+		if changed[cti(x, y)] {
+			hit = true
+		}
+	}
+	lineSpan(board, m.x, m.y, m.dir, visit)
+	cross := DIR_VERT
+	if m.dir == DIR_VERT {
+		cross = DIR_HORIZ
+	}
+	for idx := range placed {
+		lineSpan(board, idx%boardSize, idx/boardSize, cross, visit)
+	}
+	return hit
+}
+
+// affectedLines returns the rows for horizontal anchors and the columns for
+// vertical anchors where a move could include one of the changed squares:
+// its own row or column, where it would be in the main word, and the lines
+// through the empty squares bounding its run the other way, where a new tile
+// would form a cross-word through it.
+func (b *Board) affectedLines(changed map[int]bool) (rows, cols map[int]bool) {
+	rows, cols = make(map[int]bool), make(map[int]bool)
+	for idx := range changed {
+		x, y := idx%boardSize, idx/boardSize
+		rows[y] = true
+		cols[x] = true
+		lineSpan(b.board, x, y, DIR_VERT, func(ex, ey int) {
+			if b.board[ex][ey] == 0 {
+				rows[ey] = true
+			}
+		})
+		lineSpan(b.board, x, y, DIR_HORIZ, func(ex, ey int) {
+			if b.board[ex][ey] == 0 {
+				cols[ex] = true
+			}
+		})
+	}
+	return rows, cols
+}
+
+// distinctBingos returns the distinct words (full word, uppercase, in
+// sortByScore order) that a full rack can form by playing all its tiles.
+// The same word at several positions, or with a blank standing for
+// different letters in a different spot, counts once.
+func (b *Board) distinctBingos(rack []byte) []string {
+	if len(rack) != rackSize {
+		return nil
+	}
+	var words []string
 	seen := make(map[string]bool)
-	rackLen := len(rack)
-	rackCopy := make([]byte, rackLen)
-	copy(rackCopy, rack)
+	moves, _ := b.findAllMoves(rack) // a full rack is never too long
+	for _, m := range moves {
+		if len(m.tiles) != rackSize {
+			continue
+		}
+		w := fullWord(b, m)
+		if !seen[w] {
+			seen[w] = true
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// countDistinctBingos returns len(distinctBingos(rack)).
+func (b *Board) countDistinctBingos(rack []byte) int {
+	return len(b.distinctBingos(rack))
+}
+
+// premiumWeight is a rough value for a premium square, used by findHotspots:
+// word multipliers outrank letter multipliers, triples outrank doubles.
+func premiumWeight(idx int) int {
+	switch {
+	case tw[idx]:
+		return 6
+	case dw[idx]:
+		return 4
+	case tl[idx]:
+		return 3
+	case dl[idx]:
+		return 2
+	}
+	return 0
+}
+
+type hotspot struct {
+	x, y int
+	heat int
+}
 
-	for x := 0; x < 15; x++ {
-		for y := 0; y < 15; y++ {
+// findHotspots ranks the empty squares a rack-independent player should aim
+// at and returns the top n. Only anchor squares are considered — empty
+// squares touching an existing tile, or the center on an empty board. Heat is
+// twice the square's own premium weight, plus the weight of empty premium
+// neighbours a word through it could also reach, plus the points of the
+// adjacent tiles it would build on.
+func (b *Board) findHotspots(n int) []hotspot {
+	var spots []hotspot
+	empty := b.centerEmpty()
+	for x := 0; x < boardSize; x++ {
+		for y := 0; y < boardSize; y++ {
 			if b.board[x][y] != 0 {
 				continue
 			}
-			for _, dir := range []direction{DIR_HORIZ, DIR_VERT} {
-				startX, startY, play, crossPlays, room := b.getPlaySpace(x, y, dir)
-				if room == 0 {
+			if empty && (x != center[0] || y != center[1]) {
+				continue
+			}
+			if !empty && !b.hasNeighbor(x, y) {
+				continue
+			}
+			heat := 2 * premiumWeight(cti(x, y))
+			for _, d := range [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+				nx, ny := x+d[0], y+d[1]
+				if nx < 0 || nx >= boardSize || ny < 0 || ny >= boardSize {
 					continue
 				}
-				var offset int
-				if dir == DIR_HORIZ {
-					offset = x - startX
+				if t := b.board[nx][ny]; t != 0 {
+					heat += tilePoints[t]
 				} else {
-					offset = y - startY
+					heat += premiumWeight(cti(nx, ny))
 				}
-				// Pre-walk trie through existing tiles before the anchor.
-				node := b.trie
-				valid := true
-				for i := 0; i < offset; i++ {
-					idx := int(play[i]&^32) - int('A')
-					if idx < 0 || idx >= 26 || node.children[idx] == nil {
-						valid = false
-						break
-					}
-					node = node.children[idx]
-				}
-				if !valid {
-					continue
-				}
-				b.searchPlay(node, play, crossPlays, offset, rackCopy,
-					make([]byte, 0, 7), x, y, dir, rackLen, seen, &moves)
+			}
+			spots = append(spots, hotspot{x: x, y: y, heat: heat})
+		}
+	}
+	sort.SliceStable(spots, func(i, j int) bool { return spots[i].heat > spots[j].heat })
+	if len(spots) > n {
+		spots = spots[:n]
+	}
+	return spots
+}
+
+// evaluateOpenness rates how open the board is for position evaluation: the
+// number of anchor squares (as in findHotspots) from which a play could
+// cover an empty double- or triple-word square. A play lays at most rackSize
+// tiles, so the word square must be the anchor itself or lie within
+// rackSize-1 squares of it along a row or column of empty squares. anchors is
+// the total number of anchor squares, for scale. Blocked, packed boards score
+// low; boards with long empty lanes to the premiums score high.
+func (b *Board) evaluateOpenness() (openness, anchors int) {
+	empty := b.centerEmpty()
+	for x := 0; x < boardSize; x++ {
+		for y := 0; y < boardSize; y++ {
+			if b.board[x][y] != 0 {
+				continue
+			}
+			if empty && (x != center[0] || y != center[1]) {
+				continue
+			}
+			if !empty && !b.hasNeighbor(x, y) {
+				continue
+			}
+			anchors++
+			if b.reachesWordPremium(x, y) {
+				openness++
 			}
 		}
 	}
+	return openness, anchors
+}
 
+// reachesWordPremium reports whether an empty double- or triple-word square
+// is at (x, y) or within rackSize-1 empty squares of it in any direction.
+func (b *Board) reachesWordPremium(x, y int) bool {
+	if idx := cti(x, y); dw[idx] || tw[idx] {
+		return true
+	}
+	for _, d := range [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+		for i := 1; i < rackSize; i++ {
+			nx, ny := x+d[0]*i, y+d[1]*i
+			if nx < 0 || nx >= boardSize || ny < 0 || ny >= boardSize || b.board[nx][ny] != 0 {
+				break
+			}
+			if idx := cti(nx, ny); dw[idx] || tw[idx] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// moveSortModes lists the orderings accepted by sortMoves, in the order the
+// move picker cycles through them. "score" is the default.
+var moveSortModes = []string{"score", "word", "length", "efficiency"}
+
+// sortMoves reorders moves in place by mode. The sort is stable, so moves must
+// already be in score order for equal keys to keep it. Unknown modes leave the
+// slice untouched.
+func sortMoves(b *Board, moves []BestMove, mode string) {
+	switch mode {
+	case "word":
+		words := make(map[BestMove]string, len(moves))
+		for _, m := range moves {
+			words[m] = fullWord(b, m)
+		}
+		sort.SliceStable(moves, func(i, j int) bool {
+			return words[moves[i]] < words[moves[j]]
+		})
+	case "length":
+		lens := make(map[BestMove]int, len(moves))
+		for _, m := range moves {
+			lens[m] = len(fullWord(b, m))
+		}
+		sort.SliceStable(moves, func(i, j int) bool {
+			return lens[moves[i]] > lens[moves[j]]
+		})
+	case "efficiency":
+		// Points per tile placed from the rack.
+		sort.SliceStable(moves, func(i, j int) bool {
+			return moves[i].score*len(moves[j].tiles) > moves[j].score*len(moves[i].tiles)
+		})
+	case "score":
+		sortByScore(b, moves)
+	}
+}
+
+// sortByScore orders moves by score descending, breaking ties by full word
+// alphabetically, then by x, y and direction, so equal-scoring moves come out
+// in the same order on every run.
+func sortByScore(b *Board, moves []BestMove) {
+	words := make(map[BestMove]string, len(moves))
+	for _, m := range moves {
+		words[m] = fullWord(b, m)
+	}
 	sort.Slice(moves, func(i, j int) bool {
-		return moves[i].score > moves[j].score
+		mi, mj := moves[i], moves[j]
+		if mi.score != mj.score {
+			return mi.score > mj.score
+		}
+		if words[mi] != words[mj] {
+			return words[mi] < words[mj]
+		}
+		if mi.x != mj.x {
+			return mi.x < mj.x
+		}
+		if mi.y != mj.y {
+			return mi.y < mj.y
+		}
+		if mi.dir != mj.dir {
+			return mi.dir < mj.dir
+		}
+		return mi.tiles < mj.tiles
 	})
-	if len(moves) > n {
-		moves = moves[:n]
+}
+
+// validMoveSort reports whether mode is one of moveSortModes.
+func validMoveSort(mode string) bool {
+	for _, m := range moveSortModes {
+		if m == mode {
+			return true
+		}
 	}
-	return moves
+	return false
 }
 
 // findOpponentPlacements finds all valid board positions where word could have
 // been played. Returns moves where x,y is the first NEW tile position and tiles
-// contains only the letters that weren't already on the board.
+// contains only the letters that weren't already on the board, sorted by
+// sortByScore.
+//
+// A '?' in word is a tile that couldn't be read: it matches any letter, and
+// every dictionary word the pattern can spell is tried. More than
+// maxUnknownTiles '?' finds nothing. A '*' marks the next letter as a blank
+// (see markBlanks), which is placed lowercase and scores 0.
 func (b *Board) findOpponentPlacements(word string) []BestMove {
-	word = strings.ToUpper(word)
-	n := len(word)
+	word = markBlanks(word)
 	var placements []BestMove
 
-	for _, dir := range []direction{DIR_HORIZ, DIR_VERT} {
-		for startX := 0; startX < 15; startX++ {
-			for startY := 0; startY < 15; startY++ {
-				// Check word fits on board
-				if dir == DIR_HORIZ && startX+n > 15 {
-					continue
-				}
-				if dir == DIR_VERT && startY+n > 15 {
-					continue
+	for _, w := range b.expandUnknownTiles(word) {
+		for _, dir := range []direction{DIR_HORIZ, DIR_VERT} {
+			for startX := 0; startX < boardSize; startX++ {
+				for startY := 0; startY < boardSize; startY++ {
+					if m, f := b.checkPlacement(w, startX, startY, dir); f == nil {
+						placements = append(placements, m)
+					}
 				}
+			}
+		}
+	}
+	sortByScore(b, placements)
+	return placements
+}
 
-				// Check no tile immediately before the word
-				if dir == DIR_HORIZ && startX > 0 && b.board[startX-1][startY] != 0 {
-					continue
-				}
-				if dir == DIR_VERT && startY > 0 && b.board[startX][startY-1] != 0 {
-					continue
-				}
+// markBlanks turns an opponent's word into tiles in the active alphabet, all
+// real except letters written right after a '*', which become blanks: "QU*IZ"
+// has a blank I. The '*'s are dropped; one with no letter after it is
+// ignored. Anything the alphabet can't spell, such as '?', is kept as is.
+func markBlanks(word string) string {
+	out := make([]byte, 0, len(word))
+	blank := false
+	for _, r := range word {
+		if r == '*' {
+			blank = true
+			continue
+		}
+		tile, ok := alphabet.encode(string(r))
+		if !ok {
+			out = append(out, string(r)...)
+			blank = false
+			continue
+		}
+		c := tile[0] &^ 32
+		if blank {
+			c |= 32
+		}
+		blank = false
+		out = append(out, c)
+	}
+	return string(out)
+}
 
-				// Check no tile immediately after the word
-				if dir == DIR_HORIZ && startX+n < 15 && b.board[startX+n][startY] != 0 {
-					continue
-				}
-				if dir == DIR_VERT && startY+n < 15 && b.board[startX][startY+n] != 0 {
-					continue
-				}
+// maxUnknownTiles caps the '?' placeholders in an opponent word; each one
+// multiplies the candidates by the alphabet's size.
+const maxUnknownTiles = 3
+
+// expandUnknownTiles returns the words word can stand for. Without '?' that is
+// word itself; otherwise every way of filling the '?'s with a letter that
+// spells a dictionary word, in tile order.
+func (b *Board) expandUnknownTiles(word string) []string {
+	unknown := strings.Count(word, "?")
+	if unknown == 0 {
+		return []string{word}
+	}
+	if unknown > maxUnknownTiles {
+		return nil
+	}
+	var words []string
+	buf := []byte(word)
+	var fill func(i int)
+	fill = func(i int) {
+		for i < len(buf) && word[i] != '?' {
+			i++
+		}
+		if i == len(buf) {
+			if b.isWord(string(buf)) {
+				words = append(words, string(buf))
+			}
+			return
+		}
+		for c := byte('A'); c <= alphabet.lastTile(); c++ {
+			buf[i] = c
+			fill(i + 1)
+		}
+	}
+	fill(0)
+	return words
+}
+
+// bestElsewhere returns the highest-scoring legal placement of m's full word
+// other than m itself, ignoring whether the rack holds the tiles it needs.
+// It answers "how much could this word score on a better lane?".
+func (b *Board) bestElsewhere(m BestMove) (BestMove, bool) {
+	for _, p := range b.findOpponentPlacements(fullWord(b, m)) {
+		if p.x != m.x || p.y != m.y || p.dir != m.dir {
+			return p, true
+		}
+	}
+	return BestMove{}, false
+}
+
+// Placement failure stages, in the order checkPlacement tests them. A higher
+// stage means the placement got further before failing, i.e. closer to legal.
+const (
+	failOffBoard = iota
+	failExtends
+	failConflict
+	failNoNewTiles
+	failDisconnected
+	failCrossWord
+	failCenter
+)
+
+// placementFailure explains why word cannot be played at (x, y) in dir.
+// x,y is the start of the word (not the first new tile). matched counts the
+// existing board tiles the word lined up with before failing.
+type placementFailure struct {
+	x, y    int
+	dir     direction
+	stage   int
+	matched int
+	reason  string
+}
+
+// checkPlacement tests whether word can be played starting at (startX, startY)
+// in dir. Lowercase letters in word are blanks and are kept lowercase in the
+// returned tiles. On success it returns the move (x,y at the first new tile)
+// and a nil failure; otherwise it returns the first rule broken. The main word
+// itself is not looked up in the dictionary.
+func (b *Board) checkPlacement(word string, startX, startY int, dir direction) (BestMove, *placementFailure) {
+	n := len(word)
+	fail := func(stage, matched int, format string, args ...interface{}) (BestMove, *placementFailure) {
+		return BestMove{}, &placementFailure{
+			x: startX, y: startY, dir: dir,
+			stage: stage, matched: matched, reason: fmt.Sprintf(format, args...),
+		}
+	}
+
+	// Check word fits on board
+	if dir == DIR_HORIZ && startX+n > boardSize {
+		return fail(failOffBoard, 0, "runs off the right edge of the board")
+	}
+	if dir == DIR_VERT && startY+n > boardSize {
+		return fail(failOffBoard, 0, "runs off the bottom edge of the board")
+	}
 
-				// Scan word positions: check for conflicts, collect new tiles
-				valid := true
-				newTiles := ""
-				firstNewX, firstNewY := -1, -1
-				touches := false
+	// Check no tile immediately before the word
+	if dir == DIR_HORIZ && startX > 0 && b.board[startX-1][startY] != 0 {
+		return fail(failExtends, 0, "existing tile %c directly before the word would extend it", b.board[startX-1][startY]&^32)
+	}
+	if dir == DIR_VERT && startY > 0 && b.board[startX][startY-1] != 0 {
+		return fail(failExtends, 0, "existing tile %c directly before the word would extend it", b.board[startX][startY-1]&^32)
+	}
+
+	// Check no tile immediately after the word
+	if dir == DIR_HORIZ && startX+n < boardSize && b.board[startX+n][startY] != 0 {
+		return fail(failExtends, 0, "existing tile %c directly after the word would extend it", b.board[startX+n][startY]&^32)
+	}
+	if dir == DIR_VERT && startY+n < boardSize && b.board[startX][startY+n] != 0 {
+		return fail(failExtends, 0, "existing tile %c directly after the word would extend it", b.board[startX][startY+n]&^32)
+	}
+
+	// Scan word positions: check for conflicts, collect new tiles
+	newTiles := ""
+	firstNewX, firstNewY := -1, -1
+	touches := false
+	matched := 0
+
+	for i := 0; i < n; i++ {
+		var bx, by int
+		if dir == DIR_HORIZ {
+			bx, by = startX+i, startY
+		} else {
+			bx, by = startX, startY+i
+		}
+		if b.board[bx][by] != 0 {
+			if b.board[bx][by]&^32 != word[i]&^32 { // uppercase both to handle blanks (stored lowercase)
+				return fail(failConflict, matched, "square (%d,%d) already holds %c but the word needs %c",
+					bx, by, b.board[bx][by]&^32, word[i]&^32)
+			}
+			matched++
+			touches = true // using an existing tile counts as connected
+		} else {
+			newTiles += string(word[i])
+			if firstNewX == -1 {
+				firstNewX, firstNewY = bx, by
+			}
+			// Check orthogonal neighbors for connectivity
+			if b.hasNeighbor(bx, by) {
+				touches = true
+			}
+		}
+	}
+
+	if len(newTiles) == 0 {
+		return fail(failNoNewTiles, matched, "the word is already on the board here")
+	}
 
-				for i := 0; i < n; i++ {
-					var bx, by int
-					if dir == DIR_HORIZ {
-						bx, by = startX+i, startY
+	// Must connect to existing tiles (unless this is the very first word)
+	if !b.centerEmpty() && !touches {
+		return fail(failDisconnected, matched, "does not connect to any existing tile")
+	}
+
+	// Validate cross-words formed by each new tile
+	for i := 0; i < n; i++ {
+		var bx, by int
+		if dir == DIR_HORIZ {
+			bx, by = startX+i, startY
+		} else {
+			bx, by = startX, startY+i
+		}
+		if b.board[bx][by] != 0 {
+			continue // existing tile, no new cross-word here
+		}
+		c := word[i]
+		var cross []byte
+		if dir == DIR_HORIZ {
+			// Cross direction is vertical
+			cy1, cy2 := by, by
+			for cy1 > 0 && b.board[bx][cy1-1] != 0 {
+				cy1--
+			}
+			for cy2 < boardSize-1 && b.board[bx][cy2+1] != 0 {
+				cy2++
+			}
+			if cy1 < by || cy2 > by { // touches existing tiles vertically
+				for j := cy1; j <= cy2; j++ {
+					if j == by {
+						cross = append(cross, c)
 					} else {
-						bx, by = startX, startY+i
+						cross = append(cross, b.board[bx][j])
 					}
-					if b.board[bx][by] != 0 {
-						if b.board[bx][by]&^32 != word[i] { // uppercase board tile to handle blanks (stored lowercase)
-							valid = false
-							break
-						}
-						touches = true // using an existing tile counts as connected
+				}
+			}
+		} else {
+			// Cross direction is horizontal
+			cx1, cx2 := bx, bx
+			for cx1 > 0 && b.board[cx1-1][by] != 0 {
+				cx1--
+			}
+			for cx2 < boardSize-1 && b.board[cx2+1][by] != 0 {
+				cx2++
+			}
+			if cx1 < bx || cx2 > bx { // touches existing tiles horizontally
+				for j := cx1; j <= cx2; j++ {
+					if j == bx {
+						cross = append(cross, c)
 					} else {
-						newTiles += string(word[i])
-						if firstNewX == -1 {
-							firstNewX, firstNewY = bx, by
-						}
-						// Check orthogonal neighbors for connectivity
-						if bx > 0 && b.board[bx-1][by] != 0 {
-							touches = true
-						}
-						if bx < 14 && b.board[bx+1][by] != 0 {
-							touches = true
-						}
-						if by > 0 && b.board[bx][by-1] != 0 {
-							touches = true
-						}
-						if by < 14 && b.board[bx][by+1] != 0 {
-							touches = true
-						}
+						cross = append(cross, b.board[j][by])
 					}
 				}
+			}
+		}
+		if cross != nil {
+			f := NewFNV()
+			for _, v := range cross {
+				f.Add(v)
+			}
+			if _, ok := b.wordlist[f.Val()]; !ok {
+				return fail(failCrossWord, matched, "forms invalid cross-word %s", upperTiles(string(cross)))
+			}
+		}
+	}
 
-				if !valid || len(newTiles) == 0 {
-					continue
-				}
+	// First word must cover the center square
+	if b.centerEmpty() {
+		coversCentre := false
+		for i := 0; i < n; i++ {
+			var bx, by int
+			if dir == DIR_HORIZ {
+				bx, by = startX+i, startY
+			} else {
+				bx, by = startX, startY+i
+			}
+			if bx == center[0] && by == center[1] {
+				coversCentre = true
+				break
+			}
+		}
+		if !coversCentre {
+			return fail(failCenter, matched, "the first word must cover the center square")
+		}
+	}
 
-				// Must connect to existing tiles (unless this is the very first word)
-				if b.board[7][7] != 0 && !touches {
-					continue
+	score := b.scoreMove(firstNewX, firstNewY, newTiles, dir)
+	return BestMove{
+		x: firstNewX, y: firstNewY,
+		dir: dir, tiles: newTiles, score: score,
+	}, nil
+}
+
+// validatePlay checks a transcript play of word starting at (startX, startY)
+// in dir: placement rules via checkPlacement plus a dictionary lookup of the
+// main word. Returns the scored move (bingo bonus included) and a list of
+// problems, empty when the play is legal.
+func (b *Board) validatePlay(word string, startX, startY int, dir direction) (BestMove, []string) {
+	var problems []string
+	if len(word) < 2 {
+		return BestMove{}, []string{"word must be at least 2 letters"}
+	}
+	f := NewFNV()
+	for i := 0; i < len(word); i++ {
+		f.Add(word[i])
+	}
+	if _, ok := b.wordlist[f.Val()]; !ok {
+		problems = append(problems, fmt.Sprintf("%s is not in the dictionary", upperTiles(word)))
+	}
+	m, fail := b.checkPlacement(word, startX, startY, dir)
+	if fail != nil {
+		problems = append(problems, fail.reason)
+		return m, problems
+	}
+	if isBingo(rackSize, len(m.tiles)) {
+		m.score += bingoBonus
+	}
+	return m, problems
+}
+
+// isPhony reports whether a challenge would rule the play of word at
+// (startX, startY) a phony: it is placed legally but the main word or a
+// cross-word is not in the dictionary. Plays that break placement rules are
+// not phonies; they can't be made at all.
+func (b *Board) isPhony(word string, startX, startY int, dir direction) bool {
+	_, fail := b.checkPlacement(word, startX, startY, dir)
+	if fail != nil {
+		return fail.stage == failCrossWord
+	}
+	f := NewFNV()
+	for i := 0; i < len(word); i++ {
+		f.Add(word[i])
+	}
+	_, ok := b.wordlist[f.Val()]
+	return !ok
+}
+
+// boardWord is a run of two or more tiles on the board, read in one direction.
+type boardWord struct {
+	x, y int
+	dir  direction
+	word string
+}
+
+// boardRuns returns every run of two or more tiles on the board, rows first
+// then columns. Blanks stay lowercase in word.
+func (b *Board) boardRuns() []boardWord {
+	var runs []boardWord
+	for y := 0; y < boardSize; y++ {
+		for x := 0; x < boardSize; x++ {
+			if b.board[x][y] == 0 || (x > 0 && b.board[x-1][y] != 0) {
+				continue
+			}
+			var run []byte
+			for i := x; i < boardSize && b.board[i][y] != 0; i++ {
+				run = append(run, b.board[i][y])
+			}
+			if len(run) >= 2 {
+				runs = append(runs, boardWord{x: x, y: y, dir: DIR_HORIZ, word: string(run)})
+			}
+		}
+	}
+	for x := 0; x < boardSize; x++ {
+		for y := 0; y < boardSize; y++ {
+			if b.board[x][y] == 0 || (y > 0 && b.board[x][y-1] != 0) {
+				continue
+			}
+			var run []byte
+			for i := y; i < boardSize && b.board[x][i] != 0; i++ {
+				run = append(run, b.board[x][i])
+			}
+			if len(run) >= 2 {
+				runs = append(runs, boardWord{x: x, y: y, dir: DIR_VERT, word: string(run)})
+			}
+		}
+	}
+	return runs
+}
+
+// runValue scores run under t as if all its tiles had just been laid, so
+// every premium square beneath it counts. Blanks (lowercase) score 0. This
+// is the value of the word as a fresh play, not what it earned when played.
+func runValue(run boardWord, t *scoringTable) int {
+	points, wordMult := 0, 1
+	for i := 0; i < len(run.word); i++ {
+		idx := cti(run.x+i, run.y)
+		if run.dir == DIR_VERT {
+			idx = cti(run.x, run.y+i)
+		}
+		p := t.points[run.word[i]]
+		switch {
+		case t.dw[idx]:
+			wordMult *= 2
+		case t.tw[idx]:
+			wordMult *= 3
+		case t.dl[idx]:
+			p *= 2
+		case t.tl[idx]:
+			p *= 3
+		}
+		points += p
+	}
+	return points * wordMult
+}
+
+// connectedComponents groups the board's tiles by orthogonal adjacency. Each
+// component lists its tiles as [x, y] in flood-fill order from the first tile
+// found scanning rows top to bottom; components come in that scan order too.
+func connectedComponents(board [][]byte) [][][2]int {
+	var components [][][2]int
+	seen := make(map[int]bool)
+	for y := 0; y < boardSize; y++ {
+		for x := 0; x < boardSize; x++ {
+			if board[x][y] == 0 || seen[cti(x, y)] {
+				continue
+			}
+			seen[cti(x, y)] = true
+			component := [][2]int{{x, y}}
+			for i := 0; i < len(component); i++ {
+				cx, cy := component[i][0], component[i][1]
+				for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+					nx, ny := cx+d[0], cy+d[1]
+					if nx < 0 || nx >= boardSize || ny < 0 || ny >= boardSize || board[nx][ny] == 0 || seen[cti(nx, ny)] {
+						continue
+					}
+					seen[cti(nx, ny)] = true
+					component = append(component, [2]int{nx, ny})
 				}
+			}
+			components = append(components, component)
+		}
+	}
+	return components
+}
+
+// disconnectedTiles returns the flat indices (cti) of tiles cut off from the
+// main group: the component covering the center square, or the largest one
+// when the center is empty. A board of one group has none.
+func (b *Board) disconnectedTiles() map[int]bool {
+	components := connectedComponents(b.board)
+	main := 0
+	for i, c := range components {
+		if b.board[center[0]][center[1]] != 0 {
+			if containsSquare(c, center[0], center[1]) {
+				main = i
+				break
+			}
+		} else if len(c) > len(components[main]) {
+			main = i
+		}
+	}
+	disconnected := make(map[int]bool)
+	for i, c := range components {
+		if i == main {
+			continue
+		}
+		for _, sq := range c {
+			disconnected[cti(sq[0], sq[1])] = true
+		}
+	}
+	return disconnected
+}
+
+// containsSquare reports whether squares includes (x, y).
+func containsSquare(squares [][2]int, x, y int) bool {
+	for _, sq := range squares {
+		if sq[0] == x && sq[1] == y {
+			return true
+		}
+	}
+	return false
+}
+
+// validateBoard checks every run of 2+ tiles on the board against the
+// dictionary. It returns the runs that are not words, and the flat indices
+// (cti) of suspect tiles: tiles that belong to at least one invalid run and
+// to no valid run. Suspect tiles are the likely mis-entries. disconnected
+// holds the tiles cut off from the main group (see disconnectedTiles).
+func (b *Board) validateBoard() (invalid []boardWord, suspect, disconnected map[int]bool) {
+	inValid := make(map[int]bool)
+	inInvalid := make(map[int]bool)
+
+	for _, run := range b.boardRuns() {
+		ok := b.isWord(run.word)
+		if !ok {
+			run.word = upperTiles(run.word)
+			invalid = append(invalid, run)
+		}
+		for i := range run.word {
+			idx := cti(run.x, run.y+i)
+			if run.dir == DIR_HORIZ {
+				idx = cti(run.x+i, run.y)
+			}
+			if ok {
+				inValid[idx] = true
+			} else {
+				inInvalid[idx] = true
+			}
+		}
+	}
+
+	suspect = make(map[int]bool)
+	for idx := range inInvalid {
+		if !inValid[idx] {
+			suspect[idx] = true
+		}
+	}
+	return invalid, suspect, b.disconnectedTiles()
+}
+
+// isWord reports whether word (any case) is in the wordlist.
+func (b *Board) isWord(word string) bool {
+	_, ok := b.wordlist[wordHash(word)]
+	return ok
+}
+
+// wordHooks lists the letters that extend a board word by one tile at either
+// end. Only hooks that can really be played are listed: the square is on the
+// board and empty, and every word the new tile forms is valid.
+type wordHooks struct {
+	boardWord
+	front []byte
+	back  []byte
+}
+
+// findHooks returns the playable front and back hooks of every word on the
+// board, skipping words with neither.
+func (b *Board) findHooks() []wordHooks {
+	var hooks []wordHooks
+	for _, run := range b.boardRuns() {
+		dx, dy := 1, 0
+		if run.dir == DIR_VERT {
+			dx, dy = 0, 1
+		}
+		n := len(run.word)
+		h := wordHooks{boardWord: run}
+		h.boardWord.word = upperTiles(run.word)
+		h.front = b.hookLetters(run.x-dx, run.y-dy)
+		h.back = b.hookLetters(run.x+n*dx, run.y+n*dy)
+		if len(h.front) > 0 || len(h.back) > 0 {
+			hooks = append(hooks, h)
+		}
+	}
+	return hooks
+}
+
+// hookLetters returns the letters that could be played alone on the empty
+// square (x, y) so that both the horizontal and vertical runs through it are
+// words (a run of one tile needs no check).
+func (b *Board) hookLetters(x, y int) []byte {
+	if x < 0 || x >= boardSize || y < 0 || y >= boardSize || b.board[x][y] != 0 {
+		return nil
+	}
+	var letters []byte
+	for c := byte('A'); c <= alphabet.lastTile(); c++ {
+		b.board[x][y] = c
+		ok := true
+		for _, dir := range []direction{DIR_HORIZ, DIR_VERT} {
+			if run := b.runThrough(x, y, dir); len(run) >= 2 && !b.isWord(run) {
+				ok = false
+				break
+			}
+		}
+		b.board[x][y] = 0
+		if ok {
+			letters = append(letters, c)
+		}
+	}
+	return letters
+}
 
-				// Validate cross-words formed by each new tile
-				crossValid := true
-				for i := 0; i < n; i++ {
-					var bx, by int
-					if dir == DIR_HORIZ {
-						bx, by = startX+i, startY
-					} else {
-						bx, by = startX, startY+i
-					}
-					if b.board[bx][by] != 0 {
-						continue // existing tile, no new cross-word here
-					}
-					c := word[i]
-					if dir == DIR_HORIZ {
-						// Cross direction is vertical
-						cy1, cy2 := by, by
-						for cy1 > 0 && b.board[bx][cy1-1] != 0 {
-							cy1--
-						}
-						for cy2 < 14 && b.board[bx][cy2+1] != 0 {
-							cy2++
-						}
-						if cy1 < by || cy2 > by { // touches existing tiles vertically
-							f := NewFNV()
-							for j := cy1; j <= cy2; j++ {
-								if j == by {
-									f.Add(c)
-								} else {
-									f.Add(b.board[bx][j])
-								}
-							}
-							if _, ok := b.wordlist[f.Val()]; !ok {
-								crossValid = false
-								break
-							}
-						}
-					} else {
-						// Cross direction is horizontal
-						cx1, cx2 := bx, bx
-						for cx1 > 0 && b.board[cx1-1][by] != 0 {
-							cx1--
-						}
-						for cx2 < 14 && b.board[cx2+1][by] != 0 {
-							cx2++
-						}
-						if cx1 < bx || cx2 > bx { // touches existing tiles horizontally
-							f := NewFNV()
-							for j := cx1; j <= cx2; j++ {
-								if j == bx {
-									f.Add(c)
-								} else {
-									f.Add(b.board[j][by])
-								}
-							}
-							if _, ok := b.wordlist[f.Val()]; !ok {
-								crossValid = false
-								break
-							}
-						}
-					}
-				}
-				if !crossValid {
-					continue
-				}
+// runThrough returns the contiguous run of tiles through (x, y) in dir.
+func (b *Board) runThrough(x, y int, dir direction) string {
+	dx, dy := 1, 0
+	if dir == DIR_VERT {
+		dx, dy = 0, 1
+	}
+	for x-dx >= 0 && y-dy >= 0 && b.board[x-dx][y-dy] != 0 {
+		x, y = x-dx, y-dy
+	}
+	var sb strings.Builder
+	for ; x < boardSize && y < boardSize && b.board[x][y] != 0; x, y = x+dx, y+dy {
+		sb.WriteByte(b.board[x][y])
+	}
+	return sb.String()
+}
 
-				// First word must cover the center square
-				if b.board[7][7] == 0 {
-					coversCentre := false
-					for i := 0; i < n; i++ {
-						var bx, by int
-						if dir == DIR_HORIZ {
-							bx, by = startX+i, startY
-						} else {
-							bx, by = startX, startY+i
-						}
-						if bx == 7 && by == 7 {
-							coversCentre = true
-							break
-						}
-					}
-					if !coversCentre {
-						continue
-					}
+// explainPlacementFailures returns up to n reasons why word can't be played,
+// most informative first: placements that got further through checkPlacement
+// rank higher, then those that lined up with more existing tiles.
+func (b *Board) explainPlacementFailures(word string, n int) []placementFailure {
+	word = markBlanks(word)
+	var failures []placementFailure
+	for _, dir := range []direction{DIR_HORIZ, DIR_VERT} {
+		for startX := 0; startX < boardSize; startX++ {
+			for startY := 0; startY < boardSize; startY++ {
+				if _, f := b.checkPlacement(word, startX, startY, dir); f != nil {
+					failures = append(failures, *f)
 				}
-
-				score := b.scoreMove(firstNewX, firstNewY, newTiles, dir)
-				placements = append(placements, BestMove{
-					x: firstNewX, y: firstNewY,
-					dir: dir, tiles: newTiles, score: score,
-				})
 			}
 		}
 	}
-	return placements
+	sort.SliceStable(failures, func(i, j int) bool {
+		if failures[i].stage != failures[j].stage {
+			return failures[i].stage > failures[j].stage
+		}
+		return failures[i].matched > failures[j].matched
+	})
+	if len(failures) > n {
+		failures = failures[:n]
+	}
+	return failures
 }
 
 // ── Screen: board picker ──────────────────────────────────────────────────────
 
-// boardPickerScreen shows the boards/ directory with a "+ New board" option.
-// Manages raw mode internally. Returns the selected file path and whether to proceed.
+// boardPickerScreen shows the boards directory (including subdirectories) with
+// a "+ New board" option. Manages raw mode internally. Returns the selected
+// file path and whether to proceed.
 func boardPickerScreen(reader *bufio.Reader) (string, bool) {
+	dir := boardsDir()
 	loadFiles := func() []string {
 		var files []string
-		entries, err := os.ReadDir("boards")
+		names, err := readBoardDir(dir)
 		if err != nil {
 			return files
 		}
-		for _, e := range entries {
-			if !e.IsDir() && strings.HasSuffix(e.Name(), ".txt") {
-				files = append(files, e.Name())
-			}
+		for _, name := range names {
+			files = append(files, name+".txt")
 		}
 		return files
 	}
@@ -538,13 +1642,13 @@ func boardPickerScreen(reader *bufio.Reader) (string, bool) {
 
 		previews := make([][]string, totalItems)
 		for i, f := range files {
-			if board, err := parseBoardFile("boards/" + f); err == nil {
+			if board, err := parseBoardFile(filepath.Join(dir, f)); err == nil {
 				previews[i] = buildBoardLines(&Board{board: board}, nil)
 			} else {
-				previews[i] = make([]string, 15)
+				previews[i] = make([]string, boardSize)
 			}
 		}
-		previews[len(files)] = make([]string, 15) // blank preview for new board
+		previews[len(files)] = make([]string, boardSize) // blank preview for new board
 
 		if sel >= totalItems {
 			sel = totalItems - 1
@@ -552,18 +1656,12 @@ func boardPickerScreen(reader *bufio.Reader) (string, bool) {
 
 		renderSideBySide(
 			"Select a board  (\x1b[1m\xe2\x86\x91\xe2\x86\x93\x1b[0m navigate, \x1b[1mEnter\x1b[0m select, \x1b[1mq\x1b[0m quit)",
-			displayLines, sel, previews[sel],
+			displayLines, sel, previews[sel], nil,
 		)
 
-		switch readKey() {
-		case keyUp:
-			if sel > 0 {
-				sel--
-			}
-		case keyDown:
-			if sel < totalItems-1 {
-				sel++
-			}
+		switch k := readKey(); k {
+		case keyUp, keyDown, keyPageUp, keyPageDown:
+			sel = navigate(sel, totalItems, k)
 		case keyEnter:
 			if sel == len(files) {
 				// Create a new board
@@ -573,8 +1671,11 @@ func boardPickerScreen(reader *bufio.Reader) (string, bool) {
 				name, _ := reader.ReadString('\n')
 				name = strings.TrimSpace(strings.TrimRight(name, "\r\n"))
 				if name != "" {
-					path := "boards/" + name + ".txt"
-					if err := createBlankBoard(path); err != nil {
+					path, err := boardFilePath(name)
+					if err == nil {
+						err = createBlankBoard(path)
+					}
+					if err != nil {
 						fmt.Printf("Error creating board: %v\n", err)
 					} else {
 						fmt.Printf("Created %s\n", path)
@@ -593,7 +1694,7 @@ func boardPickerScreen(reader *bufio.Reader) (string, bool) {
 				// Continue outer loop → re-render picker
 			} else {
 				disableRaw()
-				return "boards/" + files[sel], true
+				return filepath.Join(dir, files[sel]), true
 			}
 		case keyQ:
 			disableRaw()
@@ -605,12 +1706,21 @@ func boardPickerScreen(reader *bufio.Reader) (string, bool) {
 // ── Screen: move / placement picker ──────────────────────────────────────────
 
 // movePickerScreen shows a list of moves with a live board preview.
-// header should include tile/context info. Returns (selected index, ok).
-func movePickerScreen(b *Board, moves []BestMove, header string) (int, bool) {
-	sel := 0
+// header should include tile/context info. initial is the index highlighted
+// on entry, clamped to the list. Pressing s cycles the sort order (see
+// moveSortModes) of a copy of moves; the caller's slice is left as is and
+// the returned index refers to it. Returns (selected index, ok); pressing u
+// returns (pickUndo, false) so the caller can take back the last applied move.
+func movePickerScreen(b *Board, moves []BestMove, header string, initial int) (int, bool) {
+	shown := make([]BestMove, len(moves))
+	copy(shown, moves)
+	sortIdx := 0
+	sel := clampIndex(initial, len(moves))
 	for {
-		leftLines := make([]string, len(moves))
-		for i, m := range moves {
+		leftLines := make([]string, len(shown))
+		dim := make(map[int]bool)
+		for i, m := range shown {
+			dim[i] = m.impossible
 			dirStr := "H"
 			if m.dir == DIR_VERT {
 				dirStr = "V"
@@ -620,89 +1730,641 @@ func movePickerScreen(b *Board, moves []BestMove, header string) (int, bool) {
 				i+1, word, m.score, m.x+1, m.y+1, dirStr)
 		}
 
-		previewBoard, highlight := previewMove(b, moves[sel])
+		previewBoard, highlight := previewMove(b, shown[sel])
 		rightLines := buildBoardLines(&Board{board: previewBoard}, highlight)
 
-		renderSideBySide(header, leftLines, sel, rightLines)
-
-		switch readKey() {
-		case keyUp:
-			if sel > 0 {
-				sel--
-			}
-		case keyDown:
-			if sel < len(moves)-1 {
-				sel++
-			}
+		sortHeader := fmt.Sprintf("%s  \x1b[1ms\x1b[0m sort: %s", header, moveSortModes[sortIdx])
+		renderSideBySide(sortHeader, leftLines, sel, rightLines, dim)
+
+		switch k := readKey(); k {
+		case keyUp, keyDown, keyPageUp, keyPageDown:
+			sel = navigate(sel, len(shown), k)
+		case keyS:
+			sortIdx = (sortIdx + 1) % len(moveSortModes)
+			copy(shown, moves)
+			sortMoves(b, shown, moveSortModes[sortIdx])
+			sel = 0
 		case keyEnter:
+			for i := range moves {
+				if moves[i] == shown[sel] {
+					return i, true
+				}
+			}
 			return sel, true
 		case keyQ:
 			return 0, false
+		case keyU:
+			return pickUndo, false
 		}
 	}
 }
 
+// pickUndo is the index movePickerScreen returns when u is pressed.
+const pickUndo = -1
+
 // ── Helpers ───────────────────────────────────────────────────────────────────
 
+// clampIndex limits i to [0, n-1]. Returns 0 when n is 0.
+func clampIndex(i, n int) int {
+	if i >= n {
+		i = n - 1
+	}
+	if i < 0 {
+		i = 0
+	}
+	return i
+}
+
 func applyMove(b *Board, m BestMove) {
-	tiles := m.tiles
-	if m.dir == DIR_VERT {
-		for i := m.y; len(tiles) > 0; i++ {
-			if b.board[m.x][i] != 0 {
-				continue
-			}
-			b.board[m.x][i] = tiles[0]
-			tiles = tiles[1:]
+	for _, p := range newTilePositions(b.board, m) {
+		b.board[p.X][p.Y] = p.Tile
+	}
+}
+
+// parseRack reads a rack of the alphabet's letters (any case, unless the
+// alphabet is case-sensitive) and '*' blanks, ignoring surrounding
+// whitespace. Anything else — digits, punctuation, inner spaces — is left out
+// of the rack and reported once each in invalid, in input order.
+func parseRack(input string) (rack []byte, invalid []string) {
+	input = strings.TrimSpace(input)
+	rack = make([]byte, 0, len(input))
+	seen := make(map[rune]bool)
+	for _, r := range input {
+		tile, ok := alphabet.encode(string(r))
+		switch {
+		case r == '*':
+			rack = append(rack, '*')
+		case ok:
+			rack = append(rack, tile[0]&^32)
+		case !seen[r]:
+			seen[r] = true
+			invalid = append(invalid, string(r))
 		}
-	} else {
-		for i := m.x; len(tiles) > 0; i++ {
-			if b.board[i][m.y] != 0 {
-				continue
+	}
+	return rack, invalid
+}
+
+// quoteInvalid formats parseRack's invalid characters for a message, e.g.
+// ',' ' '.
+func quoteInvalid(invalid []string) string {
+	q := make([]string, len(invalid))
+	for i, c := range invalid {
+		q[i] = "'" + c + "'"
+	}
+	return strings.Join(q, " ")
+}
+
+// blankOption is the best move that plays a blank as letter (uppercase).
+type blankOption struct {
+	letter byte
+	move   BestMove
+}
+
+// bestMovePerBlank fixes the rack's first blank to each letter in turn
+// and keeps the best move that actually places that blank, so the options
+// show what the blank is worth as each letter. Letters with no such move are
+// left out. The search stops before starting a letter once deadline has
+// passed; complete reports whether every letter was tried.
+func (b *Board) bestMovePerBlank(rack []byte, deadline time.Time) (options []blankOption, complete bool) {
+	blank := strings.IndexByte(string(rack), '*')
+	if blank < 0 {
+		return nil, true
+	}
+	for letter := byte('A'); letter <= alphabet.lastTile(); letter++ {
+		if time.Now().After(deadline) {
+			return options, false
+		}
+		fixed := append([]byte(nil), rack...)
+		fixed[blank] = letter + 32
+		moves, err := b.findAllMoves(fixed)
+		if err != nil {
+			return nil, true
+		}
+		for _, m := range moves {
+			if strings.IndexByte(m.tiles, letter+32) >= 0 {
+				options = append(options, blankOption{letter: letter, move: m})
+				break
 			}
-			b.board[i][m.y] = tiles[0]
-			tiles = tiles[1:]
 		}
 	}
+	return options, true
 }
 
-func parseRack(input string) []byte {
-	input = strings.TrimRight(input, "\r\n ")
-	rack := make([]byte, 0, len(input))
-	for i := 0; i < len(input); i++ {
-		if input[i] == '*' {
-			rack = append(rack, '*')
-		} else {
-			rack = append(rack, input[i]&^byte(32))
+// drawOutcome is the best move available after drawing tile. ok is false
+// when the completed rack has no legal play.
+type drawOutcome struct {
+	tile byte
+	move BestMove
+	ok   bool
+}
+
+// bestMovePerDraw solves rack plus each distinct tile that unseen still
+// holds, in A–Z then blank order. The search stops before starting a tile
+// once deadline has passed; complete reports whether every tile was tried.
+func (b *Board) bestMovePerDraw(rack []byte, unseen map[byte]int, deadline time.Time) (outcomes []drawOutcome, complete bool) {
+	for _, t := range []byte("ABCDEFGHIJKLMNOPQRSTUVWXYZ*") {
+		if unseen[t] == 0 {
+			continue
+		}
+		if time.Now().After(deadline) {
+			return outcomes, false
+		}
+		o := drawOutcome{tile: t}
+		if moves, err := b.findTopNMoves(append(append([]byte(nil), rack...), t), 1, false); err == nil && len(moves) > 0 {
+			o.move, o.ok = moves[0], true
+		}
+		outcomes = append(outcomes, o)
+	}
+	return outcomes, true
+}
+
+// rackAnalysis summarises the letter mix of a rack, independent of any board.
+type rackAnalysis struct {
+	vowels     int
+	consonants int
+	blanks     int
+	duplicates map[byte]int // letters held more than once → count
+	balance    string       // "balanced", "vowel-heavy" or "consonant-heavy"
+}
+
+// isVowel reports whether c is a vowel: A, E, I, O, U, and Y too when yVowel
+// is set.
+func isVowel(c byte, yVowel bool) bool {
+	switch c &^ 32 {
+	case 'A', 'E', 'I', 'O', 'U':
+		return true
+	case 'Y':
+		return yVowel
+	}
+	return false
+}
+
+// analyzeRack counts vowels, consonants and blanks in rack, counting Y as a
+// vowel when yVowel is set (coaches differ; the default is AEIOU). A rack is
+// vowel-heavy when vowels outnumber consonants by two or more, and
+// consonant-heavy when consonants exceed twice the vowels plus one (so 6/1
+// and 7/0 splits on a full rack). Blanks count toward neither side.
+func analyzeRack(rack []byte, yVowel bool) rackAnalysis {
+	a := rackAnalysis{duplicates: make(map[byte]int), balance: "balanced"}
+	counts := make(map[byte]int)
+	for _, t := range rack {
+		switch {
+		case t == '*':
+			a.blanks++
+			continue
+		case isVowel(t, yVowel):
+			a.vowels++
+		default:
+			a.consonants++
+		}
+		counts[t&^32]++
+	}
+	for c, n := range counts {
+		if n > 1 {
+			a.duplicates[c] = n
+		}
+	}
+	switch {
+	case a.vowels > a.consonants+1:
+		a.balance = "vowel-heavy"
+	case a.consonants > 2*a.vowels+1:
+		a.balance = "consonant-heavy"
+	}
+	return a
+}
+
+// ── Move comparison ───────────────────────────────────────────────────────────
+
+// leaveTileValues is a rough per-tile worth, in points, of keeping a tile for
+// the next turn: blanks and S are flexible, awkward letters cost.
+var leaveTileValues = [255]int{
+	'*': 25, 'S': 8, 'Z': 3, 'X': 3, 'E': 3, 'R': 2, 'H': 1, 'A': 1, 'N': 1,
+	'T': 1, 'L': 1, 'D': 1, 'C': 0, 'M': 0, 'I': 0, 'O': -1, 'P': -1, 'Y': -1,
+	'B': -2, 'F': -2, 'G': -2, 'K': -2, 'J': -2, 'U': -3, 'W': -3, 'V': -5, 'Q': -7,
+}
+
+// leaveTable maps a leave, its letters sorted as by sortedRack, to its value
+// in points, overriding the leaveValue heuristic. Loaded from leaves.json by
+// loadLeaveTable; nil when there is none.
+var leaveTable map[string]float64
+
+// loadLeaveTable reads filename (a JSON object of leave → points, e.g.
+// {"*S": 30.5, "QU": -4}) into leaveTable. A missing file is not an error and
+// leaves the heuristic alone. It returns the number of entries loaded.
+func loadLeaveTable(filename string) (int, error) {
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var raw map[string]float64
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return 0, fmt.Errorf("%s: %w", filename, err)
+	}
+	table := make(map[string]float64, len(raw))
+	for leave, v := range raw {
+		table[sortedRack([]byte(leave))] = v
+	}
+	leaveTable = table
+	return len(table), nil
+}
+
+// leaveDuplicatePenalty is charged per extra copy of a letter kept.
+const leaveDuplicatePenalty = 3
+
+// leaveImbalancePenalty is charged when analyzeRack calls the leave vowel- or
+// consonant-heavy.
+const leaveImbalancePenalty = 4
+
+// leaveValue estimates what the tiles kept after a move are worth next turn:
+// the leaveTable entry if there is one, else the sum of leaveTileValues, less
+// penalties for duplicates and for an unbalanced mix. It is a heuristic for
+// comparing moves, not a simulation.
+func leaveValue(leave []byte) float64 {
+	if v, ok := leaveTable[sortedRack(leave)]; ok {
+		return v
+	}
+	v := 0.0
+	for _, t := range leave {
+		v += float64(leaveTileValues[t])
+	}
+	if len(leave) == 0 {
+		return v
+	}
+	a := analyzeRack(leave, false)
+	for _, n := range a.duplicates {
+		v -= float64((n - 1) * leaveDuplicatePenalty)
+	}
+	if a.balance != "balanced" {
+		v -= leaveImbalancePenalty
+	}
+	return v
+}
+
+// sortByEquity orders moves by score + leaveValue of the tiles each keeps from
+// rack, highest first. Equal equity falls back to sortByScore's order.
+func (b *Board) sortByEquity(rack []byte, moves []BestMove) {
+	sortByScore(b, moves)
+	equity := make(map[BestMove]float64, len(moves))
+	for _, m := range moves {
+		leave, _ := rackLeave(rack, m.tiles)
+		equity[m] = float64(m.score) + leaveValue(leave)
+	}
+	sort.SliceStable(moves, func(i, j int) bool {
+		return equity[moves[i]] > equity[moves[j]]
+	})
+}
+
+// rackLeave returns what is left of rack after placing tiles, a lowercase
+// (blank) tile using up a '*'. ok is false when rack doesn't hold them.
+func rackLeave(rack []byte, tiles string) (leave []byte, ok bool) {
+	leave = append([]byte(nil), rack...)
+	for i := 0; i < len(tiles); i++ {
+		t := tiles[i]
+		if isBlankTile(t) {
+			t = '*'
+		}
+		j := bytes.IndexByte(leave, t)
+		if j < 0 {
+			return nil, false
+		}
+		leave = append(leave[:j], leave[j+1:]...)
+	}
+	return leave, true
+}
+
+// replySamples is how many opponent racks expectedReply draws.
+const replySamples = 8
+
+// expectedReply estimates the opponent's best answer to m: it plays m on a
+// copy of the board, draws up to replySamples racks of rackSize from unseen
+// with rng, and averages the top score each can make. Sampling stops early
+// once deadline passes; n is the number of racks actually tried.
+func (b *Board) expectedReply(m BestMove, unseen map[byte]int, rng *rand.Rand, deadline time.Time) (avg float64, n int) {
+	after := &Board{board: make([][]byte, boardSize), wordlist: b.wordlist, trie: b.trie, deadline: deadline}
+	for x := range after.board {
+		after.board[x] = append([]byte(nil), b.board[x]...)
+	}
+	applyMove(after, m)
+
+	var bag []byte
+	for _, t := range []byte("ABCDEFGHIJKLMNOPQRSTUVWXYZ*") {
+		for i := 0; i < unseen[t]; i++ {
+			bag = append(bag, t)
+		}
+	}
+	if len(bag) == 0 {
+		return 0, 0
+	}
+	total := 0
+	for ; n < replySamples && time.Now().Before(deadline); n++ {
+		rng.Shuffle(len(bag), func(i, j int) { bag[i], bag[j] = bag[j], bag[i] })
+		rack := bag[:min(rackSize, len(bag))]
+		if moves, err := after.findTopNMoves(rack, 1, false); err == nil && len(moves) > 0 {
+			total += moves[0].score
 		}
 	}
-	return rack
+	if n == 0 {
+		return 0, 0
+	}
+	return float64(total) / float64(n), n
 }
 
 // promptSave asks whether to save (default yes). Once the user says yes,
 // autoSave is set to true and subsequent calls save silently without asking.
-func promptSave(reader *bufio.Reader, board [][]byte, path string, autoSave *bool) {
-	if *autoSave {
-		if err := saveBoard(board, path); err != nil {
-			fmt.Printf("Error saving: %v\n", err)
+// On a successful save the moves in *pending are appended to the board's
+// move log (see appendMoveLog) and *pending is cleared; otherwise they wait
+// for the next save.
+func promptSave(reader *bufio.Reader, board [][]byte, path string, autoSave *bool, pending *[]MoveRecord) {
+	asked := !*autoSave
+	if asked {
+		fmt.Print("Save board? [Y/n]: ")
+		ans, _ := reader.ReadString('\n')
+		ans = strings.TrimSpace(ans)
+		if ans != "" && !strings.HasPrefix(strings.ToLower(ans), "y") {
+			return
 		}
+		*autoSave = true
+	}
+	if err := saveBoard(board, path); err != nil {
+		fmt.Printf("Error saving: %v\n", err)
 		return
 	}
-	fmt.Print("Save board? [Y/n]: ")
-	ans, _ := reader.ReadString('\n')
-	ans = strings.TrimSpace(ans)
-	if ans == "" || strings.HasPrefix(strings.ToLower(ans), "y") {
-		*autoSave = true
-		if err := saveBoard(board, path); err != nil {
-			fmt.Printf("Error saving: %v\n", err)
+	if err := appendMoveLog(path, *pending); err != nil {
+		fmt.Printf("Error saving move log: %v\n", err)
+		return
+	}
+	*pending = nil
+	if asked {
+		fmt.Println("Saved.")
+	}
+}
+
+// moveLogPath is where file storage keeps a board's move log: beside the
+// board file, one JSON MoveRecord per line. The DB keeps it in board_moves.
+func moveLogPath(boardPath string) string {
+	return strings.TrimSuffix(boardPath, ".txt") + ".moves.jsonl"
+}
+
+// appendMoveLog adds moves to the end of the move log for the board saved at
+// boardPath, numbering them on from the moves already there.
+func appendMoveLog(boardPath string, moves []MoveRecord) error {
+	if len(moves) == 0 {
+		return nil
+	}
+	path := moveLogPath(boardPath)
+	seq := 0
+	if data, err := os.ReadFile(path); err == nil {
+		seq = bytes.Count(data, []byte("\n"))
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	now := time.Now()
+	for _, m := range moves {
+		seq++
+		m.Seq, m.CreatedAt = seq, now
+		if err := enc.Encode(m); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// dropLastLoggedMove removes the newest record from the move log of the
+// board saved at boardPath. A missing or empty log is left alone.
+func dropLastLoggedMove(boardPath string) error {
+	path := moveLogPath(boardPath)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	return os.WriteFile(path, data[:bytes.LastIndexByte(data, '\n')+1], 0644)
+}
+
+// ── One-shot solve ────────────────────────────────────────────────────────────
+
+// runSolveOnce implements `scrabble solve-once <board.txt> <RACK>`: print the
+// top 10 moves as a plain table and exit. No raw mode, so it works in scripts
+// and over SSH. `solve-once --manifest <file>` solves every position listed
+// in file instead (see solveManifest).
+func runSolveOnce(args []string) {
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "usage: scrabble solve-once <board.txt> <RACK> | --manifest <positions.json|.tsv>\n")
+		os.Exit(1)
+	}
+	loadRuleset()
+	if args[0] == "--manifest" {
+		failed, err := solveManifest(os.Stdout, "dictionary.txt", args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if failed > 0 {
+			fmt.Fprintf(os.Stderr, "%d position(s) failed\n", failed)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := solveOnce(os.Stdout, "dictionary.txt", args[0], args[1]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// loadSolverWords loads exclusions, the hashed wordlist and the trie for dict.
+func loadSolverWords(dict string) (map[uint64]struct{}, *TrieNode, error) {
+	excluded, err := loadExclusions("exclusions.txt", alphabet)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load exclusions: %w", err)
+	}
+	wordlist, err := loadDictionary(dict, alphabet, excluded)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load dictionary: %w", err)
+	}
+	trie, err := loadIndex(dict, alphabet, excluded)
+	if err != nil {
+		return nil, nil, fmt.Errorf("build trie: %w", err)
+	}
+	return wordlist, trie, nil
+}
+
+// parseSolveRack validates a rack given on the command line.
+func parseSolveRack(rackInput string) ([]byte, error) {
+	rack, invalid := parseRack(rackInput)
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("rack contains invalid characters: %s", quoteInvalid(invalid))
+	}
+	if len(rack) == 0 {
+		return nil, fmt.Errorf("rack is empty")
+	}
+	if len(rack) > maxRackLen {
+		return nil, fmt.Errorf("rack must have at most %d tiles", maxRackLen)
+	}
+	return rack, nil
+}
+
+// readManifest reads a solve manifest mapping board paths to racks. A .json
+// file is an object {"board.txt": "RACK", ...}; anything else is TSV, one
+// "board.txt<TAB>RACK" per line, with blank lines and #-comments skipped.
+// Entries come back sorted by board path.
+func readManifest(path string) ([][2]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries [][2]string
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var m map[string]string
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		for board, rack := range m {
+			entries = append(entries, [2]string{board, rack})
+		}
+	} else {
+		for i, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			board, rack, ok := strings.Cut(line, "\t")
+			if !ok {
+				return nil, fmt.Errorf("%s:%d: want board<TAB>rack", path, i+1)
+			}
+			entries = append(entries, [2]string{strings.TrimSpace(board), strings.TrimSpace(rack)})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i][0] < entries[j][0] })
+	return entries, nil
+}
+
+// manifestResult is one position's outcome in solveManifest's output.
+type manifestResult struct {
+	Rack  string         `json:"rack"`
+	Moves []MoveResponse `json:"moves,omitempty"`
+	Error string         `json:"error,omitempty"`
+}
+
+// solveManifest solves every position in the manifest at path with
+// findTopNMoves (top 10 each), loading the dictionary once, and writes a JSON
+// object keyed by board path as written in the manifest. Relative board paths
+// are resolved against the manifest's directory. A position that can't be
+// loaded or has a bad rack records an error and the rest still run; failed
+// counts them.
+func solveManifest(w io.Writer, dict, path string) (failed int, err error) {
+	entries, err := readManifest(path)
+	if err != nil {
+		return 0, err
+	}
+	wordlist, trie, err := loadSolverWords(dict)
+	if err != nil {
+		return 0, err
+	}
+	results := make(map[string]manifestResult, len(entries))
+	for _, e := range entries {
+		boardPath, res := e[0], manifestResult{Rack: e[1]}
+		if !filepath.IsAbs(boardPath) {
+			boardPath = filepath.Join(filepath.Dir(path), boardPath)
+		}
+		rack, err := parseSolveRack(e[1])
+		var boardData [][]byte
+		if err == nil {
+			boardData, err = parseBoardFile(boardPath)
+		}
+		var b *Board
+		var moves []BestMove
+		if err == nil {
+			b = &Board{board: boardData, wordlist: wordlist, trie: trie}
+			moves, err = b.findTopNMoves(rack, 10, false)
+		}
+		if err != nil {
+			res.Error = err.Error()
+			failed++
 		} else {
-			fmt.Println("Saved.")
+			res.Moves = []MoveResponse{}
+			for _, m := range moves {
+				res.Moves = append(res.Moves, bestMoveToResponse(b, m))
+			}
+		}
+		results[e[0]] = res
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return failed, enc.Encode(results)
+}
+
+// solveOnce loads the board at boardPath, finds the top 10 moves for rack and
+// writes them to w as a plain-text table.
+func solveOnce(w io.Writer, dict, boardPath, rackInput string) error {
+	rack, err := parseSolveRack(rackInput)
+	if err != nil {
+		return err
+	}
+	boardData, err := parseBoardFile(boardPath)
+	if err != nil {
+		return fmt.Errorf("load board: %w", err)
+	}
+	wordlist, trie, err := loadSolverWords(dict)
+	if err != nil {
+		return err
+	}
+	b := &Board{board: boardData, wordlist: wordlist, trie: trie}
+
+	moves, err := b.findTopNMoves(rack, 10, false)
+	if err != nil {
+		return err
+	}
+	if len(moves) == 0 {
+		fmt.Fprintln(w, "No valid moves found.")
+		return nil
+	}
+	fmt.Fprintf(w, "%3s  %-15s %5s  %-7s %s\n", "#", "WORD", "SCORE", "SQUARE", "DIR")
+	for i, m := range moves {
+		dirStr := "H"
+		if m.dir == DIR_VERT {
+			dirStr = "V"
 		}
+		fmt.Fprintf(w, "%3d  %-15s %5d  %-7s %s\n",
+			i+1, fullWord(b, m), m.score, fmt.Sprintf("(%d,%d)", m.x+1, m.y+1), dirStr)
 	}
+	return nil
 }
 
 // ── Main ──────────────────────────────────────────────────────────────────────
 
-func runSolve() {
+// undoDepth is how many applied moves the solve loop can take back.
+const undoDepth = 50
+
+// runSolve runs the interactive solver. args are the command-line flags after
+// "solve": --preselect n highlights the n-th suggestion (1-based) on the first
+// move picker instead of the top one; --cell-width sets the board cell width;
+// --page-size and --wrap tune picker navigation; --incremental re-solves only
+// around the opponent's tiles when the rack is unchanged (see moveCache).
+func runSolve(args []string) {
+	fs := flag.NewFlagSet("solve", flag.ExitOnError)
+	preselect := fs.Int("preselect", 1, "suggestion to highlight on the first move picker (1-based)")
+	fs.IntVar(&cellWidth, "cell-width", cellWidth, "terminal columns per board cell, including the gap (min 2)")
+	fs.IntVar(&pickerPageSize, "page-size", pickerPageSize, "items PageUp/PageDown move in the pickers (min 1)")
+	fs.BoolVar(&pickerWrap, "wrap", pickerWrap, "wrap arrow navigation around the ends of the pickers")
+	incremental := fs.Bool("incremental", false, "reuse the previous search for the same rack, regenerating only near new tiles")
+	fs.Parse(args)
+	if pickerPageSize < 1 {
+		pickerPageSize = 1
+	}
+	initial := *preselect - 1
+
 	initTerminal()
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT)
@@ -715,14 +2377,19 @@ func runSolve() {
 
 	ruleset := loadRuleset()
 
-	wordlist, err := loadDictionary("dictionary.txt")
+	excluded, err := loadExclusions("exclusions.txt", alphabet)
+	if err != nil {
+		fmt.Println("Unable to open exclusions:", err)
+		return
+	}
+	wordlist, err := loadDictionary("dictionary.txt", alphabet, excluded)
 	if err != nil {
 		fmt.Println("Unable to open dictionary:", err)
 		return
 	}
 
-	if err := os.MkdirAll("boards", 0755); err != nil {
-		fmt.Println("Cannot create boards/ directory:", err)
+	if err := os.MkdirAll(boardsDir(), 0755); err != nil {
+		fmt.Println("Cannot create boards directory:", err)
 		return
 	}
 
@@ -740,12 +2407,15 @@ func runSolve() {
 		fmt.Println("Failed to load board:", err)
 		return
 	}
-	trie, err := buildTrie("dictionary.txt")
+	trie, err := loadIndex("dictionary.txt", alphabet, excluded)
 	if err != nil {
 		fmt.Println("Unable to build trie:", err)
 		return
 	}
 	b := &Board{board: boardData, wordlist: wordlist, trie: trie}
+	if *incremental {
+		b.cache = &moveCache{}
+	}
 
 	// Ask whose turn is first
 	fmt.Print("\x1b[2J\x1b[H")
@@ -758,6 +2428,45 @@ func runSolve() {
 	skipMyTurn := strings.HasPrefix(strings.TrimSpace(strings.ToLower(firstInput)), "o")
 
 	autoSave := false
+	var pending []MoveRecord // applied since the last save; player 1 is me
+
+	// Snapshots of the board taken before each applied move, newest last.
+	var undo [][][]byte
+	pushUndo := func() {
+		if len(undo) == undoDepth {
+			undo = undo[1:]
+		}
+		undo = append(undo, copyBoard(b.board))
+	}
+	// undoLast restores the board from before the last applied move. An
+	// unsaved move is just dropped from pending. A saved one is already the
+	// last line of the move log, so that line is removed and the board file
+	// saved again, keeping the two in step.
+	undoLast := func() {
+		defer func() {
+			fmt.Print("Press Enter to continue...")
+			reader.ReadString('\n')
+		}()
+		if len(undo) == 0 {
+			fmt.Println("Nothing to undo.")
+			return
+		}
+		prev := undo[len(undo)-1]
+		if len(pending) > 0 {
+			pending = pending[:len(pending)-1]
+		} else {
+			if err := dropLastLoggedMove(boardFile); err != nil {
+				fmt.Printf("Cannot undo: error updating move log: %v\n", err)
+				return
+			}
+			if err := saveBoard(prev, boardFile); err != nil {
+				fmt.Printf("Error saving: %v\n", err)
+			}
+		}
+		b.board = prev
+		undo = undo[:len(undo)-1]
+		fmt.Println("Undid last move.")
+	}
 
 	// Continuous game loop ────────────────────────────────────────────────────
 	for {
@@ -771,15 +2480,30 @@ func runSolve() {
 			fmt.Print("Your tiles (blank to quit): ")
 
 			input, _ := reader.ReadString('\n')
-			rack := parseRack(input)
-			if len(rack) == 0 {
+			rack, invalid := parseRack(input)
+			if len(rack) == 0 && len(invalid) == 0 {
 				fmt.Println("Goodbye!")
 				break
 			}
+			if len(invalid) > 0 {
+				fmt.Printf("Ignored: %s\n", quoteInvalid(invalid))
+				if len(rack) == 0 {
+					fmt.Print("Press Enter to continue...")
+					reader.ReadString('\n')
+					continue
+				}
+			}
 
 			// Find best moves
 			fmt.Printf("Searching for top moves for %s...\n", string(rack))
-			moves := b.findTopNMoves(rack, 10)
+			moves, err := b.findTopNMoves(rack, 10, false)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				fmt.Print("Press Enter to continue...")
+				reader.ReadString('\n')
+				continue
+			}
+			b.flagImpossible(moves)
 			if len(moves) == 0 {
 				fmt.Println("No valid moves found.")
 				fmt.Print("Press Enter to continue...")
@@ -789,12 +2513,16 @@ func runSolve() {
 
 			// Move picker
 			myHeader := fmt.Sprintf(
-				"Your tiles: \x1b[1m%s\x1b[0m   (\x1b[1m\xe2\x86\x91\xe2\x86\x93\x1b[0m navigate, \x1b[1mEnter\x1b[0m confirm, \x1b[1mq\x1b[0m back)",
+				"Your tiles: \x1b[1m%s\x1b[0m   (\x1b[1m\xe2\x86\x91\xe2\x86\x93\x1b[0m navigate, \x1b[1mEnter\x1b[0m confirm, \x1b[1mq\x1b[0m back, \x1b[1mu\x1b[0m undo)",
 				string(rack))
 			enableRaw()
-			selMove, ok := movePickerScreen(b, moves, myHeader)
+			selMove, ok := movePickerScreen(b, moves, myHeader, initial)
+			initial = 0 // --preselect only applies to the first screen
 			disableRaw()
 			if !ok {
+				if selMove == pickUndo {
+					undoLast()
+				}
 				continue
 			}
 
@@ -805,12 +2533,14 @@ func runSolve() {
 				dirStr = "vertical"
 			}
 			bonusNote := ""
-			if len(rack) == 7 && len(m.tiles) == 7 {
+			if isBingo(len(rack), len(m.tiles)) {
 				bonusNote = fmt.Sprintf(" (includes %dpt bingo bonus)", bingoBonus)
 			}
 
 			_, highlight := previewMove(b, m)
+			pushUndo()
 			applyMove(b, m)
+			pending = append(pending, moveRecord(m, 1))
 
 			fmt.Print("\x1b[2J\x1b[H")
 			for _, line := range buildBoardLines(b, highlight) {
@@ -819,55 +2549,60 @@ func runSolve() {
 			fmt.Printf("\nPlayed: %s at (%d,%d) %s — %d points%s\n\n",
 				fullWord(b, m), m.x+1, m.y+1, dirStr, m.score, bonusNote)
 
-			promptSave(reader, b.board, boardFile, &autoSave)
+			promptSave(reader, b.board, boardFile, &autoSave, &pending)
 			fmt.Println()
 		}
 		skipMyTurn = false
 
 		// Opponent's turn
-		fmt.Print("Opponent's word (blank to skip to next turn): ")
+		fmt.Print("Opponent's word (*X for a blank X; blank to skip to next turn, u to undo): ")
 		oppInput, _ := reader.ReadString('\n')
 		oppWord := strings.TrimSpace(strings.TrimRight(oppInput, "\r\n"))
 		if oppWord == "" {
 			continue
 		}
+		if strings.EqualFold(oppWord, "u") { // no one-letter words, so never a play
+			undoLast()
+			continue
+		}
 
 		placements := b.findOpponentPlacements(oppWord)
+		b.flagImpossible(placements)
 		if len(placements) == 0 {
 			fmt.Printf("Could not find a valid placement for %q on the board.\n",
-				strings.ToUpper(oppWord))
+				markBlanks(oppWord))
 			fmt.Print("Press Enter to continue...")
 			reader.ReadString('\n')
 			continue
 		}
 
-		// Sort placements by score for display
-		sort.Slice(placements, func(i, j int) bool {
-			return placements[i].score > placements[j].score
-		})
-
 		oppHeader := fmt.Sprintf(
-			"Where did opponent play \x1b[1m%s\x1b[0m?   (\x1b[1m\xe2\x86\x91\xe2\x86\x93\x1b[0m navigate, \x1b[1mEnter\x1b[0m confirm, \x1b[1mq\x1b[0m skip)",
-			strings.ToUpper(oppWord))
+			"Where did opponent play \x1b[1m%s\x1b[0m?   (\x1b[1m\xe2\x86\x91\xe2\x86\x93\x1b[0m navigate, \x1b[1mEnter\x1b[0m confirm, \x1b[1mq\x1b[0m skip, \x1b[1mu\x1b[0m undo)",
+			markBlanks(oppWord))
 		enableRaw()
-		selOpp, ok := movePickerScreen(b, placements, oppHeader)
+		selOpp, ok := movePickerScreen(b, placements, oppHeader, 0)
 		disableRaw()
 		if !ok {
+			if selOpp == pickUndo {
+				undoLast()
+			}
 			continue
 		}
 
 		// Apply opponent's move
 		oppM := placements[selOpp]
 		_, oppHighlight := previewMove(b, oppM)
+		pushUndo()
 		applyMove(b, oppM)
+		pending = append(pending, moveRecord(oppM, 2))
 
 		fmt.Print("\x1b[2J\x1b[H")
 		for _, line := range buildBoardLines(b, oppHighlight) {
 			fmt.Println(line)
 		}
-		fmt.Printf("\nOpponent played %s\n\n", strings.ToUpper(oppWord))
+		fmt.Printf("\nOpponent played %s\n\n", markBlanks(oppWord))
 
-		promptSave(reader, b.board, boardFile, &autoSave)
+		promptSave(reader, b.board, boardFile, &autoSave, &pending)
 		fmt.Println()
 	}
 }
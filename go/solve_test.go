@@ -0,0 +1,1088 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// testWords is a small dictionary for the solver tests: enough to give every
+// test rack several plays on and around CAT without loading dictionary.txt.
+var testWords = []string{
+	"AT", "AS", "TA", "AX", "EX", "AR", "ER", "RE", "ES", "ET",
+	"CAT", "CATS", "SCAT", "ACT", "ACTS", "SAT", "TAR", "RAT", "RATS", "ARTS",
+	"STAR", "TSAR", "EAT", "EATS", "TEA", "TEAS", "SEA", "ATE", "EAST", "SEAT",
+	"ERA", "EAR", "EARS", "ARE", "RATE", "RATES", "TEAR", "TEARS", "STARE",
+	"ASTER", "TAX", "CARE", "CARES", "CART", "CARTS", "TRACE", "CRATE", "REACT",
+}
+
+// writeDict writes words, one per line, to a dictionary file in a test temp
+// directory and returns its path.
+func writeDict(t *testing.T, words []string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "dict.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(words, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newTestBoard returns an empty board with testWords loaded as both the
+// wordlist and the trie.
+func newTestBoard(t *testing.T) *Board {
+	t.Helper()
+	return newWordsBoard(t, testWords)
+}
+
+// newWordsBoard returns an empty board with words, spelled in the active
+// alphabet, loaded as both the wordlist and the trie.
+func newWordsBoard(t *testing.T, words []string) *Board {
+	t.Helper()
+	dict := writeDict(t, words)
+	wordlist, err := loadDictionary(dict, alphabet, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trie, err := parseTrie(dict, alphabet, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	board := make([][]byte, boardSize)
+	for i := range board {
+		board[i] = make([]byte, boardSize)
+	}
+	return &Board{board: board, wordlist: wordlist, trie: trie}
+}
+
+// place writes word onto b starting at (x, y) in dir.
+func place(b *Board, word string, x, y int, dir direction) {
+	for i := 0; i < len(word); i++ {
+		if dir == DIR_HORIZ {
+			b.board[x+i][y] = word[i]
+		} else {
+			b.board[x][y+i] = word[i]
+		}
+	}
+}
+
+// allMoves is b.findAllMoves(rack), failing the test on an error.
+func allMoves(t *testing.T, b *Board, rack []byte) []BestMove {
+	t.Helper()
+	moves, err := b.findAllMoves(rack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return moves
+}
+
+// moveKeys lists moves as sorted "x,y,dir,TILES=score" strings, so two move
+// lists can be compared regardless of how ties were ordered.
+func moveKeys(moves []BestMove) []string {
+	keys := make([]string, len(moves))
+	for i, m := range moves {
+		keys[i] = fmt.Sprintf("%s=%d", moveKey(m.x, m.y, m.dir, m.tiles), m.score)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestIncrementalMatchesFullSolve(t *testing.T) {
+	b := newTestBoard(t)
+	place(b, "CAT", 6, 7, DIR_HORIZ)
+	b.cache = &moveCache{}
+	rack := []byte("AERST")
+
+	// Prime the cache, then let the opponent play.
+	allMoves(t, b, rack)
+	var opp *BestMove
+	for _, p := range b.findOpponentPlacements("CARTS") {
+		if p.dir == DIR_VERT && p.x == 6 {
+			opp = &p
+			break
+		}
+	}
+	if opp == nil {
+		t.Fatal("no vertical CARTS through the C")
+	}
+	before := copyBoard(b.board)
+	applyMove(b, *opp)
+	changed, _ := addedSquares(before, b.board)
+
+	incremental := allMoves(t, b, rack)
+	full := allMoves(t, &Board{board: b.board, wordlist: b.wordlist, trie: b.trie}, rack)
+
+	got, want := moveKeys(incremental), moveKeys(full)
+	if len(want) == 0 {
+		t.Fatal("full solve found no moves")
+	}
+	if len(got) != len(want) {
+		t.Fatalf("incremental found %d moves, full solve %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("move %d: incremental %s, full solve %s", i, got[i], want[i])
+		}
+	}
+
+	rows, cols := b.affectedLines(changed)
+	crossing := false
+	for _, m := range incremental {
+		if (m.dir == DIR_HORIZ && rows[m.y]) || (m.dir == DIR_VERT && cols[m.x]) {
+			crossing = true
+			break
+		}
+	}
+	if !crossing {
+		t.Error("no move crosses a row or column the opponent's play affected")
+	}
+}
+
+func TestDropLastLoggedMove(t *testing.T) {
+	boardPath := filepath.Join(t.TempDir(), "game.txt")
+	moves := []MoveRecord{
+		moveRecord(BestMove{x: 7, y: 7, dir: DIR_HORIZ, tiles: "CAT", score: 10}, 1),
+		moveRecord(BestMove{x: 7, y: 8, dir: DIR_HORIZ, tiles: "AT", score: 4}, 2),
+	}
+	if err := appendMoveLog(boardPath, moves); err != nil {
+		t.Fatal(err)
+	}
+	for want := 1; want >= 0; want-- {
+		if err := dropLastLoggedMove(boardPath); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(moveLogPath(boardPath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Count(string(data), "\n"); got != want {
+			t.Fatalf("log has %d records, want %d:\n%s", got, want, data)
+		}
+		if want == 1 && !strings.Contains(string(data), `"tiles":"CAT"`) {
+			t.Fatalf("wrong record dropped:\n%s", data)
+		}
+	}
+	if err := dropLastLoggedMove(boardPath); err != nil {
+		t.Fatalf("empty log: %v", err)
+	}
+}
+
+func TestMoveWarningsBlank(t *testing.T) {
+	hasBlankWarning := func(m BestMove) bool {
+		for _, w := range moveWarnings(m) {
+			if w == "uses blank" {
+				return true
+			}
+		}
+		return false
+	}
+	if !hasBlankWarning(BestMove{tiles: "CaT", score: 6}) {
+		t.Error("6-point play with a blank has no \"uses blank\" warning")
+	}
+	if hasBlankWarning(BestMove{tiles: "CAT", score: 6}) {
+		t.Error("play without a blank warns \"uses blank\"")
+	}
+	if hasBlankWarning(BestMove{tiles: "QuIZ", score: blankDumpScore + 10}) {
+		t.Error("high-scoring play with a blank warns \"uses blank\"")
+	}
+}
+
+func TestSolveWithoutTrie(t *testing.T) {
+	withTrie := newTestBoard(t)
+	place(withTrie, "CAT", 6, 7, DIR_HORIZ)
+	noTrie := &Board{board: withTrie.board, wordlist: withTrie.wordlist}
+	if noTrie.moveGenPath() == "trie" {
+		t.Fatal("board without a trie reports the trie path")
+	}
+
+	for _, rack := range []string{"AERST", "SX"} {
+		want := moveKeys(allMoves(t, withTrie, []byte(rack)))
+		got := moveKeys(allMoves(t, noTrie, []byte(rack)))
+		if len(want) == 0 {
+			t.Fatalf("%s: trie solve found no moves", rack)
+		}
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("%s: without trie\n%v\nwith trie\n%v", rack, got, want)
+		}
+	}
+}
+
+func TestPlayDiacriticWord(t *testing.T) {
+	german, err := newAlphabet(strings.Split("ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÜ", ""), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	useAlphabet(t, german)
+	words := []string{"BÄR", "BAR", "ÄR"}
+	b := newWordsBoard(t, words)
+	rack, invalid := parseRack("bär")
+	if len(invalid) != 0 || len(rack) != 3 {
+		t.Fatalf("parseRack(bär) = %q, invalid %v", rack, invalid)
+	}
+
+	moves := allMoves(t, b, rack)
+	var bar *BestMove
+	for i, m := range moves {
+		switch alphabet.decode(m.tiles) {
+		case "BAR":
+			t.Errorf("BAR offered from a rack without an A: %+v", m)
+		case "BÄR":
+			if m.dir == DIR_HORIZ && m.y == 7 && m.x <= 7 && m.x+2 >= 7 {
+				bar = &moves[i]
+			}
+		}
+	}
+	if bar == nil {
+		t.Fatalf("no horizontal BÄR through the center among %v", moveKeys(moves))
+	}
+	if !b.verifyMove(*bar) {
+		t.Errorf("verifyMove rejects %+v", *bar)
+	}
+	applyMove(b, *bar)
+	row := make([]byte, 3)
+	for i := range row {
+		row[i] = b.board[bar.x+i][7]
+	}
+	if got := alphabet.decode(string(row)); got != "BÄR" {
+		t.Errorf("board reads %q after the play, want BÄR", got)
+	}
+
+	// A blank can stand in for Ä, and shows lower-case.
+	blank, _ := parseRack("B*R")
+	empty := newWordsBoard(t, words)
+	found := false
+	for _, m := range allMoves(t, empty, blank) {
+		if alphabet.decode(m.tiles) == "BäR" {
+			found = true
+			if got := alphabet.decode(fullWord(empty, m)); got != "BÄR" {
+				t.Errorf("fullWord with the blank Ä = %q, want BÄR", got)
+			}
+			break
+		}
+	}
+	if !found {
+		t.Error("no BäR with the blank as Ä")
+	}
+}
+
+func TestSortMovesWordAndLength(t *testing.T) {
+	b := newTestBoard(t)
+	place(b, "CAT", 6, 7, DIR_HORIZ)
+	byScore := allMoves(t, b, []byte("AERST"))
+	if len(byScore) < 2 {
+		t.Fatal("too few moves to sort")
+	}
+	rank := make(map[BestMove]int, len(byScore))
+	for i, m := range byScore {
+		rank[m] = i
+	}
+
+	for _, mode := range []string{"word", "length"} {
+		moves := append([]BestMove(nil), byScore...)
+		sortMoves(b, moves, mode)
+		for i := 1; i < len(moves); i++ {
+			p, m := moves[i-1], moves[i]
+			pw, mw := fullWord(b, p), fullWord(b, m)
+			var before, tied bool
+			if mode == "word" {
+				before, tied = pw < mw, pw == mw
+			} else {
+				before, tied = len(pw) > len(mw), len(pw) == len(mw)
+			}
+			if !before && !tied {
+				t.Errorf("%s: %s listed before %s", mode, pw, mw)
+			}
+			if tied && rank[p] > rank[m] {
+				t.Errorf("%s: tied %s (%d) and %s (%d) lost score order", mode, pw, p.score, mw, m.score)
+			}
+		}
+	}
+}
+
+func TestNoLoneTileMoves(t *testing.T) {
+	// "A" is a one-letter word in the file; the loaders must drop it, so a
+	// lone A on the empty board's center is never a move.
+	words := append([]string{"A"}, testWords...)
+	withTrie := newWordsBoard(t, words)
+	noTrie := &Board{board: withTrie.board, wordlist: withTrie.wordlist}
+	for name, b := range map[string]*Board{"trie": withTrie, "no trie": noTrie} {
+		if moves := allMoves(t, b, []byte("A")); len(moves) != 0 {
+			t.Errorf("%s: empty board, rack A: got %v, want no moves", name, moveKeys(moves))
+		}
+		for _, m := range allMoves(t, b, []byte("AT")) {
+			if len(m.tiles) == 1 || m.score == 0 {
+				t.Errorf("%s: empty board, rack AT: lone or scoreless move %s=%d", name, m.tiles, m.score)
+			}
+		}
+	}
+
+	b := newWordsBoard(t, words)
+	place(b, "CAT", 6, 7, DIR_HORIZ)
+	for _, m := range allMoves(t, b, []byte("AERST")) {
+		if len(m.tiles) != 1 {
+			continue
+		}
+		p := newTilePositions(b.board, m)[0]
+		if !b.hasNeighbor(p.X, p.Y) || m.score == 0 {
+			t.Errorf("one-tile move %s at (%d,%d) touches nothing or scores 0", m.tiles, p.X, p.Y)
+		}
+	}
+}
+
+func TestBingoFollowsRulesetRackSize(t *testing.T) {
+	// Made-up 7- and 8-letter words, so every rack below can play out.
+	b := newWordsBoard(t, append([]string{"SALTIER", "SALTIERN"}, testWords...))
+	bonus := func(rack, word string) (int, bool) {
+		t.Helper()
+		for _, m := range allMoves(t, b, []byte(rack)) {
+			if m.tiles == word && m.dir == DIR_HORIZ {
+				return m.score - b.scoreMove(m.x, m.y, m.tiles, m.dir), true
+			}
+		}
+		return 0, false
+	}
+	tests := []struct {
+		rackSize    int
+		rack, word  string
+		wantBonus   bool
+		description string
+	}{
+		{7, "ASTER", "ASTER", false, "5-tile partial rack"},
+		{7, "SALTIER", "SALTIER", true, "full 7-tile rack"},
+		{8, "SALTIERN", "SALTIERN", true, "full 8-tile rack"},
+		{8, "SALTIER", "SALTIER", false, "7 tiles under an 8-tile rack"},
+		{8, "SALTIERN", "SALTIER", false, "7 of a full 8-tile rack"},
+	}
+	for _, tt := range tests {
+		useRuleset(t, "scrabble", func(def *rulesetDef) { def.RackSize = tt.rackSize })
+		got, ok := bonus(tt.rack, tt.word)
+		if !ok {
+			t.Fatalf("%s: no %s from rack %s", tt.description, tt.word, tt.rack)
+		}
+		want := 0
+		if tt.wantBonus {
+			want = bingoBonus
+		}
+		if got != want {
+			t.Errorf("%s: %s scored a %d-point bonus, want %d", tt.description, tt.word, got, want)
+		}
+	}
+}
+
+func TestFindAllMovesRejectsLongRack(t *testing.T) {
+	b := newTestBoard(t)
+	if _, err := b.findAllMoves([]byte("AAAAAEEEEER")); err != errRackTooLong {
+		t.Errorf("11-tile rack: err = %v, want errRackTooLong", err)
+	}
+	if _, err := b.findTopNMoves([]byte("AAAAAEEEEER"), 1, false); err != errRackTooLong {
+		t.Errorf("findTopNMoves, 11-tile rack: err = %v, want errRackTooLong", err)
+	}
+	if _, err := b.findAllMoves([]byte("AAAAAEEEET")); err != nil {
+		t.Errorf("10-tile rack: %v", err)
+	}
+}
+
+func TestMovePositionsAgree(t *testing.T) {
+	for _, m := range []BestMove{
+		// Two tiles before CAT, a blank among them, and one after it.
+		{x: 4, y: 7, dir: DIR_HORIZ, tiles: "SeR", score: 1},
+		// Starting on the C: the only new square is past the T.
+		{x: 6, y: 7, dir: DIR_HORIZ, tiles: "S", score: 1},
+		// Down through the A, blank below it.
+		{x: 7, y: 5, dir: DIR_VERT, tiles: "ORs", score: 1},
+	} {
+		b := newTestBoard(t)
+		place(b, "CAT", 6, 7, DIR_HORIZ)
+		ps := newTilePositions(b.board, m)
+		if len(ps) != len(m.tiles) {
+			t.Fatalf("%s: %d positions for %d tiles", m.tiles, len(ps), len(m.tiles))
+		}
+		var want, wantBlanks [][2]int
+		for _, p := range ps {
+			if b.board[p.X][p.Y] != 0 {
+				t.Errorf("%s: position (%d,%d) is already filled", m.tiles, p.X, p.Y)
+			}
+			want = append(want, [2]int{p.X, p.Y})
+			if p.Blank {
+				wantBlanks = append(wantBlanks, [2]int{p.X, p.Y})
+			}
+		}
+
+		resp := bestMoveToResponse(b, m)
+		if !reflect.DeepEqual(resp.NewPositions, want) || !reflect.DeepEqual(resp.Blanks, wantBlanks) {
+			t.Errorf("%s: response positions %v blanks %v, want %v %v", m.tiles, resp.NewPositions, resp.Blanks, want, wantBlanks)
+		}
+
+		preview, highlight := previewMove(b, m)
+		if len(highlight) != len(ps) {
+			t.Errorf("%s: preview highlights %d squares, want %d", m.tiles, len(highlight), len(ps))
+		}
+		for _, p := range ps {
+			if !highlight[cti(p.X, p.Y)] || preview[p.X][p.Y] != p.Tile {
+				t.Errorf("%s: preview at (%d,%d) = %q, highlighted %v", m.tiles, p.X, p.Y, preview[p.X][p.Y], highlight[cti(p.X, p.Y)])
+			}
+		}
+
+		word := fullWord(b, m)
+		applyMove(b, m)
+		if !reflect.DeepEqual(b.board, preview) {
+			t.Errorf("%s: applyMove and previewMove leave different boards", m.tiles)
+		}
+		// The applied board spells fullWord along the move's line.
+		line := fullWord(b, BestMove{x: m.x, y: m.y, dir: m.dir})
+		if word != line || resp.Word != word {
+			t.Errorf("%s: fullWord before %q, board after %q, response %q", m.tiles, word, line, resp.Word)
+		}
+	}
+}
+
+func TestAnalyzeRack(t *testing.T) {
+	tests := []struct {
+		rack   string
+		yVowel bool
+		want   rackAnalysis
+	}{
+		{"AEIOUU*", false, rackAnalysis{vowels: 6, blanks: 1, duplicates: map[byte]int{'U': 2}, balance: "vowel-heavy"}},
+		{"RETAINS", false, rackAnalysis{vowels: 3, consonants: 4, duplicates: map[byte]int{}, balance: "balanced"}},
+		{"BCDFGHA", false, rackAnalysis{vowels: 1, consonants: 6, duplicates: map[byte]int{}, balance: "consonant-heavy"}},
+		{"SSTTRRE", false, rackAnalysis{vowels: 1, consonants: 6, duplicates: map[byte]int{'S': 2, 'T': 2, 'R': 2}, balance: "consonant-heavy"}},
+		{"RHYTHMS", false, rackAnalysis{consonants: 7, duplicates: map[byte]int{'H': 2}, balance: "consonant-heavy"}},
+		{"RHYTHMS", true, rackAnalysis{vowels: 1, consonants: 6, duplicates: map[byte]int{'H': 2}, balance: "consonant-heavy"}},
+		{"AEYYRST", true, rackAnalysis{vowels: 4, consonants: 3, duplicates: map[byte]int{'Y': 2}, balance: "balanced"}},
+		// MYTHY is all consonants by default, but balanced when Y is a vowel.
+		{"MYTHY", false, rackAnalysis{consonants: 5, duplicates: map[byte]int{'Y': 2}, balance: "consonant-heavy"}},
+		{"MYTHY", true, rackAnalysis{vowels: 2, consonants: 3, duplicates: map[byte]int{'Y': 2}, balance: "balanced"}},
+	}
+	for _, tt := range tests {
+		if got := analyzeRack([]byte(tt.rack), tt.yVowel); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("analyzeRack(%s, y vowel %v) = %+v, want %+v", tt.rack, tt.yVowel, got, tt.want)
+		}
+	}
+}
+
+func TestMaxNewTiles(t *testing.T) {
+	rack := []byte("AERSTX")
+	for _, withTrie := range []bool{true, false} {
+		b := newTestBoard(t)
+		if !withTrie {
+			b.trie = nil
+		}
+		place(b, "CAT", 6, 7, DIR_HORIZ)
+
+		var want []BestMove
+		for _, m := range allMoves(t, b, rack) {
+			if len(m.tiles) <= 2 {
+				want = append(want, m)
+			}
+		}
+		if len(want) == len(allMoves(t, b, rack)) {
+			t.Fatal("no uncapped move places 3+ tiles; the test rack is too weak")
+		}
+
+		// The cap prunes the search itself, so what's left must be exactly
+		// the uncapped moves that place at most 2 tiles.
+		b.maxNewTiles = 2
+		got := allMoves(t, b, rack)
+		if !reflect.DeepEqual(moveKeys(got), moveKeys(want)) {
+			t.Errorf("trie %v: capped moves\n%v\nwant\n%v", withTrie, moveKeys(got), moveKeys(want))
+		}
+	}
+}
+
+// withKeys runs f with keys queued on os.Stdin and os.Stdout discarded, for
+// driving the terminal screens.
+func withKeys(t *testing.T, keys string, f func()) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteString(keys); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin, stdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = r, null
+	defer func() {
+		os.Stdin, os.Stdout = stdin, stdout
+		r.Close()
+		null.Close()
+	}()
+	f()
+}
+
+func TestMovePickerInitialSelection(t *testing.T) {
+	b := newTestBoard(t)
+	place(b, "CAT", 6, 7, DIR_HORIZ)
+	moves := allMoves(t, b, []byte("AERST"))
+	last := len(moves) - 1
+	if last < 2 {
+		t.Fatalf("only %d moves", len(moves))
+	}
+
+	tests := []struct {
+		name    string
+		initial int
+		keys    string
+		want    int
+	}{
+		{"honored", 2, "\r", 2},
+		{"then moved down", 1, "\x1b[B\r", 2},
+		{"clamped high", len(moves) + 10, "\r", last},
+		{"clamped low", -3, "\r", 0},
+	}
+	for _, tt := range tests {
+		var got int
+		var ok bool
+		withKeys(t, tt.keys, func() { got, ok = movePickerScreen(b, moves, "", tt.initial) })
+		if !ok || got != tt.want {
+			t.Errorf("%s: picked %d (ok %v), want %d", tt.name, got, ok, tt.want)
+		}
+	}
+}
+
+func TestNavigate(t *testing.T) {
+	defer func(size int, wrap bool) { pickerPageSize, pickerWrap = size, wrap }(pickerPageSize, pickerWrap)
+	pickerPageSize = 3
+	const n = 5
+	tests := []struct {
+		wrap bool
+		sel  int
+		k    key
+		want int
+	}{
+		{false, 0, keyUp, 0},
+		{false, 4, keyDown, 4},
+		{true, 0, keyUp, 4},
+		{true, 4, keyDown, 0},
+		{true, 2, keyDown, 3},
+		{true, 1, keyPageDown, 4},
+		{true, 3, keyPageDown, 4}, // page jumps stop at the ends
+		{true, 3, keyPageUp, 0},
+		{true, 1, keyPageUp, 0},
+		{false, 2, keyEnter, 2},
+	}
+	for _, tt := range tests {
+		pickerWrap = tt.wrap
+		if got := navigate(tt.sel, n, tt.k); got != tt.want {
+			t.Errorf("wrap %v: navigate(%d, %d, %d) = %d, want %d", tt.wrap, tt.sel, n, tt.k, got, tt.want)
+		}
+	}
+
+	// The escape sequences readKey decodes, in one stream.
+	var keys []key
+	withKeys(t, "\x1b[A\x1b[B\x1b[5~\x1b[6~\r", func() {
+		for i := 0; i < 5; i++ {
+			keys = append(keys, readKey())
+		}
+	})
+	if want := []key{keyUp, keyDown, keyPageUp, keyPageDown, keyEnter}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("readKey = %v, want %v", keys, want)
+	}
+}
+
+func TestHotspotsNearTripleWord(t *testing.T) {
+	useRuleset(t, "scrabble", nil)
+	b := newWordsBoard(t, []string{"CRATERS", "SEA"})
+	place(b, "CRATERS", 1, 7, DIR_HORIZ)
+	place(b, "SEA", 7, 7, DIR_VERT)
+
+	// (0,7) is a triple word next to the C: 2×6 for the square plus 3 for C.
+	spots := b.findHotspots(5)
+	if len(spots) == 0 || spots[0] != (hotspot{x: 0, y: 7, heat: 15}) {
+		t.Fatalf("top hotspots %+v, want (0,7) with heat 15 first", spots)
+	}
+	for _, h := range spots {
+		if b.board[h.x][h.y] != 0 || !b.hasNeighbor(h.x, h.y) {
+			t.Errorf("(%d,%d) is not an empty square next to a tile", h.x, h.y)
+		}
+	}
+
+}
+
+func TestCountDistinctBingos(t *testing.T) {
+	b := newWordsBoard(t, append([]string{"RETAINS", "STAINER", "NASTIER"}, testWords...))
+	place(b, "CAT", 6, 7, DIR_HORIZ)
+
+	// Each anagram fits through the S hook on CAT in several spots, but
+	// counts once.
+	words := b.distinctBingos([]byte("AEINRST"))
+	sort.Strings(words)
+	if want := []string{"NASTIER", "RETAINS", "STAINER"}; !reflect.DeepEqual(words, want) {
+		t.Errorf("distinctBingos = %v, want %v", words, want)
+	}
+	if got := b.countDistinctBingos([]byte("AEINRST")); got != 3 {
+		t.Errorf("countDistinctBingos = %d, want 3", got)
+	}
+	if got := b.countDistinctBingos([]byte("AEINRSX")); got != 0 {
+		t.Errorf("rack with no bingo: %d, want 0", got)
+	}
+	if got := b.countDistinctBingos([]byte("AEINRS")); got != 0 {
+		t.Errorf("short rack: %d, want 0", got)
+	}
+}
+
+func TestSolveOnceTable(t *testing.T) {
+	dict := writeDict(t, testWords)
+	b := newTestBoard(t)
+	place(b, "CAT", 6, 7, DIR_HORIZ)
+	boardPath := filepath.Join(t.TempDir(), "board.txt")
+	if err := saveBoard(b.board, boardPath); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if err := solveOnce(&out, dict, boardPath, "aerst"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if want := "  #  WORD            SCORE  SQUARE  DIR"; lines[0] != want {
+		t.Errorf("header %q, want %q", lines[0], want)
+	}
+	if len(lines) != 11 {
+		t.Fatalf("got %d rows, want 10:\n%s", len(lines)-1, out.String())
+	}
+	// The rows are findTopNMoves' top 10, best first, squares 1-based.
+	top, err := b.findTopNMoves([]byte("AERST"), 10, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range top {
+		dir := "H"
+		if m.dir == DIR_VERT {
+			dir = "V"
+		}
+		want := fmt.Sprintf("%3d  %-15s %5d  %-7s %s", i+1, fullWord(b, m), m.score, fmt.Sprintf("(%d,%d)", m.x+1, m.y+1), dir)
+		if lines[i+1] != want {
+			t.Errorf("row %d = %q, want %q", i+1, lines[i+1], want)
+		}
+	}
+
+	out.Reset()
+	if err := solveOnce(&out, dict, boardPath, "QQ"); err != nil || out.String() != "No valid moves found.\n" {
+		t.Errorf("rack with no play: %q, %v", out.String(), err)
+	}
+	if err := solveOnce(&out, dict, boardPath, "AB1"); err == nil {
+		t.Error("rack with a digit was accepted")
+	}
+	if err := solveOnce(&out, dict, filepath.Join(t.TempDir(), "missing.txt"), "AERST"); err == nil {
+		t.Error("missing board file was accepted")
+	}
+}
+
+func TestSolveManifest(t *testing.T) {
+	dict := writeDict(t, testWords)
+	dir := t.TempDir()
+	across, down := newTestBoard(t), newTestBoard(t)
+	place(across, "CAT", 6, 7, DIR_HORIZ)
+	place(down, "CAT", 7, 6, DIR_VERT)
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveBoard(across.board, filepath.Join(dir, "across.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveBoard(down.board, filepath.Join(dir, "sub", "down.txt")); err != nil {
+		t.Fatal(err)
+	}
+	racks := map[string]string{"across.txt": "aerst", "sub/down.txt": "S", "missing.txt": "AE"}
+	boards := map[string]*Board{"across.txt": across, "sub/down.txt": down}
+
+	manifests := map[string]string{
+		"positions.json": `{"across.txt": "aerst", "sub/down.txt": "S", "missing.txt": "AE"}`,
+		"positions.tsv":  "# board\track\nacross.txt\taerst\n\nsub/down.txt\tS\nmissing.txt\tAE\n",
+	}
+	for name, data := range manifests {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var out strings.Builder
+		failed, err := solveManifest(&out, dict, path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var results map[string]manifestResult
+		if err := json.Unmarshal([]byte(out.String()), &results); err != nil {
+			t.Fatalf("%s: %v\n%s", name, err, out.String())
+		}
+		if failed != 1 || len(results) != 3 || results["missing.txt"].Error == "" {
+			t.Errorf("%s: %d failed, results %v; want only missing.txt to fail", name, failed, results)
+		}
+		for board, b := range boards {
+			res := results[board]
+			top, err := b.findTopNMoves([]byte(strings.ToUpper(racks[board])), 10, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Rack != racks[board] || res.Error != "" || len(res.Moves) != len(top) || len(top) == 0 {
+				t.Errorf("%s %s: rack %q, error %q, %d moves; want %d", name, board, res.Rack, res.Error, len(res.Moves), len(top))
+				continue
+			}
+			for i, m := range top {
+				if want := bestMoveToResponse(b, m); res.Moves[i].Word != want.Word || res.Moves[i].Score != want.Score {
+					t.Errorf("%s %s move %d = %s %d, want %s %d", name, board, i, res.Moves[i].Word, res.Moves[i].Score, want.Word, want.Score)
+				}
+			}
+		}
+	}
+}
+
+// stripANSI removes the colour escapes buildBoardLines writes.
+func stripANSI(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == 0x1b {
+			for i < len(s) && s[i] != 'm' {
+				i++
+			}
+			continue
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+func TestBoardColumnsAligned(t *testing.T) {
+	saved := cellWidth
+	t.Cleanup(func() { cellWidth = saved })
+
+	for _, width := range []int{1, 2, 3, 4} {
+		cellWidth = width
+		cell := width
+		if cell < 2 {
+			cell = 2
+		}
+		// Once the cell fits it, a digraph tile takes the same columns as a
+		// single letter; in a narrower cell it still gets one space.
+		for _, sym := range []string{".", "A", "Ä", "CH", "LL"} {
+			want := cell
+			if n := utf8.RuneCountInString(sym) + 1; n > want {
+				want = n
+			}
+			if got := utf8.RuneCountInString(sym + padCell(sym)); got != want {
+				t.Errorf("width %d: %q fills %d columns, want %d", width, sym, got, want)
+			}
+		}
+	}
+
+	german, err := newAlphabet(strings.Split("ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÜ", ""), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	useAlphabet(t, german)
+	b := newWordsBoard(t, []string{"BÄR"})
+	tiles, _ := alphabet.encode("BÄR")
+	place(b, tiles, 6, 7, DIR_HORIZ)
+	b.board[6][8] = tiles[1] | 32 // a blank Ä
+	cellWidth = 3
+	for y, line := range buildBoardLines(b, map[int]bool{cti(7, 7): true}) {
+		plain := stripANSI(line)
+		if n := utf8.RuneCountInString(plain); n != boardSize*cellWidth {
+			t.Errorf("row %d is %d columns, want %d: %q", y, n, boardSize*cellWidth, plain)
+		}
+		if y == 7 && !strings.Contains(plain, "B  Ä  R  ") {
+			t.Errorf("row 7 = %q, want B, Ä, R in aligned cells", plain)
+		}
+		if y == 8 && !strings.Contains(plain, "ä  ") {
+			t.Errorf("row 8 = %q, want the blank Ä shown as ä", plain)
+		}
+	}
+}
+
+func TestFirstMoveCoversCustomCenter(t *testing.T) {
+	useRuleset(t, "scrabble", func(def *rulesetDef) { def.Center = &[2]int{2, 12} })
+	b := newTestBoard(t)
+
+	if b.checkCenterPlayed(6, 7, 3, DIR_HORIZ) {
+		t.Error("opening through (7,7) accepted with the center at (2,12)")
+	}
+	if !b.checkCenterPlayed(1, 12, 3, DIR_HORIZ) || !b.checkCenterPlayed(2, 10, 3, DIR_VERT) {
+		t.Error("opening over (2,12) rejected")
+	}
+	if _, problems := b.validatePlay("CAT", 6, 7, DIR_HORIZ); len(problems) == 0 {
+		t.Error("validatePlay accepts an opening off the center")
+	}
+	if _, problems := b.validatePlay("CAT", 0, 12, DIR_HORIZ); len(problems) != 0 {
+		t.Errorf("validatePlay rejects CAT over the center: %v", problems)
+	}
+
+	placements := b.findOpponentPlacements("CAT")
+	if len(placements) == 0 {
+		t.Fatal("no opening placements for CAT")
+	}
+	for _, m := range placements {
+		covers := false
+		for _, p := range newTilePositions(b.board, m) {
+			covers = covers || (p.X == 2 && p.Y == 12)
+		}
+		if !covers {
+			t.Errorf("opponent opening at (%d,%d) misses the center", m.x, m.y)
+		}
+	}
+}
+
+func TestMinScoreKeepsTopN(t *testing.T) {
+	b := newTestBoard(t)
+	place(b, "CAT", 6, 7, DIR_HORIZ)
+	rack := []byte("AERST")
+	all := allMoves(t, b, rack)
+
+	// The threshold is the n-th best score: at least n moves reach it,
+	// and the rest fall below.
+	const n = 5
+	min := all[n-1].score
+	var high []BestMove
+	for _, m := range all {
+		if m.score >= min {
+			high = append(high, m)
+		}
+	}
+	if len(high) < n || len(high) == len(all) {
+		t.Fatalf("threshold %d keeps %d of %d moves; pick another board", min, len(high), len(all))
+	}
+
+	b.minScore = min
+	top, err := b.findTopNMoves(rack, n, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(moveKeys(top), moveKeys(high[:n])) {
+		t.Errorf("minScore %d top %d = %v, want %v", min, n, moveKeys(top), moveKeys(high[:n]))
+	}
+	for _, m := range allMoves(t, b, rack) {
+		if m.score < min {
+			t.Errorf("%s scores %d, below minScore %d", fullWord(b, m), m.score, min)
+		}
+	}
+}
+
+func TestParseRackInvalid(t *testing.T) {
+	tests := []struct {
+		input   string
+		rack    string
+		invalid []string
+	}{
+		{"aer*st", "AER*ST", nil},
+		{"AE1RS2", "AERS", []string{"1", "2"}},
+		{" A E R ", "AER", []string{" "}}, // outer spaces are trimmed
+		{"A,B,,C", "ABC", []string{","}},
+	}
+	for _, tt := range tests {
+		rack, invalid := parseRack(tt.input)
+		if string(rack) != tt.rack || !reflect.DeepEqual(invalid, tt.invalid) {
+			t.Errorf("parseRack(%q) = %q, %q; want %q, %q", tt.input, rack, invalid, tt.rack, tt.invalid)
+		}
+	}
+}
+
+func TestOpponentUnknownTile(t *testing.T) {
+	b := newWordsBoard(t, append([]string{"BAT", "BET", "BIT", "BOT", "BUT"}, testWords...))
+	place(b, "TA", 8, 7, DIR_VERT)
+
+	// B?T across onto the T: each vowel spells a word, and the ? is a real
+	// tile, not a blank.
+	got := map[string]int{}
+	for _, m := range b.findOpponentPlacements("B?T") {
+		w := fullWord(b, m)
+		if m.x == 6 && m.y == 7 && m.dir == DIR_HORIZ {
+			got[w] = m.score
+		}
+		if strings.ContainsAny(m.tiles, "?abcdefghijklmnopqrstuvwxyz") {
+			t.Errorf("%s at (%d,%d) places %q, want real tiles", w, m.x, m.y, m.tiles)
+		}
+	}
+	want := []string{"BAT", "BET", "BIT", "BOT", "BUT"}
+	if len(got) != len(want) {
+		t.Errorf("B?T across at (6,7) = %v, want %v", got, want)
+	}
+	for _, w := range want {
+		if _, ok := got[w]; !ok {
+			t.Errorf("B?T across at (6,7) misses %s", w)
+		} else if got[w] != b.scoreMove(6, 7, w[:2], DIR_HORIZ) {
+			t.Errorf("%s scores %d, want %d", w, got[w], b.scoreMove(6, 7, w[:2], DIR_HORIZ))
+		}
+	}
+}
+
+func TestConnectedComponents(t *testing.T) {
+	b := newTestBoard(t)
+	place(b, "CAT", 6, 7, DIR_HORIZ)
+	place(b, "TA", 8, 7, DIR_VERT) // shares CAT's T
+	place(b, "AX", 1, 1, DIR_VERT)
+
+	want := [][][2]int{
+		{{1, 1}, {1, 2}},
+		{{6, 7}, {7, 7}, {8, 7}, {8, 8}},
+	}
+	if got := connectedComponents(b.board); !reflect.DeepEqual(got, want) {
+		t.Errorf("components = %v, want %v", got, want)
+	}
+	if got, want := b.disconnectedTiles(), map[int]bool{cti(1, 1): true, cti(1, 2): true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("disconnected = %v, want %v (AX, away from the center)", got, want)
+	}
+
+	// Diagonal neighbours are not connected.
+	b.board[9][9] = 'S'
+	if got := connectedComponents(b.board); len(got) != 3 {
+		t.Errorf("with a diagonal tile: %d components %v, want 3", len(got), got)
+	}
+}
+
+func TestMinWordLen(t *testing.T) {
+	b := newWordsBoard(t, append([]string{"XU"}, testWords...))
+	rack := []byte("AERSTUX")
+	words := func() map[string]bool {
+		t.Helper()
+		moves, err := b.findTopNMoves(rack, 1000, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen := map[string]bool{}
+		for _, m := range moves {
+			seen[fullWord(b, m)] = true
+		}
+		return seen
+	}
+	if all := words(); !all["XU"] || !all["RATES"] {
+		t.Fatalf("without minWordLen: XU %v, RATES %v; want both", all["XU"], all["RATES"])
+	}
+	b.minWordLen = 3
+	for w := range words() {
+		if len(w) < 3 {
+			t.Errorf("minWordLen 3 kept %s", w)
+		}
+	}
+	if !words()["RATES"] {
+		t.Error("minWordLen 3 dropped RATES")
+	}
+
+	// One tile extending CAT to CATS is a four-letter play, not a one.
+	place(b, "CAT", 6, 7, DIR_HORIZ)
+	rack = []byte("S")
+	if !words()["CATS"] {
+		t.Error("minWordLen 3 dropped S hooking CATS")
+	}
+}
+
+func TestFlagImpossibleBlanks(t *testing.T) {
+	b := newTestBoard(t)
+	place(b, "CaTs", 6, 7, DIR_HORIZ) // both blanks are on the board
+
+	bag := newTileBag(b.board)
+	if bag['*'] != 0 || bag['C'] != strings.Count(startTiles, "C")-1 || bag['A'] != strings.Count(startTiles, "A") {
+		t.Errorf("bag: * %d, C %d, A %d; want the board's blanks taken from *, not A or S", bag['*'], bag['C'], bag['A'])
+	}
+
+	moves := allMoves(t, b, []byte("AERST*"))
+	b.flagImpossible(moves)
+	var possible, impossible *BestMove
+	for i, m := range moves {
+		usesBlank := m.tiles != upperTiles(m.tiles)
+		if m.impossible != usesBlank {
+			t.Errorf("%s (%q) impossible = %v, want %v", fullWord(b, m), m.tiles, m.impossible, usesBlank)
+		}
+		if m.impossible && impossible == nil {
+			impossible = &moves[i]
+		} else if !m.impossible && possible == nil {
+			possible = &moves[i]
+		}
+	}
+	if possible == nil || impossible == nil {
+		t.Fatalf("want both kinds of move; possible %v, impossible %v", possible, impossible)
+	}
+
+	// The picker dims the impossible row.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString("\r")
+	w.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin; r.Close() }()
+	shown := []BestMove{*possible, *impossible}
+	out := captureStdout(t, func() { movePickerScreen(b, shown, "", 0) })
+	word := fmt.Sprintf("2. %-7s", fullWord(b, *impossible))
+	if !strings.Contains(out, "\x1b[2m  "+word) {
+		t.Errorf("impossible row %q is not dimmed:\n%q", word, out)
+	}
+	if strings.Contains(out, "\x1b[2m  1. ") || strings.Contains(out, "\x1b[2;7m") {
+		t.Errorf("possible row dimmed:\n%q", out)
+	}
+}
+
+func TestMaxBlanks(t *testing.T) {
+	rack := []byte("AER**")
+	for _, withTrie := range []bool{true, false} {
+		b := newTestBoard(t)
+		if !withTrie {
+			b.trie = nil
+		}
+		place(b, "CAT", 6, 7, DIR_HORIZ)
+
+		both := 0
+		for _, m := range allMoves(t, b, rack) {
+			if countBlanks([]byte(m.tiles)) == 2 {
+				both++
+			}
+		}
+		if both == 0 {
+			t.Fatalf("trie %v: no move uses both blanks without a cap", withTrie)
+		}
+
+		b.maxBlanks = 1
+		oneBlank := 0
+		for _, m := range allMoves(t, b, rack) {
+			switch countBlanks([]byte(m.tiles)) {
+			case 2:
+				t.Errorf("trie %v: maxBlanks 1 kept %s (%q)", withTrie, fullWord(b, m), m.tiles)
+			case 1:
+				oneBlank++
+			}
+		}
+		if oneBlank == 0 {
+			t.Errorf("trie %v: maxBlanks 1 dropped every blank play", withTrie)
+		}
+	}
+}
+
+func TestEvaluateOpenness(t *testing.T) {
+	useRuleset(t, "crossplay", nil)
+
+	open := newTestBoard(t)
+	place(open, "CAT", 6, 7, DIR_HORIZ)
+	openness, anchors := open.evaluateOpenness()
+	if openness == 0 || openness > anchors {
+		t.Fatalf("CAT alone: openness %d of %d anchors", openness, anchors)
+	}
+
+	// Packed: every square filled but a 3×3 hole at the center, which has
+	// no word premium in crossplay and no lane out to one.
+	packed := newTestBoard(t)
+	for x := 0; x < boardSize; x++ {
+		for y := 0; y < boardSize; y++ {
+			if x < 6 || x > 8 || y < 6 || y > 8 {
+				packed.board[x][y] = 'E'
+			}
+		}
+	}
+	packed.board[7][7] = 'A'
+	closed, closedAnchors := packed.evaluateOpenness()
+	if closed != 0 || closedAnchors != 8 {
+		t.Errorf("packed board: openness %d of %d anchors, want 0 of 8", closed, closedAnchors)
+	}
+	if closed >= openness {
+		t.Errorf("packed board scores %d, not below the open board's %d", closed, openness)
+	}
+}
@@ -11,6 +11,8 @@ export interface Move {
 export interface Ruleset {
 	name: string;
 	bingoBonus: number;
+	boardSize: number;
+	center: [number, number];
 	letterPoints: Record<string, number>;
 	tripleWord: [number, number][];
 	doubleWord: [number, number][];